@@ -0,0 +1,130 @@
+// Package auth provides the signed session tokens used to authenticate API requests.
+// A token carries the username and role of the authenticated user and an expiration time,
+// it is signed with HMAC-SHA256 so it can be verified without any server side session storage.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dogeplus-backend/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"strings"
+	"time"
+)
+
+// defaultTokenTTL is the token lifetime used when AUTH_TOKEN_TTL is not set
+const defaultTokenTTL = 12 * time.Hour
+
+var (
+	// ErrInvalidToken is returned for malformed tokens or tokens with a wrong signature
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpiredToken is returned for correctly signed tokens past their expiration time
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Claims represents the content of a session token
+type Claims struct {
+	Username  string `json:"sub"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// TokenSigner issues and verifies session tokens with a shared secret
+type TokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewTokenSigner creates a TokenSigner with the given secret and token lifetime.
+func NewTokenSigner(secret []byte, ttl time.Duration) (*TokenSigner, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("token secret should be at least 32 bytes long")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("token lifetime should be positive")
+	}
+
+	return &TokenSigner{secret: secret, ttl: ttl, now: time.Now}, nil
+}
+
+// NewTokenSignerFromConfig creates a TokenSigner using the AUTH_SECRET and AUTH_TOKEN_TTL variables.
+// Without AUTH_SECRET a random secret is generated, tokens are then invalidated at every restart.
+func NewTokenSignerFromConfig(cfg config.Config) (*TokenSigner, error) {
+	ttl := defaultTokenTTL
+	if value := config.GetEnvWithFallback(cfg, config.AuthTokenTTL); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", config.AuthTokenTTL, err)
+		}
+		ttl = parsed
+	}
+
+	secret := []byte(config.GetEnvWithFallback(cfg, config.AuthSecret))
+	if len(secret) == 0 {
+		log.Warnf("%s is not set, using a random secret: tokens won't survive a restart", config.AuthSecret)
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate token secret: %v", err)
+		}
+	}
+
+	return NewTokenSigner(secret, ttl)
+}
+
+// Sign issues a new token for the user, it returns the token and its expiration time.
+func (s *TokenSigner) Sign(username, role string) (string, time.Time, error) {
+	issuedAt := s.now()
+	expiresAt := issuedAt.Add(s.ttl)
+
+	payload, err := json.Marshal(Claims{
+		Username:  username,
+		Role:      role,
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode token claims: %v", err)
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + s.signature(encodedPayload), expiresAt, nil
+}
+
+// Verify checks the token signature and expiration and returns its claims.
+// It returns ErrInvalidToken or ErrExpiredToken if the token can't be accepted.
+func (s *TokenSigner) Verify(token string) (Claims, error) {
+	encodedPayload, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.signature(encodedPayload))) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Username == "" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if s.now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+
+	return claims, nil
+}
+
+// signature returns the base64 encoded HMAC-SHA256 of the encoded payload
+func (s *TokenSigner) signature(encodedPayload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encodedPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
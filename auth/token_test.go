@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// TestTokenSigner_SignVerify tests that a signed token is verified and returns its claims
+func TestTokenSigner_SignVerify(t *testing.T) {
+	signer, err := NewTokenSigner(testSecret, time.Hour)
+	require.NoError(t, err)
+
+	token, expiresAt, err := signer.Sign("mario", "supervisor")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	claims, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "mario", claims.Username)
+	assert.Equal(t, "supervisor", claims.Role)
+	assert.Equal(t, expiresAt.Unix(), claims.ExpiresAt)
+}
+
+// TestTokenSigner_Verify tests that tampered, foreign and expired tokens are rejected
+func TestTokenSigner_Verify(t *testing.T) {
+	signer, err := NewTokenSigner(testSecret, time.Hour)
+	require.NoError(t, err)
+
+	token, _, err := signer.Sign("mario", "operator")
+	require.NoError(t, err)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// Payload swapped with one claiming another role
+	adminToken, _, err := signer.Sign("mario", "procedure-admin")
+	require.NoError(t, err)
+	adminPayload, _, _ := strings.Cut(adminToken, ".")
+	_, err = signer.Verify(adminPayload + "." + signature)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Malformed tokens
+	for _, malformed := range []string{"", "abc", payload, payload + ".", "." + signature} {
+		_, err = signer.Verify(malformed)
+		assert.ErrorIs(t, err, ErrInvalidToken, malformed)
+	}
+
+	// Token signed with another secret
+	other, err := NewTokenSigner([]byte("fedcba9876543210fedcba9876543210"), time.Hour)
+	require.NoError(t, err)
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// Expired token
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = signer.Verify(token)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+// TestNewTokenSigner tests that short secrets and non positive lifetimes are rejected
+func TestNewTokenSigner(t *testing.T) {
+	_, err := NewTokenSigner([]byte("short"), time.Hour)
+	assert.Error(t, err)
+
+	_, err = NewTokenSigner(testSecret, 0)
+	assert.Error(t, err)
+}
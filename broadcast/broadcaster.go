@@ -19,3 +19,10 @@ type Broadcaster interface {
 	Unsubscribe(topic string) error
 	GetTopics() []string
 }
+
+// Streamer is implemented by broadcasters bound to an open http response, like server-sent event streams.
+// The http server waits for these responses to complete before stopping, so they are ended on shutdown.
+type Streamer interface {
+	Broadcaster
+	EndStream()
+}
@@ -5,28 +5,54 @@
 package broadcast
 
 import (
+	"context"
+	"dogeplus-backend/metrics"
+	"fmt"
 	"github.com/gofiber/fiber/v2/log"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 )
 
+// shutdownFlushTimeout bounds how long Shutdown waits for the queued messages to be sent
+const shutdownFlushTimeout = 5 * time.Second
+
 // ConnectionManager handles the management of client connections for real-time broadcasting.
 // It maintains a map of connected broadcasters and provides methods to add, remove, and broadcast messages to clients.
+// Messages are not sent right away: every client has a bounded send queue emptied by its own writer goroutine,
+// so a slow client doesn't delay the others. See QueueConfig for what happens when a queue is full.
 type ConnectionManager struct {
 	Clients       map[string]Broadcaster // Map of client ID to broadcaster
 	lock          sync.RWMutex
-	heartbeatTick time.Duration // Interval for sending heartbeats
-	done          chan struct{} // Channel to signal shutdown
+	queueConfig   QueueConfig
+	queues        map[string]*clientQueue // Send queue of every client, created on first use
+	queuesLock    sync.Mutex
+	heartbeatTick time.Duration           // Interval for sending heartbeats
+	done          chan struct{}           // Channel to signal shutdown
+	shutdownOnce  sync.Once               // Guards done from being closed twice
+	streams       map[string]*topicStream // Sequence numbers and replay buffer of every topic
+	streamsLock   sync.Mutex
+	epoch         int64 // Identifies the sequence numbers of this run
 }
 
 // NewConnectionManager creates a new connection manager with default settings
 // and starts the heartbeat and monitoring goroutines.
 func NewConnectionManager() *ConnectionManager {
+	return NewConnectionManagerWithQueues(DefaultQueueConfig())
+}
+
+// NewConnectionManagerWithQueues creates a new connection manager whose client send queues follow config
+// and starts the heartbeat and monitoring goroutines.
+func NewConnectionManagerWithQueues(config QueueConfig) *ConnectionManager {
 	cm := &ConnectionManager{
 		Clients:       make(map[string]Broadcaster),
+		queueConfig:   config,
+		queues:        make(map[string]*clientQueue),
 		heartbeatTick: 30 * time.Second,
 		done:          make(chan struct{}),
+		streams:       make(map[string]*topicStream),
+		epoch:         time.Now().UnixMilli(),
 	}
 
 	// Start the heartbeat goroutine
@@ -54,13 +80,14 @@ func (cm *ConnectionManager) AddClient(clientID string, client Broadcaster) {
 	defer cm.lock.Unlock()
 
 	cm.Clients[clientID] = client
+	cm.queueFor(clientID, client)
 }
 
 // RemoveClient removes a client from the ConnectionManager's list of clients.
 // It takes a client ID as a parameter.
 // It acquires a lock on the ConnectionManager to ensure thread safety.
 // It unlocks the ConnectionManager when the function exits using a deferred statement.
-// It deletes the client from the Clients map.
+// It deletes the client from the Clients map and discards its queued messages.
 //
 // Example usage:
 //
@@ -71,42 +98,159 @@ func (cm *ConnectionManager) RemoveClient(clientID string) {
 	defer cm.lock.Unlock()
 
 	delete(cm.Clients, clientID)
+	cm.dropQueue(clientID)
+}
+
+// queueFor returns the send queue of a client, creating it on first use.
+// A client replaced under the same ID gets a new queue, the messages queued for the previous one are discarded.
+// The caller must hold the ConnectionManager lock.
+func (cm *ConnectionManager) queueFor(clientID string, client Broadcaster) *clientQueue {
+	cm.queuesLock.Lock()
+	defer cm.queuesLock.Unlock()
+
+	q, ok := cm.queues[clientID]
+	if ok && q.client == client {
+		return q
+	}
+	if ok {
+		q.close()
+	}
+
+	q = newClientQueue(clientID, client, cm.queueConfig, cm.dropClient)
+	cm.queues[clientID] = q
+	return q
+}
+
+// dropQueue closes and forgets the send queue of a client, the caller must hold the ConnectionManager write lock
+func (cm *ConnectionManager) dropQueue(clientID string) {
+	cm.queuesLock.Lock()
+	defer cm.queuesLock.Unlock()
+
+	if q, ok := cm.queues[clientID]; ok {
+		q.close()
+		delete(cm.queues, clientID)
+	}
+}
+
+// enqueue queues a message for a client, the caller must hold the ConnectionManager lock.
+// A client whose queue overflows with the OverflowDisconnect policy is disconnected and removed in the background,
+// enqueue returns false in that case.
+func (cm *ConnectionManager) enqueue(clientID string, client Broadcaster, msg queuedMessage) bool {
+	q := cm.queueFor(clientID, client)
+	if q.push(msg) {
+		return true
+	}
+
+	metrics.SlowClientDisconnects.Inc()
+	log.Warnf("Send queue of client %s is full, disconnecting it", clientID)
+	go cm.dropClient(q)
+	return false
+}
+
+// dropClient removes and disconnects the client of a send queue, unless it was replaced in the meantime.
+// It is used for clients whose queue overflowed or whose Send panicked.
+func (cm *ConnectionManager) dropClient(q *clientQueue) {
+	clientID := q.id
+
+	cm.lock.Lock()
+	cm.queuesLock.Lock()
+	if cm.queues[clientID] == q {
+		delete(cm.Clients, clientID)
+		delete(cm.queues, clientID)
+	}
+	cm.queuesLock.Unlock()
+	cm.lock.Unlock()
+
+	q.close()
+	if err := q.client.Disconnect(); err != nil {
+		log.Warnf("Error disconnecting dropped client %s: %v", clientID, err)
+	}
+}
+
+// SendTo queues a message for a single client, after the messages already queued for it.
+// It returns an error if the client doesn't exist or was disconnected because its queue is full.
+func (cm *ConnectionManager) SendTo(clientID string, message []byte) error {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	client, ok := cm.Clients[clientID]
+	if !ok {
+		return fmt.Errorf("client %s not found", clientID)
+	}
+
+	if !cm.enqueue(clientID, client, queuedMessage{kind: kindDirect, message: message}) {
+		return fmt.Errorf("send queue of client %s is full", clientID)
+	}
+
+	return nil
+}
+
+// Flush waits until every queued message is sent, or until ctx is done.
+// It returns ctx.Err() when some messages were still queued at the deadline.
+func (cm *ConnectionManager) Flush(ctx context.Context) error {
+	cm.queuesLock.Lock()
+	queues := make([]*clientQueue, 0, len(cm.queues))
+	for _, q := range cm.queues {
+		queues = append(queues, q)
+	}
+	cm.queuesLock.Unlock()
+
+	// The queues are closed on Shutdown, so the goroutine is not left waiting past the deadline
+	flushed := make(chan struct{})
+	go func() {
+		for _, q := range queues {
+			q.flush()
+		}
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Broadcast sends a message to all connected clients.
 // It acquires a read lock on the ConnectionManager to ensure thread safety.
 // It releases the read lock when the function exits using a deferred statement.
-// It iterates over each client in the Clients map and calls the Send method to send the message.
-// If there is an error while sending the message to a client, it logs the error and continues
-// broadcasting to other clients.
+// It iterates over each client in the Clients map and queues the message for it,
+// send errors are logged by the client writer goroutine.
 func (cm *ConnectionManager) Broadcast(message []byte) {
 	cm.lock.RLock()
 	defer cm.lock.RUnlock()
 
+	metrics.BroadcastMessages.WithLabelValues(metrics.BroadcastAllTopic).Inc()
+
 	for id, client := range cm.Clients {
 		if !client.IsConnected() {
 			continue
 		}
 
-		err := client.Send(message)
-		if err != nil {
-			log.Errorf("Error broadcasting to client %s: %v", id, err)
-			// Continue broadcasting to other clients
-		}
+		cm.enqueue(id, client, queuedMessage{kind: kindBroadcast, topic: metrics.BroadcastAllTopic, message: message})
 	}
 }
 
 // BroadcastToTopic sends a message to all connected clients subscribed to the specified topic.
+// Every message gets the next sequence number of the topic, JSON object messages are stamped with
+// their "topic" and "seq" fields, and is kept in the topic replay buffer, see Resume.
 // It acquires a read lock on the ConnectionManager to ensure thread safety.
 // It releases the read lock when the function exits using a deferred statement.
 // It iterates over each client in the Clients map, checks if the client is subscribed to the topic,
-// and calls the Send method to send the message if it is.
-// If there is an error while sending the message to a client, it logs the error and continues
-// broadcasting to other clients.
+// and queues the message for it if it is. Send errors are logged by the client writer goroutine.
 func (cm *ConnectionManager) BroadcastToTopic(topic string, message []byte) {
+	stream := cm.stream(topic)
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	message = stream.append(topic, message)
+
 	cm.lock.RLock()
 	defer cm.lock.RUnlock()
 
+	metrics.BroadcastMessages.WithLabelValues(topic).Inc()
+
 	for id, client := range cm.Clients {
 		if !client.IsConnected() {
 			continue
@@ -126,11 +270,7 @@ func (cm *ConnectionManager) BroadcastToTopic(topic string, message []byte) {
 			continue
 		}
 
-		err := client.Send(message)
-		if err != nil {
-			log.Errorf("Error broadcasting to client %s on topic %s: %v", id, topic, err)
-			// Continue broadcasting to other clients
-		}
+		cm.enqueue(id, client, queuedMessage{kind: kindBroadcast, topic: topic, message: message})
 	}
 }
 
@@ -159,7 +299,7 @@ func (cm *ConnectionManager) heartbeatLoop() {
 	}
 }
 
-// sendHeartbeats sends a heartbeat to all connected clients
+// sendHeartbeats queues a heartbeat for all connected clients
 func (cm *ConnectionManager) sendHeartbeats() {
 	cm.lock.RLock()
 	defer cm.lock.RUnlock()
@@ -171,11 +311,7 @@ func (cm *ConnectionManager) sendHeartbeats() {
 			continue
 		}
 
-		err := client.Send(heartbeatMsg)
-		if err != nil {
-			// Log the error but don't remove the client yet
-			log.Errorf("Error sending heartbeat to client %s: %v", id, err)
-		}
+		cm.enqueue(id, client, queuedMessage{kind: kindHeartbeat, message: heartbeatMsg})
 	}
 }
 
@@ -219,13 +355,40 @@ func (cm *ConnectionManager) cleanStaleConnections() {
 				// Continue with removal despite the error
 			}
 			delete(cm.Clients, id)
+			cm.dropQueue(id)
+		}
+	}
+}
+
+// NotifyShutdown sends a server_shutdown message to all connected clients, regardless of their topics,
+// so they can reconnect once the server is back instead of waiting for the heartbeat to time out.
+// Streamer clients are ended once the message is sent, so the http server doesn't wait for their responses while draining.
+func (cm *ConnectionManager) NotifyShutdown() {
+	cm.Broadcast([]byte(`{"type":"server_shutdown","timestamp":` + strconv.FormatInt(time.Now().Unix(), 10) + `}`))
+
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	for id, client := range cm.Clients {
+		if _, ok := client.(Streamer); ok {
+			cm.enqueue(id, client, queuedMessage{kind: kindEndStream})
 		}
 	}
 }
 
-// Shutdown gracefully stops the connection manager
+// Shutdown gracefully stops the connection manager.
+// It is safe to call more than once, later calls only disconnect the clients added in the meantime.
 func (cm *ConnectionManager) Shutdown() {
-	close(cm.done)
+	cm.shutdownOnce.Do(func() {
+		close(cm.done)
+	})
+
+	// Give the writers a chance to send what is left, such as the server_shutdown notice
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer cancel()
+	if err := cm.Flush(ctx); err != nil {
+		log.Warnf("Discarding queued messages during shutdown: %v", err)
+	}
 
 	cm.lock.Lock()
 	defer cm.lock.Unlock()
@@ -238,8 +401,66 @@ func (cm *ConnectionManager) Shutdown() {
 		}
 	}
 
-	// Clear the clients map
+	// Clear the clients map, discarding the queued messages
 	cm.Clients = make(map[string]Broadcaster)
+
+	cm.queuesLock.Lock()
+	defer cm.queuesLock.Unlock()
+
+	for _, q := range cm.queues {
+		q.close()
+	}
+	cm.queues = make(map[string]*clientQueue)
+}
+
+// ConnectedClients returns the number of connected clients.
+func (cm *ConnectionManager) ConnectedClients() int {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	connected := 0
+	for _, client := range cm.Clients {
+		if client.IsConnected() {
+			connected++
+		}
+	}
+
+	return connected
+}
+
+// ClientInfo is a snapshot of a client state, as reported by ClientsInfo
+type ClientInfo struct {
+	ID           string    `json:"id"`
+	Connected    bool      `json:"connected"`
+	LastActivity time.Time `json:"last_activity"`
+	Topics       []string  `json:"topics"`
+	QueueDepth   int       `json:"queue_depth"` // Messages waiting in the send queue
+	Dropped      uint64    `json:"dropped"`     // Messages dropped because the send queue was full
+}
+
+// ClientsInfo returns a snapshot of all the clients, ordered by ID.
+func (cm *ConnectionManager) ClientsInfo() []ClientInfo {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	clients := make([]ClientInfo, 0, len(cm.Clients))
+	for id, client := range cm.Clients {
+		depth, dropped := cm.queueFor(id, client).stats()
+		clients = append(clients, ClientInfo{
+			ID:           id,
+			Connected:    client.IsConnected(),
+			LastActivity: client.LastActivity(),
+			Topics:       client.GetTopics(),
+			QueueDepth:   depth,
+			Dropped:      dropped,
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ID < clients[j].ID
+	})
+
+	return clients
 }
 
 // ClientExists checks if a client with the given ID exists
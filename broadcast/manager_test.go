@@ -5,7 +5,9 @@
 package broadcast
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -164,8 +166,9 @@ func TestConnectionManager_BroadcastToTopic(t *testing.T) {
 				cm.Clients[id] = client
 			}
 
-			// Broadcast the message to the topic
+			// Broadcast the message to the topic and wait for the send queues
 			cm.BroadcastToTopic(tt.topic, tt.message)
+			cm.Flush(context.Background())
 
 			// Check that the expected clients received the message
 			for id, client := range tt.clients {
@@ -214,6 +217,7 @@ func TestConnectionManager_BroadcastToTopic_WithSendError(t *testing.T) {
 	// Broadcast a message to the topic
 	message := []byte("test message")
 	cm.BroadcastToTopic("topic1", message)
+	cm.Flush(context.Background())
 
 	// Check that the normal client received the message despite the error from the other client
 	if len(normalClient.receivedMsgs) == 0 {
@@ -278,3 +282,112 @@ func TestConnectionManager_GetClient(t *testing.T) {
 		t.Errorf("GetClient() returned non-nil client for non-existing client")
 	}
 }
+
+func TestConnectionManager_NotifyShutdown(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	// Clients get the notification regardless of their topics, disconnected ones are skipped
+	subscribed := NewMockBroadcaster(true, []string{"event_updates"})
+	unsubscribed := NewMockBroadcaster(true, []string{})
+	disconnected := NewMockBroadcaster(false, []string{})
+	cm.AddClient("client1", subscribed)
+	cm.AddClient("client2", unsubscribed)
+	cm.AddClient("client3", disconnected)
+
+	cm.NotifyShutdown()
+	cm.Flush(context.Background())
+
+	for name, client := range map[string]*MockBroadcaster{"subscribed": subscribed, "unsubscribed": unsubscribed} {
+		if len(client.receivedMsgs) != 1 || !strings.Contains(string(client.receivedMsgs[0]), `"type":"server_shutdown"`) {
+			t.Errorf("%s client received %q, want a server_shutdown message", name, client.receivedMsgs)
+		}
+	}
+	if len(disconnected.receivedMsgs) != 0 {
+		t.Errorf("disconnected client received %d messages, want 0", len(disconnected.receivedMsgs))
+	}
+}
+
+// mockStreamer is a MockBroadcaster bound to an open http response
+type mockStreamer struct {
+	*MockBroadcaster
+	ended bool
+}
+
+func (m *mockStreamer) EndStream() {
+	m.ended = true
+}
+
+func TestConnectionManager_NotifyShutdown_EndsStreams(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	stream := &mockStreamer{MockBroadcaster: NewMockBroadcaster(true, []string{})}
+	cm.AddClient("client1", stream)
+
+	cm.NotifyShutdown()
+	cm.Flush(context.Background())
+
+	if len(stream.receivedMsgs) != 1 || !strings.Contains(string(stream.receivedMsgs[0]), `"type":"server_shutdown"`) {
+		t.Errorf("stream received %q, want a server_shutdown message", stream.receivedMsgs)
+	}
+	if !stream.ended {
+		t.Errorf("NotifyShutdown() left the stream open")
+	}
+}
+
+func TestConnectionManager_Shutdown(t *testing.T) {
+	cm := NewConnectionManager()
+
+	client := NewMockBroadcaster(true, []string{})
+	cm.AddClient("client1", client)
+
+	cm.Shutdown()
+
+	if client.IsConnected() {
+		t.Errorf("Shutdown() left the client connected")
+	}
+	if cm.ClientExists("client1") {
+		t.Errorf("Shutdown() left the client in the manager")
+	}
+
+	// A second shutdown must not panic
+	cm.Shutdown()
+}
+
+func TestConnectionManager_Shutdown_SendsQueuedMessages(t *testing.T) {
+	cm := NewConnectionManager()
+
+	client := NewMockBroadcaster(true, []string{})
+	cm.AddClient("client1", client)
+
+	// The shutdown notice is still queued when the manager shuts down
+	cm.NotifyShutdown()
+	cm.Shutdown()
+
+	if len(client.receivedMsgs) != 1 || !strings.Contains(string(client.receivedMsgs[0]), `"type":"server_shutdown"`) {
+		t.Errorf("client received %q, want a server_shutdown message", client.receivedMsgs)
+	}
+	if client.IsConnected() {
+		t.Errorf("Shutdown() left the client connected")
+	}
+}
+
+func TestConnectionManager_ClientsInfo(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	cm.AddClient("client2", NewMockBroadcaster(false, []string{}))
+	cm.AddClient("client1", NewMockBroadcaster(true, []string{"event_updates", "central_SRA"}))
+
+	clients := cm.ClientsInfo()
+	if len(clients) != 2 {
+		t.Fatalf("ClientsInfo() returned %d clients, want 2", len(clients))
+	}
+	if clients[0].ID != "client1" || !clients[0].Connected || !reflect.DeepEqual(clients[0].Topics, []string{"event_updates", "central_SRA"}) {
+		t.Errorf("ClientsInfo()[0] = %+v, want connected client1 with its topics", clients[0])
+	}
+	if clients[1].ID != "client2" || clients[1].Connected {
+		t.Errorf("ClientsInfo()[1] = %+v, want disconnected client2", clients[1])
+	}
+}
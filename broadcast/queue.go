@@ -0,0 +1,241 @@
+package broadcast
+
+import (
+	"dogeplus-backend/metrics"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"sync"
+)
+
+// OverflowPolicy tells what happens when a message is queued for a client whose send queue is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest drops the oldest queued message to make room for the new one
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDisconnect disconnects the slow client, it is expected to reconnect and resume its topics
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// DefaultQueueSize is the default number of messages a client send queue holds, larger than a topic replay buffer
+const DefaultQueueSize = 1024
+
+// QueueConfig configures the per client send queues of a ConnectionManager
+type QueueConfig struct {
+	Size     int
+	Overflow OverflowPolicy
+}
+
+// DefaultQueueConfig returns the queue configuration used by NewConnectionManager
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{Size: DefaultQueueSize, Overflow: OverflowDropOldest}
+}
+
+// ParseOverflowPolicy parses an overflow policy name, an empty name is OverflowDropOldest
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch OverflowPolicy(name) {
+	case "", OverflowDropOldest:
+		return OverflowDropOldest, nil
+	case OverflowDisconnect:
+		return OverflowDisconnect, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q, expected %q or %q", name, OverflowDropOldest, OverflowDisconnect)
+	}
+}
+
+// messageKind tells the writer how to account for a queued message
+type messageKind int
+
+const (
+	kindBroadcast messageKind = iota // message broadcast to a topic, or to every client
+	kindHeartbeat                    // heartbeat from sendHeartbeats
+	kindDirect                       // message for this client only, see SendTo
+	kindEndStream                    // not a message, ends a Streamer once the previous messages are sent
+)
+
+// queuedMessage is a message waiting in a client send queue
+type queuedMessage struct {
+	kind    messageKind
+	topic   string // metrics label of broadcast messages
+	message []byte
+}
+
+// clientQueue is the bounded outbound queue of a client, its writer goroutine sends the queued messages in order
+// so a slow client only delays its own messages.
+type clientQueue struct {
+	id       string
+	client   Broadcaster
+	size     int
+	overflow OverflowPolicy
+	mu       sync.Mutex
+	ready    *sync.Cond // signalled when a message is queued or the queue is closed
+	idle     *sync.Cond // signalled when the queue is empty and nothing is being sent
+	items    []queuedMessage
+	sending  bool
+	dropped  uint64
+	closed   bool
+	drop     func(q *clientQueue) // removes the client when its writer gives up on it, called without the queue lock
+}
+
+// newClientQueue creates the send queue of a client and starts its writer goroutine.
+// drop is called when sending to the client panics, it must close the queue.
+func newClientQueue(id string, client Broadcaster, config QueueConfig, drop func(q *clientQueue)) *clientQueue {
+	if config.Size <= 0 {
+		config.Size = DefaultQueueSize
+	}
+
+	q := &clientQueue{
+		id:       id,
+		client:   client,
+		size:     config.Size,
+		overflow: config.Overflow,
+		drop:     drop,
+	}
+	q.ready = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+
+	go q.run()
+
+	return q
+}
+
+// push queues a message applying the overflow policy.
+// It returns false when the queue is full and the policy is OverflowDisconnect, the message is not queued then.
+func (q *clientQueue) push(msg queuedMessage) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return true
+	}
+
+	if len(q.items) >= q.size {
+		if q.overflow == OverflowDisconnect {
+			return false
+		}
+		q.items[0] = queuedMessage{} // release the message for the garbage collector
+		q.items = q.items[1:]
+		q.dropped++
+		metrics.SendQueueDropped.Inc()
+	}
+
+	q.items = append(q.items, msg)
+	q.ready.Signal()
+
+	return true
+}
+
+// free returns the number of messages that can be queued without overflowing
+func (q *clientQueue) free() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.size - len(q.items)
+}
+
+// stats returns the number of queued messages and of messages dropped so far
+func (q *clientQueue) stats() (depth int, dropped uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items), q.dropped
+}
+
+// close stops the writer goroutine, queued messages are discarded
+func (q *clientQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.items = nil
+	q.ready.Broadcast()
+	q.idle.Broadcast()
+}
+
+// flush waits until every queued message is sent or the queue is closed
+func (q *clientQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && (len(q.items) > 0 || q.sending) {
+		q.idle.Wait()
+	}
+}
+
+// run is the writer goroutine, it sends the queued messages until the queue is closed.
+// A client whose Send panics is dropped, so it doesn't take the application down.
+func (q *clientQueue) run() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		for len(q.items) == 0 && !q.closed {
+			q.sending = false
+			q.idle.Broadcast()
+			q.ready.Wait()
+		}
+		if q.closed {
+			return
+		}
+
+		msg := q.items[0]
+		q.items[0] = queuedMessage{}
+		q.items = q.items[1:]
+		q.sending = true
+
+		q.mu.Unlock()
+		if !q.deliverRecovered(msg) {
+			q.drop(q)
+			q.mu.Lock()
+			return
+		}
+		q.mu.Lock()
+	}
+}
+
+// deliverRecovered delivers a message, it returns false if the client panicked
+func (q *clientQueue) deliverRecovered(msg queuedMessage) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Recovered from panic sending to client %s, dropping it: %v", q.id, r)
+			ok = false
+		}
+	}()
+
+	q.deliver(msg)
+	return true
+}
+
+// deliver sends a message to the client, accounting for it in the metrics
+func (q *clientQueue) deliver(msg queuedMessage) {
+	if msg.kind == kindEndStream {
+		if streamer, ok := q.client.(Streamer); ok {
+			streamer.EndStream()
+		}
+		return
+	}
+
+	err := q.client.Send(msg.message)
+
+	switch msg.kind {
+	case kindHeartbeat:
+		if err != nil {
+			// Log the error but don't remove the client yet
+			metrics.HeartbeatSendErrors.Inc()
+			log.Errorf("Error sending heartbeat to client %s: %v", q.id, err)
+			return
+		}
+		metrics.HeartbeatsSent.Inc()
+	case kindBroadcast:
+		if err != nil {
+			metrics.BroadcastSendErrors.WithLabelValues(msg.topic).Inc()
+			log.Errorf("Error broadcasting to client %s on topic %s: %v", q.id, msg.topic, err)
+			return
+		}
+		metrics.BroadcastDeliveries.WithLabelValues(msg.topic).Inc()
+	default:
+		if err != nil {
+			log.Errorf("Error sending to client %s: %v", q.id, err)
+		}
+	}
+}
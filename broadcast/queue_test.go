@@ -0,0 +1,278 @@
+package broadcast
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBroadcaster is a MockBroadcaster whose Send blocks until release is closed, like a slow client
+type blockingBroadcaster struct {
+	*MockBroadcaster
+	release chan struct{}
+	mu      sync.Mutex
+}
+
+func newBlockingBroadcaster(topics []string) *blockingBroadcaster {
+	return &blockingBroadcaster{
+		MockBroadcaster: NewMockBroadcaster(true, topics),
+		release:         make(chan struct{}),
+	}
+}
+
+func (b *blockingBroadcaster) Send(message []byte) error {
+	<-b.release
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.MockBroadcaster.Send(message)
+}
+
+func (b *blockingBroadcaster) Disconnect() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.MockBroadcaster.Disconnect()
+}
+
+func (b *blockingBroadcaster) IsConnected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.MockBroadcaster.IsConnected()
+}
+
+func (b *blockingBroadcaster) messages() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages := make([]string, len(b.receivedMsgs))
+	for i, msg := range b.receivedMsgs {
+		messages[i] = string(msg)
+	}
+	return messages
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    OverflowPolicy
+		wantErr bool
+	}{
+		{"", OverflowDropOldest, false},
+		{"drop_oldest", OverflowDropOldest, false},
+		{"disconnect", OverflowDisconnect, false},
+		{"block", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOverflowPolicy(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseOverflowPolicy(%q) = %q, %v, want %q, error %t", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestConnectionManager_SlowClientDoesNotBlockOthers(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	slow := newBlockingBroadcaster([]string{"topic1"})
+	fast := NewMockBroadcaster(true, []string{"topic1"})
+	cm.AddClient("slow", slow)
+	cm.AddClient("fast", fast)
+
+	done := make(chan struct{})
+	go func() {
+		cm.BroadcastToTopic("topic1", []byte("first"))
+		cm.BroadcastToTopic("topic1", []byte("second"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("BroadcastToTopic() blocked on a slow client")
+	}
+
+	cm.queueFor("fast", fast).flush()
+	if len(fast.receivedMsgs) != 2 {
+		t.Errorf("fast client received %d messages while the slow one was blocked, want 2", len(fast.receivedMsgs))
+	}
+
+	// The slow client gets its messages in order once it catches up
+	close(slow.release)
+	cm.Flush(context.Background())
+	if got := slow.messages(); len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("slow client received %q, want [first second]", got)
+	}
+}
+
+func TestConnectionManager_FlushDeadline(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	slow := newBlockingBroadcaster([]string{"topic1"})
+	cm.AddClient("slow", slow)
+	cm.BroadcastToTopic("topic1", []byte("first"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cm.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush() = %v with a blocked client, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(slow.release)
+	if err := cm.Flush(context.Background()); err != nil {
+		t.Errorf("Flush() = %v once the client caught up, want nil", err)
+	}
+}
+
+func TestConnectionManager_OverflowDropOldest(t *testing.T) {
+	cm := NewConnectionManagerWithQueues(QueueConfig{Size: 2, Overflow: OverflowDropOldest})
+	defer cm.Shutdown()
+
+	slow := newBlockingBroadcaster([]string{"topic1"})
+	cm.AddClient("slow", slow)
+
+	// The writer takes the first message and blocks sending it, the others wait in the queue
+	cm.BroadcastToTopic("topic1", []byte("1"))
+	waitFor(t, func() bool {
+		depth, _ := cm.queueFor("slow", slow).stats()
+		return depth == 0
+	})
+	for _, message := range []string{"2", "3", "4"} {
+		cm.BroadcastToTopic("topic1", []byte(message))
+	}
+
+	info := cm.ClientsInfo()
+	if len(info) != 1 || info[0].QueueDepth != 2 || info[0].Dropped != 1 {
+		t.Errorf("ClientsInfo() = %+v, want queue depth 2 and 1 dropped", info)
+	}
+
+	close(slow.release)
+	cm.Flush(context.Background())
+	if got := slow.messages(); len(got) != 3 || got[0] != "1" || got[1] != "3" || got[2] != "4" {
+		t.Errorf("slow client received %q, want [1 3 4]", got)
+	}
+	if !cm.ClientExists("slow") {
+		t.Errorf("drop_oldest policy removed the client")
+	}
+}
+
+func TestConnectionManager_OverflowDisconnect(t *testing.T) {
+	cm := NewConnectionManagerWithQueues(QueueConfig{Size: 1, Overflow: OverflowDisconnect})
+	defer cm.Shutdown()
+
+	slow := newBlockingBroadcaster([]string{"topic1"})
+	fast := NewMockBroadcaster(true, []string{"topic1"})
+	cm.AddClient("slow", slow)
+	cm.AddClient("fast", fast)
+
+	// The fast client keeps up, its queue is emptied after every message
+	cm.BroadcastToTopic("topic1", []byte("1"))
+	cm.queueFor("fast", fast).flush()
+	waitFor(t, func() bool {
+		depth, _ := cm.queueFor("slow", slow).stats()
+		return depth == 0
+	})
+	cm.BroadcastToTopic("topic1", []byte("2"))
+	cm.queueFor("fast", fast).flush()
+	cm.BroadcastToTopic("topic1", []byte("3"))
+	cm.queueFor("fast", fast).flush()
+
+	waitFor(t, func() bool { return !cm.ClientExists("slow") })
+	if slow.IsConnected() {
+		t.Errorf("slow client still connected after its queue overflowed")
+	}
+	if err := cm.SendTo("slow", []byte("4")); err == nil {
+		t.Errorf("SendTo() a disconnected client error = nil, want an error")
+	}
+
+	close(slow.release)
+	cm.Flush(context.Background())
+	if len(fast.receivedMsgs) != 3 {
+		t.Errorf("fast client received %d messages, want 3", len(fast.receivedMsgs))
+	}
+}
+
+// panickingBroadcaster is a MockBroadcaster whose Send panics
+type panickingBroadcaster struct {
+	*MockBroadcaster
+	mu sync.Mutex
+}
+
+func (p *panickingBroadcaster) Send(message []byte) error {
+	panic("send failed")
+}
+
+func (p *panickingBroadcaster) Disconnect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.MockBroadcaster.Disconnect()
+}
+
+func (p *panickingBroadcaster) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.MockBroadcaster.IsConnected()
+}
+
+func TestConnectionManager_PanickingClient(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	broken := &panickingBroadcaster{MockBroadcaster: NewMockBroadcaster(true, []string{"topic1"})}
+	client := NewMockBroadcaster(true, []string{"topic1"})
+	cm.AddClient("broken", broken)
+	cm.AddClient("client1", client)
+
+	cm.BroadcastToTopic("topic1", []byte("first"))
+
+	// The panicking client is dropped, the others keep receiving their messages
+	waitFor(t, func() bool { return !broken.IsConnected() })
+	if cm.ClientExists("broken") {
+		t.Errorf("panicking client still in the manager")
+	}
+
+	cm.BroadcastToTopic("topic1", []byte("second"))
+	cm.Flush(context.Background())
+	if len(client.receivedMsgs) != 2 {
+		t.Errorf("client received %d messages, want 2", len(client.receivedMsgs))
+	}
+}
+
+func TestConnectionManager_SendTo(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	client := NewMockBroadcaster(true, []string{"topic1"})
+	cm.AddClient("client1", client)
+
+	cm.BroadcastToTopic("topic1", []byte("broadcast"))
+	if err := cm.SendTo("client1", []byte("reply")); err != nil {
+		t.Fatalf("SendTo() error = %v", err)
+	}
+	cm.Flush(context.Background())
+
+	// Direct messages are queued after the broadcasts already queued
+	if len(client.receivedMsgs) != 2 || string(client.receivedMsgs[1]) != "reply" {
+		t.Errorf("client received %q, want [broadcast reply]", client.receivedMsgs)
+	}
+
+	if err := cm.SendTo("missing", []byte("reply")); err == nil {
+		t.Errorf("SendTo() an unknown client error = nil, want an error")
+	}
+}
+
+// waitFor polls condition until it holds, failing the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,166 @@
+package broadcast
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// replaySize is the number of messages kept per topic for clients resuming their stream
+const replaySize = 500
+
+// replayEntry is a broadcast message as sent to the clients, stamped with its sequence number
+type replayEntry struct {
+	seq     uint64
+	message []byte
+}
+
+// topicStream numbers the messages of a topic and keeps the latest ones for replay.
+// Its lock is held for the whole broadcast, so messages of a topic are numbered and sent in order
+// and a resuming client never misses or gets twice a message sent during its replay.
+type topicStream struct {
+	mu      sync.Mutex
+	seq     uint64
+	entries []replayEntry // ring buffer of the latest replaySize messages
+	next    int           // position of the next entry in the ring buffer
+}
+
+// append numbers a message, stamps it with its topic and sequence number and keeps it for replay.
+// It returns the stamped message. The caller must hold the stream lock.
+func (s *topicStream) append(topic string, message []byte) []byte {
+	s.seq++
+	stamped := stampMessage(message, topic, s.seq)
+
+	entry := replayEntry{seq: s.seq, message: stamped}
+	if len(s.entries) < replaySize {
+		s.entries = append(s.entries, entry)
+	} else {
+		s.entries[s.next] = entry
+	}
+	s.next = (s.next + 1) % replaySize
+
+	return stamped
+}
+
+// since returns the messages sent after lastSeq, oldest first.
+// It returns false when some of them are no longer buffered. The caller must hold the stream lock.
+func (s *topicStream) since(lastSeq uint64) ([][]byte, bool) {
+	if lastSeq > s.seq {
+		// Sequence numbers of a previous run, nothing in common with the current ones
+		return nil, false
+	}
+
+	missed := s.seq - lastSeq
+	if missed > uint64(len(s.entries)) {
+		return nil, false
+	}
+
+	messages := make([][]byte, 0, missed)
+	start := len(s.entries) - int(missed)
+	for i := start; i < len(s.entries); i++ {
+		// Ring buffer position of the i-th oldest entry
+		entry := s.entries[(s.next+i)%len(s.entries)]
+		messages = append(messages, entry.message)
+	}
+
+	return messages, true
+}
+
+// stampMessage adds the "topic" and "seq" fields to a JSON object message.
+// Other messages are returned unchanged, they are still numbered and replayed but clients can't tell their sequence number.
+func stampMessage(message []byte, topic string, seq uint64) []byte {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) < 2 || trimmed[0] != '{' {
+		return message
+	}
+
+	header := `{"topic":` + strconv.Quote(topic) + `,"seq":` + strconv.FormatUint(seq, 10)
+	body := bytes.TrimSpace(trimmed[1:])
+	if len(body) > 0 && body[0] != '}' {
+		header += ","
+	}
+
+	stamped := make([]byte, 0, len(header)+len(body))
+	stamped = append(stamped, header...)
+	return append(stamped, body...)
+}
+
+// ResumeResult reports the outcome of ConnectionManager.Resume
+type ResumeResult struct {
+	// Replayed is the number of missed messages sent again
+	Replayed int `json:"replayed"`
+	// LastSeq is the sequence number of the latest message of the topic
+	LastSeq uint64 `json:"last_seq"`
+	// Refetch tells the client that missed messages are no longer available and the state must be fetched again
+	Refetch bool `json:"refetch"`
+}
+
+// Epoch identifies the sequence numbers of this run, they restart from 1 at every start.
+// Clients resuming with the epoch of a previous run are told to refetch.
+func (cm *ConnectionManager) Epoch() int64 {
+	return cm.epoch
+}
+
+// stream returns the stream of a topic, creating it on first use
+func (cm *ConnectionManager) stream(topic string) *topicStream {
+	cm.streamsLock.Lock()
+	defer cm.streamsLock.Unlock()
+
+	stream, ok := cm.streams[topic]
+	if !ok {
+		stream = &topicStream{}
+		cm.streams[topic] = stream
+	}
+
+	return stream
+}
+
+// LastSeq returns the sequence number of the latest message broadcast to a topic, 0 if none.
+func (cm *ConnectionManager) LastSeq(topic string) uint64 {
+	stream := cm.stream(topic)
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	return stream.seq
+}
+
+// Resume subscribes a client to a topic and queues for it the messages broadcast after lastSeq.
+// The epoch is the one the client got its sequence numbers in, 0 if unknown.
+// When the missed messages are no longer buffered, come from another epoch or don't fit the client send queue
+// nothing is replayed and the result asks the client to refetch the state through the REST API.
+func (cm *ConnectionManager) Resume(clientID string, topic string, lastSeq uint64, epoch int64) (ResumeResult, error) {
+	stream := cm.stream(topic)
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	client, ok := cm.Clients[clientID]
+	if !ok {
+		return ResumeResult{}, fmt.Errorf("client %s not found", clientID)
+	}
+
+	if err := client.Subscribe(topic); err != nil {
+		return ResumeResult{}, err
+	}
+
+	result := ResumeResult{LastSeq: stream.seq}
+
+	missed, ok := stream.since(lastSeq)
+	if !ok || (epoch != 0 && epoch != cm.epoch) || len(missed) > cm.queueFor(clientID, client).free() {
+		result.Refetch = true
+		return result, nil
+	}
+
+	// The stream lock keeps new messages of the topic from being queued before the replayed ones
+	for _, message := range missed {
+		if !cm.enqueue(clientID, client, queuedMessage{kind: kindBroadcast, topic: topic, message: message}) {
+			return result, fmt.Errorf("send queue of client %s is full", clientID)
+		}
+		result.Replayed++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,243 @@
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStampMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{"JSON object", `{"type":"update","data":1}`, `{"topic":"topic1","seq":7,"type":"update","data":1}`},
+		{"Empty JSON object", `{}`, `{"topic":"topic1","seq":7}`},
+		{"JSON object with spaces", " { \"type\": \"update\" } ", `{"topic":"topic1","seq":7,"type": "update" }`},
+		{"Plain text", "test message", "test message"},
+		{"JSON array", `[1,2]`, `[1,2]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stampMessage([]byte(tt.message), "topic1", 7)); got != tt.expected {
+				t.Errorf("stampMessage() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConnectionManager_BroadcastToTopic_Sequence(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	client := NewMockBroadcaster(true, []string{"topic1", "topic2"})
+	cm.AddClient("client1", client)
+
+	cm.BroadcastToTopic("topic1", []byte(`{"type":"a"}`))
+	cm.BroadcastToTopic("topic2", []byte(`{"type":"b"}`))
+	cm.BroadcastToTopic("topic1", []byte(`{"type":"c"}`))
+	cm.Flush(context.Background())
+
+	// Every topic is numbered on its own
+	expected := []string{
+		`{"topic":"topic1","seq":1,"type":"a"}`,
+		`{"topic":"topic2","seq":1,"type":"b"}`,
+		`{"topic":"topic1","seq":2,"type":"c"}`,
+	}
+	got := make([]string, len(client.receivedMsgs))
+	for i, msg := range client.receivedMsgs {
+		got[i] = string(msg)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("received %q, want %q", got, expected)
+	}
+
+	if seq := cm.LastSeq("topic1"); seq != 2 {
+		t.Errorf("LastSeq(topic1) = %d, want 2", seq)
+	}
+	if seq := cm.LastSeq("topic3"); seq != 0 {
+		t.Errorf("LastSeq(topic3) = %d, want 0", seq)
+	}
+}
+
+func TestConnectionManager_Resume(t *testing.T) {
+	tests := []struct {
+		name         string
+		broadcasts   int
+		lastSeq      uint64
+		epoch        func(cm *ConnectionManager) int64
+		wantReplayed []string
+		wantRefetch  bool
+	}{
+		{
+			name:         "Replays missed messages",
+			broadcasts:   5,
+			lastSeq:      3,
+			wantReplayed: []string{`{"topic":"topic1","seq":4,"n":4}`, `{"topic":"topic1","seq":5,"n":5}`},
+		},
+		{
+			name:         "Nothing missed",
+			broadcasts:   5,
+			lastSeq:      5,
+			wantReplayed: []string{},
+		},
+		{
+			name:         "Replays the whole buffer",
+			broadcasts:   replaySize + 2,
+			lastSeq:      2,
+			wantReplayed: nil, // replaySize messages, checked by count
+		},
+		{
+			name:        "Gap larger than the buffer",
+			broadcasts:  replaySize + 2,
+			lastSeq:     1,
+			wantRefetch: true,
+		},
+		{
+			name:        "Sequence ahead of the server",
+			broadcasts:  2,
+			lastSeq:     10,
+			wantRefetch: true,
+		},
+		{
+			name:        "Epoch of a previous run",
+			broadcasts:  5,
+			lastSeq:     3,
+			epoch:       func(cm *ConnectionManager) int64 { return cm.Epoch() - 1 },
+			wantRefetch: true,
+		},
+		{
+			name:         "Epoch of the current run",
+			broadcasts:   2,
+			lastSeq:      1,
+			epoch:        func(cm *ConnectionManager) int64 { return cm.Epoch() },
+			wantReplayed: []string{`{"topic":"topic1","seq":2,"n":2}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := NewConnectionManager()
+			defer cm.Shutdown()
+
+			for i := 1; i <= tt.broadcasts; i++ {
+				cm.BroadcastToTopic("topic1", []byte(fmt.Sprintf(`{"n":%d}`, i)))
+			}
+
+			var epoch int64
+			if tt.epoch != nil {
+				epoch = tt.epoch(cm)
+			}
+
+			client := NewMockBroadcaster(true, []string{})
+			cm.AddClient("client1", client)
+			result, err := cm.Resume("client1", "topic1", tt.lastSeq, epoch)
+			if err != nil {
+				t.Fatalf("Resume() error = %v", err)
+			}
+			cm.Flush(context.Background())
+
+			if !reflect.DeepEqual(client.GetTopics(), []string{"topic1"}) {
+				t.Errorf("client topics = %v, want [topic1]", client.GetTopics())
+			}
+			if result.LastSeq != uint64(tt.broadcasts) {
+				t.Errorf("LastSeq = %d, want %d", result.LastSeq, tt.broadcasts)
+			}
+			if result.Refetch != tt.wantRefetch {
+				t.Errorf("Refetch = %t, want %t", result.Refetch, tt.wantRefetch)
+			}
+			if result.Replayed != len(client.receivedMsgs) {
+				t.Errorf("Replayed = %d, but %d messages were sent", result.Replayed, len(client.receivedMsgs))
+			}
+
+			if tt.wantRefetch {
+				if len(client.receivedMsgs) != 0 {
+					t.Errorf("received %d messages, want none when refetching", len(client.receivedMsgs))
+				}
+				return
+			}
+
+			if tt.wantReplayed == nil {
+				expected := tt.broadcasts - int(tt.lastSeq)
+				if len(client.receivedMsgs) != expected {
+					t.Fatalf("received %d messages, want %d", len(client.receivedMsgs), expected)
+				}
+				first := fmt.Sprintf(`{"topic":"topic1","seq":%d,"n":%d}`, tt.lastSeq+1, tt.lastSeq+1)
+				if string(client.receivedMsgs[0]) != first {
+					t.Errorf("first replayed message = %s, want %s", client.receivedMsgs[0], first)
+				}
+				return
+			}
+
+			got := make([]string, len(client.receivedMsgs))
+			for i, msg := range client.receivedMsgs {
+				got[i] = string(msg)
+			}
+			if !reflect.DeepEqual(got, tt.wantReplayed) {
+				t.Errorf("replayed %q, want %q", got, tt.wantReplayed)
+			}
+		})
+	}
+}
+
+func TestConnectionManager_Resume_ThenLive(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	cm.BroadcastToTopic("topic1", []byte(`{"n":1}`))
+
+	client := NewMockBroadcaster(true, []string{})
+	cm.AddClient("client1", client)
+	if _, err := cm.Resume("client1", "topic1", 0, cm.Epoch()); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	// Once resumed the client gets the following messages live
+	cm.BroadcastToTopic("topic1", []byte(`{"n":2}`))
+	cm.Flush(context.Background())
+
+	expected := []string{`{"topic":"topic1","seq":1,"n":1}`, `{"topic":"topic1","seq":2,"n":2}`}
+	got := make([]string, len(client.receivedMsgs))
+	for i, msg := range client.receivedMsgs {
+		got[i] = string(msg)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("received %q, want %q", got, expected)
+	}
+}
+
+func TestConnectionManager_Resume_UnknownClient(t *testing.T) {
+	cm := NewConnectionManager()
+	defer cm.Shutdown()
+
+	if _, err := cm.Resume("missing", "topic1", 0, 0); err == nil {
+		t.Errorf("Resume() error = nil, want an error for an unknown client")
+	}
+}
+
+func TestConnectionManager_Resume_QueueTooSmall(t *testing.T) {
+	cm := NewConnectionManagerWithQueues(QueueConfig{Size: 2, Overflow: OverflowDisconnect})
+	defer cm.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		cm.BroadcastToTopic("topic1", []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+
+	client := NewMockBroadcaster(true, []string{})
+	cm.AddClient("client1", client)
+
+	// Three missed messages don't fit a queue of two, the client must refetch instead of being disconnected
+	result, err := cm.Resume("client1", "topic1", 0, 0)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if !result.Refetch || result.Replayed != 0 {
+		t.Errorf("Resume() = %+v, want a refetch without replay", result)
+	}
+	if !cm.ClientExists("client1") {
+		t.Errorf("Resume() disconnected the client")
+	}
+}
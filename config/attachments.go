@@ -0,0 +1,83 @@
+// Package config provides functionality for loading and managing application configuration.
+// It handles reading configuration from both TOML files and environment variables,
+// providing fallback mechanisms and validation. The package also includes utilities
+// for file path sanitization and Excel file loading based on configuration settings.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AttachmentDir returns the directory task attachments are stored in.
+// It is ATTACHMENTROOT when set, otherwise the "attachments" directory next to TASKROOT.
+func AttachmentDir(config Config) (string, error) {
+	if attachmentRoot := GetEnvWithFallback(config, AttachmentRoot); attachmentRoot != "" {
+		return filepath.Clean(attachmentRoot), nil
+	}
+
+	taskRoot, err := taskRootDir(config)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(taskRoot), "attachments"), nil
+}
+
+// attachmentPath resolves a stored attachment name inside the attachments directory,
+// rejecting names that would escape it.
+func attachmentPath(config Config, storedName string) (string, error) {
+	dir, err := AttachmentDir(config)
+	if err != nil {
+		return "", err
+	}
+
+	cleanName := filepath.Clean(storedName)
+	if storedName == "" || filepath.IsAbs(cleanName) || cleanName == ".." ||
+		strings.HasPrefix(cleanName, ".."+string(filepath.Separator)) || strings.ContainsRune(cleanName, '\x00') {
+		return "", fmt.Errorf("invalid attachment name: %s", storedName)
+	}
+
+	return filepath.Join(dir, cleanName), nil
+}
+
+// SaveAttachmentFile writes an attachment content under the attachments directory, creating missing directories.
+// storedName is the path relative to the attachments directory.
+func SaveAttachmentFile(config Config, storedName string, data []byte) error {
+	path, err := attachmentPath(config, storedName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create attachment directory: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write attachment: %v", err)
+	}
+
+	return nil
+}
+
+// AttachmentFilePath returns the absolute path of a stored attachment, to be sent back to the client.
+func AttachmentFilePath(config Config, storedName string) (string, error) {
+	path, err := attachmentPath(config, storedName)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(path)
+}
+
+// RemoveAttachmentFile deletes a stored attachment, used to clean up when its metadata can't be stored.
+func RemoveAttachmentFile(config Config, storedName string) error {
+	path, err := attachmentPath(config, storedName)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAttachmentFiles(t *testing.T) {
+	root := t.TempDir()
+	config := Config{Variable: map[string]interface{}{string(TaskRoot): filepath.Join(root, "tasks")}}
+
+	// Attachments default to a directory next to TASKROOT
+	dir, err := AttachmentDir(config)
+	if err != nil {
+		t.Fatalf("AttachmentDir() error = %v", err)
+	}
+	if want := filepath.Join(root, "attachments"); dir != want {
+		t.Errorf("AttachmentDir() = %s, want %s", dir, want)
+	}
+
+	if err := SaveAttachmentFile(config, filepath.Join("SRA", "1", "photo.jpg"), []byte("data")); err != nil {
+		t.Fatalf("SaveAttachmentFile() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "SRA", "1", "photo.jpg")); err != nil || string(data) != "data" {
+		t.Errorf("stored attachment = %q (%v), want %q", data, err, "data")
+	}
+
+	for _, name := range []string{"", "../escape.jpg", "/etc/passwd", "SRA/../../escape.jpg"} {
+		if _, err := AttachmentFilePath(config, name); err == nil {
+			t.Errorf("AttachmentFilePath(%q) should fail", name)
+		}
+	}
+
+	// ATTACHMENTROOT overrides the default
+	config.Variable[string(AttachmentRoot)] = filepath.Join(root, "custom")
+	if dir, _ := AttachmentDir(config); dir != filepath.Join(root, "custom") {
+		t.Errorf("AttachmentDir() = %s, want the configured directory", dir)
+	}
+}
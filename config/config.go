@@ -30,6 +30,29 @@ const (
 	TaskRoot = "TASKROOT"
 )
 
+// Optional variables, not required at startup.
+const (
+	// MigrateDryRun when set to "true" reports pending schema migrations and exits without applying them
+	MigrateDryRun = "MIGRATE_DRY_RUN"
+	// AttachmentRoot is the directory task attachments are stored in, defaults to "attachments" next to TASKROOT
+	AttachmentRoot = "ATTACHMENTROOT"
+	// AuthSecret is the secret session tokens are signed with, a random one is generated at startup if not set
+	AuthSecret = "AUTH_SECRET"
+	// AuthTokenTTL is the session token lifetime as a Go duration (e.g. "8h"), defaults to 12h
+	AuthTokenTTL = "AUTH_TOKEN_TTL"
+	// AuthAdminUser and AuthAdminPassword create the first procedure-admin user when the users table is empty
+	AuthAdminUser     = "AUTH_ADMIN_USER"
+	AuthAdminPassword = "AUTH_ADMIN_PASSWORD"
+	// ShutdownTimeout is the deadline for the graceful shutdown as a Go duration (e.g. "30s"), defaults to 15s
+	ShutdownTimeout = "SHUTDOWN_TIMEOUT"
+	// ReconcileInterval is how often the in memory aggregations are checked against the db as a Go duration, defaults to 1m, "0" disables it
+	ReconcileInterval = "RECONCILE_INTERVAL"
+	// SendQueueSize is the number of messages queued per websocket and event stream client, defaults to 1024
+	SendQueueSize = "SEND_QUEUE_SIZE"
+	// SendQueueOverflow is what happens when a client send queue is full, "drop_oldest" (default) or "disconnect"
+	SendQueueOverflow = "SEND_QUEUE_OVERFLOW"
+)
+
 // EnvVarsSlice is a slice of the EnvVars type, representing a collection of environment variables.
 var EnvVarsSlice = []EnvVars{
 	Port,
@@ -7,7 +7,12 @@ package config
 import (
 	"fmt"
 	"github.com/xuri/excelize/v2"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 // LoadExcelFile loads an Excel file based on a given config and filename.
@@ -48,3 +53,211 @@ func LoadExcelFile(config Config, filename string) (*excelize.File, error) {
 
 	return f, nil
 }
+
+// localTaskRevisionsDir is the TASKROOT subdirectory holding the previous revisions of the local task files
+const localTaskRevisionsDir = "revisions"
+
+// localTaskRevisionLayout is the timestamp layout appended to a local task file name to build a revision name
+const localTaskRevisionLayout = "20060102-150405.000000"
+
+// LocalTaskRevision represents a previous revision of a local task file kept in the TASKROOT revisions directory
+type LocalTaskRevision struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// taskRootDir returns the cleaned TASKROOT directory
+func taskRootDir(config Config) (string, error) {
+	taskRoot := GetEnvWithFallback(config, TaskRoot)
+	if taskRoot == "" {
+		return "", fmt.Errorf("TASKROOT is not set in config.toml or environment variables")
+	}
+
+	return filepath.Clean(taskRoot), nil
+}
+
+// CheckTaskRoot checks that TASKROOT is set and its content can be listed.
+func CheckTaskRoot(config Config) error {
+	taskRoot, err := taskRootDir(config)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.ReadDir(taskRoot); err != nil {
+		return fmt.Errorf("TASKROOT is not readable: %v", err)
+	}
+
+	return nil
+}
+
+// checkBaseFilename ensures the filename doesn't contain path separators or null bytes
+func checkBaseFilename(filename string) error {
+	if filename == "" || filepath.Base(filename) != filename || strings.ContainsRune(filename, '\x00') {
+		return fmt.Errorf("filename should not contain path separators: %s", filename)
+	}
+
+	return nil
+}
+
+// revisionPrefix returns the prefix shared by all the revisions of a local task file
+func revisionPrefix(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "_"
+}
+
+// SaveLocalTaskFile writes a local task file to TASKROOT.
+// If a file with the same name already exists it is first kept in the revisions directory
+// under a timestamped name, which is returned (empty if there was no previous file).
+// The new content is written to a temporary file and renamed over the live file, so the live file
+// is never missing nor left half written.
+func SaveLocalTaskFile(config Config, filename string, data []byte) (string, error) {
+	taskRoot, err := taskRootDir(config)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkBaseFilename(filename); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(taskRoot, filename)
+
+	tmpFile, err := os.CreateTemp(taskRoot, "."+filename+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file: %v", err)
+	}
+
+	// Keep the current file as a revision before replacing it
+	revision := ""
+	if _, err := os.Stat(filePath); err == nil {
+		revisionsDir := filepath.Join(taskRoot, localTaskRevisionsDir)
+		if err := os.MkdirAll(revisionsDir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create revisions directory: %v", err)
+		}
+
+		revision = revisionPrefix(filename) + time.Now().Format(localTaskRevisionLayout) + filepath.Ext(filename)
+		if err := linkOrCopy(filePath, filepath.Join(revisionsDir, revision)); err != nil {
+			return "", fmt.Errorf("failed to keep previous revision of %s: %v", filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to stat %s: %v", filePath, err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return "", fmt.Errorf("failed to save %s: %v", filename, err)
+	}
+
+	return revision, nil
+}
+
+// linkOrCopy hard links src to dst, copying it where hard links are not supported
+func linkOrCopy(src, dst string) (err error) {
+	if os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// ListLocalTaskRevisions returns the previous revisions of a local task file, newest first.
+func ListLocalTaskRevisions(config Config, filename string) ([]LocalTaskRevision, error) {
+	taskRoot, err := taskRootDir(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBaseFilename(filename); err != nil {
+		return nil, err
+	}
+
+	revisions := []LocalTaskRevision{}
+
+	entries, err := os.ReadDir(filepath.Join(taskRoot, localTaskRevisionsDir))
+	if os.IsNotExist(err) {
+		return revisions, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read revisions directory: %v", err)
+	}
+
+	prefix := revisionPrefix(filename)
+	ext := filepath.Ext(filename)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		// Skip files whose suffix is not a revision timestamp, e.g. revisions of "SRA_2.xlsx" for "SRA.xlsx"
+		createdAt, err := time.ParseInLocation(localTaskRevisionLayout,
+			strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat revision %s: %v", name, err)
+		}
+
+		revisions = append(revisions, LocalTaskRevision{Name: name, CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+
+	return revisions, nil
+}
+
+// ReadLocalTaskRevision returns the content of a previous revision of a local task file.
+// It returns an error wrapping os.ErrNotExist if the revision does not exist or doesn't belong to the file.
+func ReadLocalTaskRevision(config Config, filename, revision string) ([]byte, error) {
+	taskRoot, err := taskRootDir(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBaseFilename(filename); err != nil {
+		return nil, err
+	}
+
+	if checkBaseFilename(revision) != nil || !strings.HasPrefix(revision, revisionPrefix(filename)) {
+		return nil, fmt.Errorf("revision %s of %s: %w", revision, filename, os.ErrNotExist)
+	}
+
+	data, err := os.ReadFile(filepath.Join(taskRoot, localTaskRevisionsDir, revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %s: %w", revision, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalTaskFileRevisions(t *testing.T) {
+	taskRoot := t.TempDir()
+	config := Config{Variable: map[string]interface{}{string(TaskRoot): taskRoot}}
+
+	// First upload has nothing to keep
+	revision, err := SaveLocalTaskFile(config, "SRA.xlsx", []byte("first"))
+	if err != nil {
+		t.Fatalf("SaveLocalTaskFile() error = %v", err)
+	}
+	if revision != "" {
+		t.Errorf("first upload revision = %q, want empty", revision)
+	}
+
+	// Second upload keeps the first file as a revision
+	revision, err = SaveLocalTaskFile(config, "SRA.xlsx", []byte("second"))
+	if err != nil {
+		t.Fatalf("SaveLocalTaskFile() error = %v", err)
+	}
+	if revision == "" {
+		t.Fatal("second upload revision is empty")
+	}
+
+	current, err := os.ReadFile(filepath.Join(taskRoot, "SRA.xlsx"))
+	if err != nil || string(current) != "second" {
+		t.Errorf("current file = %q (%v), want %q", current, err, "second")
+	}
+
+	// A file of another central must not show up between the revisions
+	if _, err := SaveLocalTaskFile(config, "SRL.xlsx", []byte("other")); err != nil {
+		t.Fatalf("SaveLocalTaskFile() error = %v", err)
+	}
+	if _, err := SaveLocalTaskFile(config, "SRL.xlsx", []byte("other 2")); err != nil {
+		t.Fatalf("SaveLocalTaskFile() error = %v", err)
+	}
+
+	revisions, err := ListLocalTaskRevisions(config, "SRA.xlsx")
+	if err != nil {
+		t.Fatalf("ListLocalTaskRevisions() error = %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Name != revision {
+		t.Fatalf("ListLocalTaskRevisions() = %+v, want only %s", revisions, revision)
+	}
+
+	data, err := ReadLocalTaskRevision(config, "SRA.xlsx", revision)
+	if err != nil || string(data) != "first" {
+		t.Errorf("ReadLocalTaskRevision() = %q (%v), want %q", data, err, "first")
+	}
+
+	// Revisions of other files and path traversals are not found
+	srlRevisions, _ := ListLocalTaskRevisions(config, "SRL.xlsx")
+	if _, err := ReadLocalTaskRevision(config, "SRA.xlsx", srlRevisions[0].Name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadLocalTaskRevision() of another file error = %v, want os.ErrNotExist", err)
+	}
+	if _, err := ReadLocalTaskRevision(config, "SRA.xlsx", "../SRA.xlsx"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadLocalTaskRevision() with traversal error = %v, want os.ErrNotExist", err)
+	}
+
+	if _, err := SaveLocalTaskFile(config, "../SRA.xlsx", []byte("bad")); err == nil {
+		t.Error("SaveLocalTaskFile() with path separators should fail")
+	}
+}
+
+// TestCheckTaskRoot tests the TASKROOT readiness check
+func TestCheckTaskRoot(t *testing.T) {
+	taskRoot := t.TempDir()
+	if err := CheckTaskRoot(Config{Variable: map[string]interface{}{string(TaskRoot): taskRoot}}); err != nil {
+		t.Errorf("CheckTaskRoot() error = %v", err)
+	}
+
+	if err := CheckTaskRoot(Config{Variable: map[string]interface{}{string(TaskRoot): filepath.Join(taskRoot, "missing")}}); err == nil {
+		t.Errorf("CheckTaskRoot() with a missing TASKROOT returned no error")
+	}
+}
@@ -30,19 +30,84 @@ func (e NoEventsFoundError) Error() string {
 
 // ActiveEvents represents active events with relative properties
 type ActiveEvents struct {
-	UUID            uuid.UUID `json:"uuid"`
-	EventNumber     int       `json:"event_number"`
-	EventDate       time.Time `json:"event_date"`
-	CentralID       string    `json:"central_id"`
-	Priority        int       `json:"priority"`
-	Title           string    `json:"title"`
-	Description     string    `json:"description"`
-	Role            string    `json:"role"`
-	Status          string    `json:"status"`
-	ModifiedBy      string    `json:"modified_by"`
-	IpAddress       string    `json:"ip_address"`
-	Timestamp       time.Time `json:"timestamp"`
-	EscalationLevel string    `json:"escalation_level"`
+	UUID            uuid.UUID  `json:"uuid"`
+	EventNumber     int        `json:"event_number"`
+	EventDate       time.Time  `json:"event_date"`
+	CentralID       string     `json:"central_id"`
+	Priority        int        `json:"priority"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Role            string     `json:"role"`
+	Category        string     `json:"category"`
+	Status          string     `json:"status"`
+	ModifiedBy      string     `json:"modified_by"`
+	IpAddress       string     `json:"ip_address"`
+	Timestamp       time.Time  `json:"timestamp"`
+	EscalationLevel string     `json:"escalation_level"`
+	TemplateVersion int        `json:"template_version"`
+	AssignedTo      string     `json:"assigned_to"`
+	AssignedAt      *time.Time `json:"assigned_at,omitempty"`
+}
+
+// activeEventColumns lists the active_events columns read by scanActiveEvent, in scan order.
+// Tasks created before template versioning have no template version and are reported as version 0.
+// Unassigned tasks have an empty assigned_to and no assigned_at, tasks created before categories were recorded have an empty category.
+const activeEventColumns = `uuid, event_number, event_date, central_id, priority, title, description, role, status,
+	modified_by, ip_address, timestamp, escalation_level, COALESCE(template_version, 0), COALESCE(assigned_to, ''),
+	assigned_at, COALESCE(category, '')`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanActiveEvent scans a row selected with activeEventColumns, parsing the stored dates to actual types.
+func scanActiveEvent(row rowScanner) (ActiveEvents, error) {
+	var tmpEventDate string // event date as string to be scanned to before parsing
+	var tmpTimestamp string // timestamp as string to be scanned to before parsing
+	var assignedAt sql.NullTime
+	var event ActiveEvents
+	layout := "2006-01-02 15:04:05.999999-07:00"
+
+	if err := row.Scan(&event.UUID, &event.EventNumber, &tmpEventDate, &event.CentralID, &event.Priority, &event.Title,
+		&event.Description, &event.Role, &event.Status, &event.ModifiedBy, &event.IpAddress, &tmpTimestamp,
+		&event.EscalationLevel, &event.TemplateVersion, &event.AssignedTo, &assignedAt, &event.Category); err != nil {
+		return ActiveEvents{}, err
+	}
+
+	// parse time to actual type
+	parsedEventDate, err := time.Parse(layout, tmpEventDate)
+	if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to parse event date: %w", err)
+	}
+	parsedTimestamp, err := time.Parse(layout, tmpTimestamp)
+	if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	event.EventDate = parsedEventDate
+	event.Timestamp = parsedTimestamp
+
+	event.AssignedAt = optionalTime(assignedAt)
+
+	return event, nil
+}
+
+// nullTime converts an optional time to the value to be stored in a nullable date column
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *value, Valid: true}
+}
+
+// optionalTime converts a nullable date column to an optional time, nil for NULL
+func optionalTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Time
 }
 
 // AggregatedActiveEvents represents aggregated active events with the following properties:
@@ -94,11 +159,12 @@ func NewActiveEventRepository(db *sql.DB) *ActiveEventsRepository {
 // It returns an error if the database operation fails.
 func (e *ActiveEventsRepository) Add(tx *sql.Tx, task ActiveEvents) error {
 	query := `INSERT INTO active_events (UUID, event_number , event_date, central_id, Priority, Title, Description, 
-				Role, Status,modified_by,ip_address, Timestamp, escalation_level)
-			   VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,?,?)`
+				Role, Status,modified_by,ip_address, Timestamp, escalation_level, template_version, assigned_to, assigned_at, category)
+			   VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,?,?, NULLIF(?, 0), NULLIF(?, ''), ?, ?)`
 
 	_, err := tx.Exec(query, task.UUID, task.EventNumber, task.EventDate, task.CentralID, task.Priority, task.Title,
-		task.Description, task.Role, task.Status, task.ModifiedBy, task.IpAddress, task.Timestamp, task.EscalationLevel)
+		task.Description, task.Role, task.Status, task.ModifiedBy, task.IpAddress, task.Timestamp, task.EscalationLevel,
+		task.TemplateVersion, task.AssignedTo, nullTime(task.AssignedAt), task.Category)
 
 	return err
 }
@@ -118,6 +184,7 @@ func (e *ActiveEventsRepository) TaskToActiveEvent(task Task, eventNumber int, c
 		Title:           task.Title,
 		Description:     task.Description,
 		Role:            task.Role,
+		Category:        task.Category,
 		Status:          TaskNotdone,
 		Timestamp:       time.Now(),
 		EscalationLevel: task.EscalationLevel,
@@ -129,6 +196,7 @@ func (e *ActiveEventsRepository) TaskToActiveEvent(task Task, eventNumber int, c
 // This method begins a transaction on the database, converts each task into an ActiveEvents object,
 // and inserts it into the active_events table using the Add method. If any error occurs during this process,
 // the transaction is rolled back and the error is returned. Otherwise, the transaction is committed.
+// Every created record references the current task template version.
 // It returns an error if the transaction fails to begin, any Add operation fails, or the transaction fails to commit.
 func (e *ActiveEventsRepository) CreateFromTaskList(tasks []Task, eventNumber int, centralId string) (err error) {
 
@@ -147,12 +215,9 @@ func (e *ActiveEventsRepository) CreateFromTaskList(tasks []Task, eventNumber in
 		}
 	}()
 
-	for _, task := range tasks {
-		t := e.TaskToActiveEvent(task, eventNumber, centralId)
-		err = e.Add(tx, t)
-		if err != nil {
-			return err
-		}
+	err = e.addTasks(tx, tasks, eventNumber, centralId)
+	if err != nil {
+		return err
 	}
 
 	// Get singleton instance of TaskCompletionMap to update aggregation
@@ -164,6 +229,42 @@ func (e *ActiveEventsRepository) CreateFromTaskList(tasks []Task, eventNumber in
 	return nil
 }
 
+// addTasks creates an active event record and its history entry for every task using the given transaction.
+// Every created record references the current task template version.
+func (e *ActiveEventsRepository) addTasks(tx *sql.Tx, tasks []Task, eventNumber int, centralId string) error {
+	// Tasks are built from the current main task template
+	templateVersion, err := currentTemplateVersion(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		t := e.TaskToActiveEvent(task, eventNumber, centralId)
+		t.TemplateVersion = templateVersion
+		err = e.Add(tx, t)
+		if err != nil {
+			return err
+		}
+
+		// Record task creation in history
+		err = addHistoryEntry(tx, HistoryEntry{
+			TaskUUID:    t.UUID,
+			EventNumber: eventNumber,
+			CentralID:   centralId,
+			Title:       t.Title,
+			ChangeType:  HistoryCreated,
+			NewStatus:   t.Status,
+			Timestamp:   t.Timestamp,
+			Detail:      t.EscalationLevel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // GetByCentralID retrieves active events from the database based on the provided central ID.
 // The db parameter is a reference to the database connection.
 // The centralID parameter is the central ID used to filter the events.
@@ -173,8 +274,7 @@ func (e *ActiveEventsRepository) CreateFromTaskList(tasks []Task, eventNumber in
 // a slice of int representing the unique event numbers found,
 // and an error if the database operation fails.
 func (e *ActiveEventsRepository) GetByCentralID(centralId string) ([]ActiveEvents, []int, error) {
-	rows, err := e.db.Query(`SELECT uuid, event_number, event_date, central_id, priority, title, 
-    description, role, status, modified_by, ip_address, timestamp, escalation_level FROM active_events WHERE central_id = ?`, centralId)
+	rows, err := e.db.Query(`SELECT `+activeEventColumns+` FROM active_events WHERE central_id = ?`, centralId)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -182,28 +282,13 @@ func (e *ActiveEventsRepository) GetByCentralID(centralId string) ([]ActiveEvent
 
 	events := []ActiveEvents{}
 	eventNumbers := []int{}
-	layout := "2006-01-02 15:04:05.999999-07:00"
 
 	// Scan row to return slice and count unique event numbers
 	for rows.Next() {
-		var tmpEventDate string // event date as string to be scanned to before parsing
-		var tmpTimestamp string // timestamp as string to be scanned to before parsing
-		var event ActiveEvents
-		if err := rows.Scan(&event.UUID, &event.EventNumber, &tmpEventDate, &event.CentralID, &event.Priority, &event.Title,
-			&event.Description, &event.Role, &event.Status, &event.ModifiedBy, &event.IpAddress, &tmpTimestamp, &event.EscalationLevel); err != nil {
-			return nil, nil, err
-		}
-		// parse time to actual type
-		parsedEventDate, err := time.Parse(layout, tmpEventDate)
-		if err != nil {
-			return nil, nil, err
-		}
-		parsedTimestamp, err := time.Parse(layout, tmpTimestamp)
+		event, err := scanActiveEvent(rows)
 		if err != nil {
 			return nil, nil, err
 		}
-		event.EventDate = parsedEventDate
-		event.Timestamp = parsedTimestamp
 
 		// Append event to slice
 		events = append(events, event)
@@ -240,35 +325,20 @@ func (e *ActiveEventsRepository) GetByCentralID(centralId string) ([]ActiveEvent
 // It returns a slice of ActiveEvents representing the retrieved events
 // and an error if the database operation fails.
 func (e *ActiveEventsRepository) GetByCentralAndNumber(eventNumber int, centralId string) ([]ActiveEvents, error) {
-	rows, err := e.db.Query(`SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status, modified_by, ip_address, timestamp, escalation_level
-								FROM active_events WHERE central_id = ? AND event_number = ?`, centralId, eventNumber)
+	rows, err := e.db.Query(`SELECT `+activeEventColumns+` FROM active_events WHERE central_id = ? AND event_number = ?`,
+		centralId, eventNumber)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	events := []ActiveEvents{}
-	layout := "2006-01-02 15:04:05.999999-07:00"
 
 	for rows.Next() {
-		var tmpEventDate string // event date as string to be scanned to before parsing
-		var tmpTimestamp string // timestamp as string to be scanned to before parsing
-		var event ActiveEvents
-		if err := rows.Scan(&event.UUID, &event.EventNumber, &tmpEventDate, &event.CentralID, &event.Priority,
-			&event.Title, &event.Description, &event.Role, &event.Status, &event.ModifiedBy, &event.IpAddress, &tmpTimestamp, &event.EscalationLevel); err != nil {
-			return nil, err
-		}
-		// parse time to actual type
-		parsedEventDate, err := time.Parse(layout, tmpEventDate)
-		if err != nil {
-			return nil, err
-		}
-		parsedTimestamp, err := time.Parse(layout, tmpTimestamp)
+		event, err := scanActiveEvent(rows)
 		if err != nil {
 			return nil, err
 		}
-		event.EventDate = parsedEventDate
-		event.Timestamp = parsedTimestamp
 
 		// Append event to slice
 		events = append(events, event)
@@ -383,48 +453,54 @@ func (e *ActiveEventsRepository) UpdateStatus(uuid uuid.UUID, status string, mod
 		}
 	}()
 
+	// Read the current status to be recorded in history
+	var oldStatus string
+	err = tx.QueryRow("SELECT status FROM active_events WHERE uuid = ?", uuid).Scan(&oldStatus)
+	if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to read current status: %w", err)
+	}
+
 	// Update the status
-	_, err = tx.Exec("UPDATE active_events SET status = ?, modified_by = ?, ip_address=?, timestamp=? WHERE uuid = ?", status, modifiedBy, ipAddress, time.Now(), uuid)
+	now := time.Now()
+	_, err = tx.Exec("UPDATE active_events SET status = ?, modified_by = ?, ip_address=?, timestamp=? WHERE uuid = ?", status, modifiedBy, ipAddress, now, uuid)
 	if err != nil {
 		return ActiveEvents{}, fmt.Errorf("failed to update status: %w", err)
 	}
 
 	// Fetch the updated row
-	row := tx.QueryRow("SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status, modified_by, ip_address, timestamp, escalation_level FROM active_events WHERE uuid = ?", uuid)
-
-	var tmpEventDate string // event date as string to be scanned to before parsing
-	var tmpTimestamp string // timestamp as string to be scanned to before parsing
-	layout := "2006-01-02 15:04:05.999999-07:00"
-
-	err = row.Scan(&event.UUID, &event.EventNumber, &tmpEventDate, &event.CentralID, &event.Priority, &event.Title,
-		&event.Description, &event.Role, &event.Status, &event.ModifiedBy, &event.IpAddress, &tmpTimestamp, &event.EscalationLevel)
+	event, err = scanActiveEvent(tx.QueryRow("SELECT "+activeEventColumns+" FROM active_events WHERE uuid = ?", uuid))
 	if err != nil {
 		return ActiveEvents{}, fmt.Errorf("failed to scan updated row: %w", err)
 	}
 
-	// Commit the transaction
-	err = tx.Commit()
+	// Record the status change in history
+	err = addHistoryEntry(tx, HistoryEntry{
+		TaskUUID:    event.UUID,
+		EventNumber: event.EventNumber,
+		CentralID:   event.CentralID,
+		Title:       event.Title,
+		ChangeType:  HistoryStatusChange,
+		OldStatus:   oldStatus,
+		NewStatus:   event.Status,
+		ModifiedBy:  modifiedBy,
+		IpAddress:   ipAddress,
+		Timestamp:   now,
+	})
 	if err != nil {
-		return ActiveEvents{}, fmt.Errorf("failed to commit transaction: %w", err)
+		return ActiveEvents{}, err
 	}
 
-	// parse time to actual type
-	parsedEventDate, err := time.Parse(layout, tmpEventDate)
-	if err != nil {
-		return ActiveEvents{}, fmt.Errorf("failed to parse event date: %w", err)
-	}
-	parsedTimestamp, err := time.Parse(layout, tmpTimestamp)
+	// Commit the transaction
+	err = tx.Commit()
 	if err != nil {
-		return ActiveEvents{}, fmt.Errorf("failed to parse timestamp: %w", err)
+		return ActiveEvents{}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	event.EventDate = parsedEventDate
-	event.Timestamp = parsedTimestamp
 
 	// Get singleton instance of TaskCompletionMap to update aggregation
 	taskCompletionMap := GetTaskCompletionMapInstance(nil, nil)
 
 	// Update the aggregation with query result data
-	taskCompletionMap.UpdateEventStatus(event.EventNumber, event.Status)
+	taskCompletionMap.UpdateEventStatus(event.EventNumber, oldStatus, event.Status)
 
 	return event, nil
 }
@@ -453,31 +529,23 @@ func (e *ActiveEventsRepository) DeleteEvent(eventNumber int, centralId string)
 	return nil
 }
 
-// FilterAndUpdateExistingTasks filters out tasks that already exist in the active_events table.
-// It takes a list of tasks, an event number, and a central ID.
-// For each task in the list:
-// 1. If it exists in the database with status != "notdone", it is removed from the list
-// 2. If it exists in the database with status = "notdone", the database record is updated with data from the task and the task is removed from the list
-// It returns the filtered list of tasks that need to be added as new records.
-func (e *ActiveEventsRepository) FilterAndUpdateExistingTasks(tasks []Task, eventNumber int, centralId string) ([]Task, error) {
-	// Get existing active events for this event number and central ID
-	existingEvents, err := e.GetByCentralAndNumber(eventNumber, centralId)
-	if err != nil {
-		// If no events found, return all tasks as they need to be added
-		if _, ok := err.(*NoEventsFoundError); ok {
-			return tasks, nil
-		}
-		return nil, fmt.Errorf("failed to get existing events: %w", err)
-	}
-
+// updateExistingTasks applies the tasks already existing in the event, matched by title, using the given transaction.
+// Existing "notdone" records are updated with the task data and written to the history, the other ones are left untouched.
+// It returns the tasks not existing in the event, that need to be added as new records, and the number of updated records.
+func (e *ActiveEventsRepository) updateExistingTasks(tx *sql.Tx, tasks []Task, existingEvents []ActiveEvents, eventNumber int, centralId string) (newTasks []Task, updated int, err error) {
 	// Create a map of existing events by title for quick lookup
 	existingEventsByTitle := make(map[string]ActiveEvents)
 	for _, event := range existingEvents {
 		existingEventsByTitle[event.Title] = event
 	}
 
+	// Updated records now follow the current main task template
+	templateVersion, err := currentTemplateVersion(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Filter tasks and update existing events
-	var filteredTasks []Task
 	for _, task := range tasks {
 		// Check if task already exists
 		if existingEvent, exists := existingEventsByTitle[task.Title]; exists {
@@ -493,34 +561,91 @@ func (e *ActiveEventsRepository) FilterAndUpdateExistingTasks(tasks []Task, even
 				updatedEvent.IpAddress = existingEvent.IpAddress
 
 				// Update the existing event in the database
-				_, err := e.db.Exec(`UPDATE active_events SET 
+				_, err = tx.Exec(`UPDATE active_events SET 
 					priority = ?, 
 					title = ?, 
 					description = ?, 
 					role = ?, 
+					category = ?,
 					escalation_level = ?, 
-					timestamp = ? 
+					timestamp = ?,
+					template_version = NULLIF(?, 0)
 					WHERE uuid = ?`,
 					updatedEvent.Priority,
 					updatedEvent.Title,
 					updatedEvent.Description,
 					updatedEvent.Role,
+					updatedEvent.Category,
 					updatedEvent.EscalationLevel,
-					time.Now(),
+					updatedEvent.Timestamp,
+					templateVersion,
 					updatedEvent.UUID)
 
 				if err != nil {
-					return nil, fmt.Errorf("failed to update existing event: %w", err)
+					return nil, 0, fmt.Errorf("failed to update existing event: %w", err)
 				}
+
+				// Record the re-filter in history, detail holds the escalation level transition
+				err = addHistoryEntry(tx, HistoryEntry{
+					TaskUUID:    updatedEvent.UUID,
+					EventNumber: eventNumber,
+					CentralID:   centralId,
+					Title:       updatedEvent.Title,
+					ChangeType:  HistoryEscalationUpdate,
+					OldStatus:   existingEvent.Status,
+					NewStatus:   updatedEvent.Status,
+					ModifiedBy:  existingEvent.ModifiedBy,
+					IpAddress:   existingEvent.IpAddress,
+					Timestamp:   updatedEvent.Timestamp,
+					Detail:      existingEvent.EscalationLevel + " -> " + updatedEvent.EscalationLevel,
+				})
+				if err != nil {
+					return nil, 0, err
+				}
+				updated++
 			}
 			// Skip this task as it already exists (either updated or status != "notdone")
 		} else {
 			// Task doesn't exist, add it to the filtered list
-			filteredTasks = append(filteredTasks, task)
+			newTasks = append(newTasks, task)
+		}
+	}
+
+	return newTasks, updated, nil
+}
+
+// removeForDeEscalation deletes, using the given transaction, the "notdone" tasks of the event whose title is in removeTitles
+// and records their removal in the history. The other tasks are left untouched, with their UUID, status and history.
+// It returns the number of removed tasks.
+func (e *ActiveEventsRepository) removeForDeEscalation(tx *sql.Tx, existing []ActiveEvents, removeTitles map[string]bool, eventNumber int, centralId string) (removed int, err error) {
+	for _, event := range existing {
+		if !removeTitles[event.Title] || event.Status != TaskNotdone {
+			continue
+		}
+
+		_, err = tx.Exec("DELETE FROM active_events WHERE uuid = ?", event.UUID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete event task: %w", err)
+		}
+
+		err = addHistoryEntry(tx, HistoryEntry{
+			TaskUUID:    event.UUID,
+			EventNumber: eventNumber,
+			CentralID:   centralId,
+			Title:       event.Title,
+			ChangeType:  HistoryDeEscalationRemoved,
+			OldStatus:   event.Status,
+			ModifiedBy:  event.ModifiedBy,
+			IpAddress:   event.IpAddress,
+			Detail:      event.EscalationLevel,
+		})
+		if err != nil {
+			return 0, err
 		}
+		removed++
 	}
 
-	return filteredTasks, nil
+	return removed, nil
 }
 
 // GetRawEscalationLevels retrieves distinct event numbers and their associated escalation levels from the active_events table.
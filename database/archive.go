@@ -0,0 +1,236 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"time"
+)
+
+// ClosedEvent summarizes the outcome of closing an event
+type ClosedEvent struct {
+	EventNumber   int       `json:"event_number"`
+	CentralID     string    `json:"central_id"`
+	ClosedAt      time.Time `json:"closed_at"`
+	ClosedBy      string    `json:"closed_by"`
+	ClosureReason string    `json:"closure_reason"`
+	TasksArchived int       `json:"tasks_archived"`
+}
+
+// ArchivedOverview represents the overview of a closed event together with its closure data
+type ArchivedOverview struct {
+	Overview
+	ClosedAt      time.Time `json:"closed_at"`
+	ClosedBy      string    `json:"closed_by"`
+	ClosureReason string    `json:"closure_reason"`
+}
+
+// ArchivedEvent represents a task of a closed event together with its closure data
+type ArchivedEvent struct {
+	ActiveEvents
+	ClosedAt      time.Time `json:"closed_at"`
+	ClosedBy      string    `json:"closed_by"`
+	ClosureReason string    `json:"closure_reason"`
+}
+
+// ArchiveRepository represents a repository for closing events and reading closed ones back
+type ArchiveRepository struct {
+	db *sql.DB
+}
+
+// NewArchiveRepository creates a new instance of ArchiveRepository with the provided database connection.
+func NewArchiveRepository(db *sql.DB) *ArchiveRepository {
+	return &ArchiveRepository{db: db}
+}
+
+// CloseEvent moves all the tasks and the overview of an event from the live tables to the archive tables.
+// The whole move happens in a single transaction, so an event is either fully archived or left untouched.
+// Once committed, the event is removed from the in memory TaskCompletionMap and EscalationLevels aggregations.
+// It returns a NoEventsFoundError if neither tasks nor overview exist for the given central ID and event number.
+func (ar *ArchiveRepository) CloseEvent(eventNumber int, centralId string, closedBy string, reason string) (closed ClosedEvent, err error) {
+	tx, err := ar.db.Begin()
+	if err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	closedAt := time.Now()
+
+	// Archive tasks
+	result, err := tx.Exec(`INSERT INTO archived_events (uuid, event_number, event_date, central_id, priority, title,
+				description, role, status, modified_by, ip_address, timestamp, escalation_level, template_version, assigned_to,
+				assigned_at, category, closed_at, closed_by, closure_reason)
+			SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status, modified_by,
+				ip_address, timestamp, escalation_level, template_version, assigned_to, assigned_at, category, ?, ?, ?
+			FROM active_events WHERE central_id = ? AND event_number = ?`,
+		closedAt, closedBy, reason, centralId, eventNumber)
+	if err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to archive event tasks: %w", err)
+	}
+	tasksArchived, err := result.RowsAffected()
+	if err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to count archived tasks: %w", err)
+	}
+
+	// Archive overview
+	result, err = tx.Exec(`INSERT INTO archived_overview (uuid, central_id, event_number, location, location_detail, type,
+				level, incident_level, closed_at, closed_by, closure_reason)
+			SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level, ?, ?, ?
+			FROM overview WHERE central_id = ? AND event_number = ?`,
+		closedAt, closedBy, reason, centralId, eventNumber)
+	if err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to archive event overview: %w", err)
+	}
+	overviewsArchived, err := result.RowsAffected()
+	if err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to count archived overviews: %w", err)
+	}
+
+	if tasksArchived == 0 && overviewsArchived == 0 {
+		err = &NoEventsFoundError{Detail: "No events found for specified centralId and event number"}
+		return ClosedEvent{}, err
+	}
+
+	// Remove the event from the live tables
+	if _, err = tx.Exec(`DELETE FROM active_events WHERE central_id = ? AND event_number = ?`, centralId, eventNumber); err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to delete event tasks: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM overview WHERE central_id = ? AND event_number = ?`, centralId, eventNumber); err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to delete event overview: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ClosedEvent{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Keep in memory aggregations in sync now that the event is no longer active
+	GetTaskCompletionMapInstance(nil, nil).DeleteEvent(eventNumber)
+	GetEscalationLevelsInstance(nil).Remove(eventNumber)
+
+	return ClosedEvent{
+		EventNumber:   eventNumber,
+		CentralID:     centralId,
+		ClosedAt:      closedAt,
+		ClosedBy:      closedBy,
+		ClosureReason: reason,
+		TasksArchived: int(tasksArchived),
+	}, nil
+}
+
+// GetOverviewsByCentralId retrieves the overviews of all closed events for the provided central ID,
+// most recently closed first.
+func (ar *ArchiveRepository) GetOverviewsByCentralId(centralId string) ([]ArchivedOverview, error) {
+	query := `SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level,
+				closed_at, closed_by, closure_reason
+			FROM archived_overview WHERE central_id = ? ORDER BY closed_at DESC`
+
+	return ar.queryOverviews(query, centralId)
+}
+
+// GetOverviewsByCentralIdAndEventNumber retrieves the overviews of closed events for the provided central ID
+// and event number. More than one overview can be returned as event numbers may be reused after closure.
+func (ar *ArchiveRepository) GetOverviewsByCentralIdAndEventNumber(centralId string, eventNumber int) ([]ArchivedOverview, error) {
+	query := `SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level,
+				closed_at, closed_by, closure_reason
+			FROM archived_overview WHERE central_id = ? AND event_number = ? ORDER BY closed_at DESC`
+
+	return ar.queryOverviews(query, centralId, eventNumber)
+}
+
+// GetTasksByCentralAndNumber retrieves the archived tasks of a closed event for the provided central ID and event number.
+// It returns a NoEventsFoundError if no archived task matches.
+func (ar *ArchiveRepository) GetTasksByCentralAndNumber(eventNumber int, centralId string) ([]ArchivedEvent, error) {
+	rows, err := ar.db.Query(`SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status,
+				modified_by, ip_address, timestamp, escalation_level, COALESCE(template_version, 0), COALESCE(assigned_to, ''),
+				assigned_at, COALESCE(category, ''), closed_at, closed_by, closure_reason
+			FROM archived_events WHERE central_id = ? AND event_number = ? ORDER BY closed_at DESC, priority`,
+		centralId, eventNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query archived tasks")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetTasksByCentralAndNumber")
+	}()
+
+	layout := "2006-01-02 15:04:05.999999-07:00"
+	var tasks []ArchivedEvent
+
+	for rows.Next() {
+		var tmpEventDate, tmpTimestamp, tmpClosedAt string // dates as string to be scanned to before parsing
+		var assignedAt sql.NullTime
+		var task ArchivedEvent
+		if err := rows.Scan(&task.UUID, &task.EventNumber, &tmpEventDate, &task.CentralID, &task.Priority, &task.Title,
+			&task.Description, &task.Role, &task.Status, &task.ModifiedBy, &task.IpAddress, &tmpTimestamp,
+			&task.EscalationLevel, &task.TemplateVersion, &task.AssignedTo, &assignedAt, &task.Category, &tmpClosedAt, &task.ClosedBy, &task.ClosureReason); err != nil {
+			return nil, errors.Wrap(err, "failed to scan archived task row")
+		}
+
+		// parse time to actual type
+		if task.EventDate, err = time.Parse(layout, tmpEventDate); err != nil {
+			return nil, errors.Wrap(err, "failed to parse event date")
+		}
+		if task.Timestamp, err = time.Parse(layout, tmpTimestamp); err != nil {
+			return nil, errors.Wrap(err, "failed to parse timestamp")
+		}
+		if task.ClosedAt, err = time.Parse(layout, tmpClosedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to parse closed at")
+		}
+		task.AssignedAt = optionalTime(assignedAt)
+
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	if len(tasks) == 0 {
+		return nil, &NoEventsFoundError{Detail: "No archived events found for specified centralId and event number"}
+	}
+
+	return tasks, nil
+}
+
+// queryOverviews executes the given archived overview query and scans the resulting rows.
+func (ar *ArchiveRepository) queryOverviews(query string, args ...interface{}) ([]ArchivedOverview, error) {
+	rows, err := ar.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query archived overviews")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in queryOverviews")
+	}()
+
+	layout := "2006-01-02 15:04:05.999999-07:00"
+	var overviews []ArchivedOverview
+
+	for rows.Next() {
+		var tmpClosedAt string // closed at as string to be scanned to before parsing
+		var overview ArchivedOverview
+		if err := rows.Scan(&overview.UUID, &overview.CentralId, &overview.EventNumber, &overview.Location,
+			&overview.LocationDetail, &overview.Type, &overview.Level, &overview.IncidentLevel,
+			&tmpClosedAt, &overview.ClosedBy, &overview.ClosureReason); err != nil {
+			return nil, errors.Wrap(err, "failed to scan archived overview row")
+		}
+		if overview.ClosedAt, err = time.Parse(layout, tmpClosedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to parse closed at")
+		}
+		overviews = append(overviews, overview)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return overviews, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// setupSchemaTestDB creates an in-memory SQLite database with the full application schema
+func setupSchemaTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	// Every pooled connection would get its own in-memory database, keep a single one
+	db.SetMaxOpenConns(1)
+
+	require.NoError(t, migrate(db))
+
+	return db
+}
+
+// TestArchiveRepository_CloseEvent tests that closing an event moves tasks and overview to the archive
+func TestArchiveRepository_CloseEvent(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	activeEventsRepo := NewActiveEventRepository(db)
+	overviewRepo := NewOverviewRepository(db)
+	archiveRepo := NewArchiveRepository(db)
+
+	eventNumber := 42
+	centralID := "SRA"
+
+	// Create the event tasks and overview
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Task 2", EscalationLevel: EscalationAlarm},
+	}, eventNumber, centralID))
+	require.NoError(t, overviewRepo.Add(&Overview{
+		CentralId:   centralID,
+		EventNumber: eventNumber,
+		Location:    "Somewhere",
+		Type:        "fire",
+		Level:       EscalationAlarm,
+	}))
+	GetEscalationLevelsInstance(nil).Add(eventNumber, Allarme)
+
+	closed, err := archiveRepo.CloseEvent(eventNumber, centralID, "supervisor", "false alarm")
+	require.NoError(t, err)
+	assert.Equal(t, 2, closed.TasksArchived)
+	assert.Equal(t, "supervisor", closed.ClosedBy)
+	assert.Equal(t, "false alarm", closed.ClosureReason)
+
+	// Live tables should be empty
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM active_events WHERE event_number = ?", eventNumber).Scan(&count))
+	assert.Equal(t, 0, count)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM overview WHERE event_number = ?", eventNumber).Scan(&count))
+	assert.Equal(t, 0, count)
+
+	// Aggregations should no longer know the event
+	_, ok := GetTaskCompletionMapInstance(nil, nil).Data[eventNumber]
+	assert.False(t, ok)
+	_, ok = GetEscalationLevelsInstance(nil).GetLevels()[eventNumber]
+	assert.False(t, ok)
+
+	// Archive should hold everything
+	overviews, err := archiveRepo.GetOverviewsByCentralIdAndEventNumber(centralID, eventNumber)
+	require.NoError(t, err)
+	require.Len(t, overviews, 1)
+	assert.Equal(t, "Somewhere", overviews[0].Location)
+	assert.Equal(t, "false alarm", overviews[0].ClosureReason)
+
+	tasks, err := archiveRepo.GetTasksByCentralAndNumber(eventNumber, centralID)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "supervisor", tasks[0].ClosedBy)
+}
+
+// TestArchiveRepository_CloseEvent_NotFound tests closing an event that does not exist
+func TestArchiveRepository_CloseEvent_NotFound(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	archiveRepo := NewArchiveRepository(db)
+
+	_, err := archiveRepo.CloseEvent(1, "SRA", "supervisor", "duplicate")
+	require.Error(t, err)
+	_, ok := err.(*NoEventsFoundError)
+	assert.True(t, ok, "Expected NoEventsFoundError, got %T", err)
+}
@@ -0,0 +1,157 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type TaskNotFoundError struct {
+	Detail string
+}
+
+func (e TaskNotFoundError) Error() string {
+	return fmt.Sprintf("task not found: %s", e.Detail)
+}
+
+type TaskAlreadyAssignedError struct {
+	Detail string
+}
+
+func (e TaskAlreadyAssignedError) Error() string {
+	return fmt.Sprintf("task already assigned: %s", e.Detail)
+}
+
+// Assign sets the operator responsible for an active event task, an empty assignee removes the assignment.
+// modifiedBy is the user performing the assignment, recorded in the task history together with the previous assignee.
+// It returns a TaskNotFoundError if the task does not exist.
+func (e *ActiveEventsRepository) Assign(taskUUID uuid.UUID, assignee, modifiedBy, ipAddress string) (ActiveEvents, error) {
+	return e.assign(taskUUID, assignee, modifiedBy, ipAddress, false)
+}
+
+// Claim assigns an unassigned active event task to the operator performing the request.
+// Claiming a task already assigned to the same operator is a no-op change.
+// It returns a TaskNotFoundError if the task does not exist and a TaskAlreadyAssignedError
+// if the task is assigned to another operator.
+func (e *ActiveEventsRepository) Claim(taskUUID uuid.UUID, operator, ipAddress string) (ActiveEvents, error) {
+	return e.assign(taskUUID, operator, operator, ipAddress, true)
+}
+
+// assign implements Assign and Claim in a single transaction, claim refuses to take over another operator task.
+func (e *ActiveEventsRepository) assign(taskUUID uuid.UUID, assignee, modifiedBy, ipAddress string, claim bool) (event ActiveEvents, err error) {
+	// Begin a transaction
+	tx, err := e.db.Begin()
+	if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Read the current assignee to be checked and recorded in history
+	var oldAssignee string
+	err = tx.QueryRow("SELECT COALESCE(assigned_to, '') FROM active_events WHERE uuid = ?", taskUUID).Scan(&oldAssignee)
+	if err == sql.ErrNoRows {
+		return ActiveEvents{}, &TaskNotFoundError{Detail: taskUUID.String()}
+	} else if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to read current assignee: %w", err)
+	}
+
+	if claim && oldAssignee != "" && oldAssignee != assignee {
+		return ActiveEvents{}, &TaskAlreadyAssignedError{Detail: fmt.Sprintf("%s is assigned to %s", taskUUID, oldAssignee)}
+	}
+
+	// Update the assignment
+	now := time.Now()
+	var assignedAt sql.NullTime
+	if assignee != "" {
+		assignedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	if oldAssignee != assignee {
+		_, err = tx.Exec("UPDATE active_events SET assigned_to = NULLIF(?, ''), assigned_at = ? WHERE uuid = ?",
+			assignee, assignedAt, taskUUID)
+		if err != nil {
+			return ActiveEvents{}, fmt.Errorf("failed to update assignment: %w", err)
+		}
+	}
+
+	// Fetch the updated row
+	event, err = scanActiveEvent(tx.QueryRow("SELECT "+activeEventColumns+" FROM active_events WHERE uuid = ?", taskUUID))
+	if err != nil {
+		return ActiveEvents{}, fmt.Errorf("failed to scan updated row: %w", err)
+	}
+
+	if oldAssignee == assignee {
+		return event, nil
+	}
+
+	// Record the assignment change in history
+	err = addHistoryEntry(tx, HistoryEntry{
+		TaskUUID:    event.UUID,
+		EventNumber: event.EventNumber,
+		CentralID:   event.CentralID,
+		Title:       event.Title,
+		ChangeType:  HistoryAssignment,
+		OldStatus:   event.Status,
+		NewStatus:   event.Status,
+		ModifiedBy:  modifiedBy,
+		IpAddress:   ipAddress,
+		Timestamp:   now,
+		Detail:      assigneeOrUnassigned(oldAssignee) + " -> " + assigneeOrUnassigned(assignee),
+	})
+	if err != nil {
+		return ActiveEvents{}, err
+	}
+
+	return event, nil
+}
+
+// assigneeOrUnassigned returns the assignee to be shown in history details
+func assigneeOrUnassigned(assignee string) string {
+	if assignee == "" {
+		return "unassigned"
+	}
+
+	return assignee
+}
+
+// GetOpenTasksByAssignee retrieves the tasks assigned to an operator that are not done yet, across all active events.
+// Tasks are ordered by central, event number and priority.
+func (e *ActiveEventsRepository) GetOpenTasksByAssignee(assignee string) ([]ActiveEvents, error) {
+	rows, err := e.db.Query(`SELECT `+activeEventColumns+` FROM active_events
+			WHERE assigned_to = ? AND status != ? ORDER BY central_id, event_number, priority`,
+		assignee, TaskDone)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query assigned tasks")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetOpenTasksByAssignee")
+	}()
+
+	events := []ActiveEvents{}
+	for rows.Next() {
+		event, err := scanActiveEvent(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan assigned task row")
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return events, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestActiveEventsRepository_Assignment tests assignment, claim and the open tasks query
+func TestActiveEventsRepository_Assignment(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	activeEventsRepo := NewActiveEventRepository(db)
+	historyRepo := NewHistoryRepository(db)
+
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Task 2", EscalationLevel: EscalationAlarm},
+	}, 9, "SRA"))
+
+	tasks, err := activeEventsRepo.GetByCentralAndNumber(9, "SRA")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Empty(t, tasks[0].AssignedTo)
+	assert.Nil(t, tasks[0].AssignedAt)
+
+	// Supervisor assigns the first task, an operator claims the second one
+	assigned, err := activeEventsRepo.Assign(tasks[0].UUID, "operator1", "supervisor", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "operator1", assigned.AssignedTo)
+	assert.NotNil(t, assigned.AssignedAt)
+
+	claimed, err := activeEventsRepo.Claim(tasks[1].UUID, "operator1", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, "operator1", claimed.AssignedTo)
+
+	// Another operator can't claim an assigned task
+	_, err = activeEventsRepo.Claim(tasks[1].UUID, "operator2", "10.0.0.3")
+	assert.IsType(t, &TaskAlreadyAssignedError{}, err)
+
+	_, err = activeEventsRepo.Claim(uuid.New(), "operator2", "10.0.0.3")
+	assert.IsType(t, &TaskNotFoundError{}, err)
+
+	// Done tasks are not open anymore
+	_, err = activeEventsRepo.UpdateStatus(tasks[0].UUID, TaskDone, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+
+	open, err := activeEventsRepo.GetOpenTasksByAssignee("operator1")
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	assert.Equal(t, tasks[1].UUID, open[0].UUID)
+
+	// Unassigning clears the assignment and is recorded in history
+	unassigned, err := activeEventsRepo.Assign(tasks[1].UUID, "", "supervisor", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, unassigned.AssignedTo)
+	assert.Nil(t, unassigned.AssignedAt)
+
+	entries, err := historyRepo.GetByTaskUUID(tasks[1].UUID)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, HistoryAssignment, entries[1].ChangeType)
+	assert.Equal(t, "unassigned -> operator1", entries[1].Detail)
+	assert.Equal(t, "operator1 -> unassigned", entries[2].Detail)
+	assert.Equal(t, "supervisor", entries[2].ModifiedBy)
+}
@@ -0,0 +1,168 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+)
+
+type CentralNotFoundError struct {
+	Detail string
+}
+
+func (e CentralNotFoundError) Error() string {
+	return fmt.Sprintf("central not found: %s", e.Detail)
+}
+
+type CentralInactiveError struct {
+	Detail string
+}
+
+func (e CentralInactiveError) Error() string {
+	return fmt.Sprintf("central inactive: %s", e.Detail)
+}
+
+type CentralInUseError struct {
+	Detail string
+}
+
+func (e CentralInUseError) Error() string {
+	return fmt.Sprintf("central in use: %s", e.Detail)
+}
+
+// Central represents an operations central allowed to open events.
+// TaskFile is the name of the local task file, inside TASKROOT, merged with the main tasks for the central events.
+// An empty TaskFile means the central has no local tasks.
+type Central struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	TaskFile string `json:"task_file"`
+	Active   bool   `json:"active"`
+}
+
+type CentralsRepository struct {
+	db *sql.DB
+}
+
+func NewCentralsRepository(db *sql.DB) *CentralsRepository {
+	return &CentralsRepository{db: db}
+}
+
+// GetAll retrieves all the centrals, active and inactive, ordered by id.
+func (c *CentralsRepository) GetAll() ([]Central, error) {
+	rows, err := c.db.Query(`SELECT id, name, task_file, active FROM centrals ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query centrals")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetAll centrals")
+	}()
+
+	centrals := []Central{}
+	for rows.Next() {
+		var central Central
+		if err := rows.Scan(&central.ID, &central.Name, &central.TaskFile, &central.Active); err != nil {
+			return nil, errors.Wrap(err, "failed to scan central row")
+		}
+		centrals = append(centrals, central)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return centrals, nil
+}
+
+// GetByID retrieves a central by its id.
+// It returns a CentralNotFoundError if no central matches.
+func (c *CentralsRepository) GetByID(id string) (Central, error) {
+	var central Central
+	err := c.db.QueryRow(`SELECT id, name, task_file, active FROM centrals WHERE id = ?`, id).
+		Scan(&central.ID, &central.Name, &central.TaskFile, &central.Active)
+	if err == sql.ErrNoRows {
+		return Central{}, &CentralNotFoundError{Detail: id}
+	}
+	if err != nil {
+		return Central{}, errors.Wrap(err, "failed to get central")
+	}
+
+	return central, nil
+}
+
+// GetActive retrieves a central by its id, checking it is allowed to operate on events.
+// It returns a CentralNotFoundError if no central matches and a CentralInactiveError if the central is disabled.
+func (c *CentralsRepository) GetActive(id string) (Central, error) {
+	central, err := c.GetByID(id)
+	if err != nil {
+		return Central{}, err
+	}
+
+	if !central.Active {
+		return Central{}, &CentralInactiveError{Detail: id}
+	}
+
+	return central, nil
+}
+
+// Add inserts a new central into the database.
+func (c *CentralsRepository) Add(central Central) error {
+	_, err := c.db.Exec(`INSERT INTO centrals (id, name, task_file, active) VALUES (?, ?, ?, ?)`,
+		central.ID, central.Name, central.TaskFile, central.Active)
+
+	return errors.Wrap(err, "failed to add central")
+}
+
+// Update modifies name, local task file and active flag of an existing central.
+// It returns a CentralNotFoundError if no central matches.
+func (c *CentralsRepository) Update(central Central) error {
+	result, err := c.db.Exec(`UPDATE centrals SET name = ?, task_file = ?, active = ? WHERE id = ?`,
+		central.Name, central.TaskFile, central.Active, central.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update central")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+	if affected == 0 {
+		return &CentralNotFoundError{Detail: central.ID}
+	}
+
+	return nil
+}
+
+// Delete removes a central from the database.
+// A central still referenced by active events can't be removed and a CentralInUseError is returned,
+// deactivate it instead. It returns a CentralNotFoundError if no central matches.
+func (c *CentralsRepository) Delete(id string) error {
+	var inUse int
+	err := c.db.QueryRow(`SELECT (SELECT COUNT(*) FROM active_events WHERE central_id = ?) +
+       							 (SELECT COUNT(*) FROM overview WHERE central_id = ?)`, id, id).Scan(&inUse)
+	if err != nil {
+		return errors.Wrap(err, "failed to check central usage")
+	}
+	if inUse > 0 {
+		return &CentralInUseError{Detail: id}
+	}
+
+	result, err := c.db.Exec(`DELETE FROM centrals WHERE id = ?`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete central")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+	if affected == 0 {
+		return &CentralNotFoundError{Detail: id}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestCentralsRepository tests the centrals lifecycle and the validation helpers
+func TestCentralsRepository(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewCentralsRepository(db)
+
+	// Seeded centrals
+	centrals, err := repo.GetAll()
+	require.NoError(t, err)
+	require.Len(t, centrals, 5)
+	sra, err := repo.GetActive("SRA")
+	require.NoError(t, err)
+	assert.Equal(t, "SRA.xlsx", sra.TaskFile)
+
+	// Onboard a new central
+	require.NoError(t, repo.Add(Central{ID: "SRN", Name: "New central", TaskFile: "SRN.xlsx", Active: true}))
+	central, err := repo.GetActive("SRN")
+	require.NoError(t, err)
+	assert.Equal(t, "New central", central.Name)
+
+	// Events can be opened on any configured central, no CHECK constraint involved
+	activeEventsRepo := NewActiveEventRepository(db)
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm}}, 99, "SRN"))
+
+	// A central in use can't be deleted, only deactivated
+	err = repo.Delete("SRN")
+	_, ok := err.(*CentralInUseError)
+	assert.True(t, ok, "Expected CentralInUseError, got %T", err)
+
+	central.Active = false
+	require.NoError(t, repo.Update(central))
+	_, err = repo.GetActive("SRN")
+	_, ok = err.(*CentralInactiveError)
+	assert.True(t, ok, "Expected CentralInactiveError, got %T", err)
+
+	// Unknown centrals
+	_, err = repo.GetByID("XXX")
+	_, ok = err.(*CentralNotFoundError)
+	assert.True(t, ok, "Expected CentralNotFoundError, got %T", err)
+	err = repo.Update(Central{ID: "XXX", Name: "Missing"})
+	_, ok = err.(*CentralNotFoundError)
+	assert.True(t, ok, "Expected CentralNotFoundError, got %T", err)
+
+	// Unused central can be deleted
+	require.NoError(t, repo.Delete("HQ"))
+	_, err = repo.GetByID("HQ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,414 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type InvalidEscalationError struct {
+	Detail string
+}
+
+func (e InvalidEscalationError) Error() string {
+	return fmt.Sprintf("invalid escalation: %s", e.Detail)
+}
+
+// EscalationRequest describes the level an event is escalated or de-escalated to.
+// LocalTasks are the tasks of the local task file of the event central, nil when it has none.
+// Operator, IpAddress and Reason are recorded in the escalation history.
+type EscalationRequest struct {
+	EventNumber   int
+	NewLevel      Level
+	IncidentLevel string
+	LocalTasks    []Task
+	Operator      string
+	IpAddress     string
+	Reason        string
+}
+
+// EscalationResult reports the changes applied by an escalation or a de-escalation.
+type EscalationResult struct {
+	Overview         Overview               `json:"overview"`
+	OldLevel         Level                  `json:"old_level"`
+	OldIncidentLevel string                 `json:"old_incident_level"`
+	Added            int                    `json:"added"`
+	Updated          int                    `json:"updated"`
+	Removed          int                    `json:"removed"`
+	History          EscalationHistoryEntry `json:"history"`
+}
+
+// EscalationService escalates and de-escalates events.
+// The overview and the tasks of the event are changed in a single transaction,
+// the in memory TaskCompletionMap and EscalationLevels aggregations are updated only once it is committed.
+// Escalations are serialized, so the aggregations are updated in the same order as the db.
+type EscalationService struct {
+	mu               sync.Mutex
+	db               *sql.DB
+	activeEvents     *ActiveEventsRepository
+	taskCompletion   *TaskCompletionMap
+	escalationLevels *EscalationLevels
+}
+
+// NewEscalationService creates an EscalationService over the db and the aggregations of repos.
+func NewEscalationService(repos *Repositories) *EscalationService {
+	return &EscalationService{
+		db:               repos.ActiveEvents.db,
+		activeEvents:     repos.ActiveEvents,
+		taskCompletion:   repos.TaskCompletionAggregation,
+		escalationLevels: repos.EscalationLevelsAggregation,
+	}
+}
+
+// Escalate raises the level of an event, or its incident level for levels with sub-levels.
+// Tasks of the levels between the old and the new one, from the main and the local task files, are added to the event,
+// existing "notdone" tasks with the same title are updated instead and the others are left untouched.
+// It returns an InvalidEscalationError if the new level is not higher than the current one,
+// and a NoEventsFoundError if the event has no overview.
+func (s *EscalationService) Escalate(request EscalationRequest) (result EscalationResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	overview, existing, err := s.readEvent(tx, request, &result)
+	if err != nil {
+		return result, err
+	}
+
+	levelModel := CurrentLevelModel()
+	oldRank, newRank := result.OldLevel.rank(), request.NewLevel.rank()
+	if newRank < oldRank || (newRank == oldRank && (!levelModel.HasSubLevels(string(request.NewLevel)) ||
+		levelModel.SubRank(string(request.NewLevel), request.IncidentLevel) <= levelModel.SubRank(string(result.OldLevel), result.OldIncidentLevel))) {
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("%s is not higher than the current level %s", describeLevel(request.NewLevel, request.IncidentLevel), describeLevel(result.OldLevel, result.OldIncidentLevel))}
+		return result, err
+	}
+
+	err = s.escalate(tx, overview, existing, request, &result)
+	return result, err
+}
+
+// escalate adds the tasks of the validated escalation in request to the event, updates its overview and records
+// the change in the escalation history using the given transaction, then commits it and updates the aggregations.
+// An escalation within the same level only adds the tasks of the incident levels above the current one.
+func (s *EscalationService) escalate(tx *sql.Tx, overview Overview, existing []ActiveEvents, request EscalationRequest, result *EscalationResult) error {
+	filter := func(tasks []Task) ([]Task, error) {
+		if result.OldLevel == request.NewLevel {
+			return FilterTasksForIncidentLevel(tasks, overview.Type, string(request.NewLevel), result.OldIncidentLevel, request.IncidentLevel), nil
+		}
+		return FilterTasksForEscalation(tasks, overview.Type, string(result.OldLevel), string(request.NewLevel), request.IncidentLevel)
+	}
+
+	// Tasks of the levels the event goes through, local tasks win over the main ones with the same title
+	mainTasks, err := templateTasks(tx, overview.Type)
+	if err != nil {
+		return err
+	}
+	tasks, err := filter(mainTasks)
+	if err != nil {
+		return &InvalidEscalationError{Detail: err.Error()}
+	}
+	if request.LocalTasks != nil {
+		localTasks, err := filter(request.LocalTasks)
+		if err != nil {
+			return &InvalidEscalationError{Detail: err.Error()}
+		}
+		tasks, err = MergeTasks(tasks, localTasks)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Priority < tasks[j].Priority })
+	}
+
+	// Existing tasks are updated in place, only the missing ones are added
+	newTasks, updated, err := s.activeEvents.updateExistingTasks(tx, tasks, existing, overview.EventNumber, overview.CentralId)
+	if err != nil {
+		return err
+	}
+	if err = s.activeEvents.addTasks(tx, newTasks, overview.EventNumber, overview.CentralId); err != nil {
+		return err
+	}
+
+	if err = updateOverviewLevel(tx, &overview, request); err != nil {
+		return err
+	}
+
+	// Record who changed the level and why, with the tasks added
+	entry := escalationHistoryEntry(overview, *result, EscalationDirectionUp, request)
+	entry.TasksAdded = len(newTasks)
+	if result.History, err = addEscalationHistoryEntry(tx, entry); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Keep in memory aggregations in sync now that the escalation is committed
+	s.escalationLevels.Set(overview.EventNumber, request.NewLevel)
+	if len(newTasks) > 0 {
+		if len(existing) == 0 {
+			s.taskCompletion.AddNewEvent(overview.EventNumber, len(newTasks))
+		} else {
+			s.taskCompletion.AddMultipleNotDoneTasks(overview.EventNumber, len(newTasks))
+		}
+	}
+
+	result.Overview = overview
+	result.Added = len(newTasks)
+	result.Updated = updated
+	return nil
+}
+
+// Deescalate lowers the level of an event, or its incident level for levels with sub-levels.
+// The "notdone" tasks included at the old level, from the main and the local task files, but not at the new one
+// are removed. Every other task is left untouched, keeping its UUID, status and history.
+// It returns an InvalidEscalationError if the new level is not lower than the current one,
+// and a NoEventsFoundError if the event has no overview.
+func (s *EscalationService) Deescalate(request EscalationRequest) (result EscalationResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	overview, existing, err := s.readEvent(tx, request, &result)
+	if err != nil {
+		return result, err
+	}
+
+	levelModel := CurrentLevelModel()
+	newLevel := string(request.NewLevel)
+	oldRank, newRank := result.OldLevel.rank(), request.NewLevel.rank()
+	if newRank > oldRank || (newRank == oldRank && (!levelModel.HasSubLevels(newLevel) ||
+		levelModel.SubRank(newLevel, request.IncidentLevel) >= levelModel.SubRank(string(result.OldLevel), result.OldIncidentLevel))) {
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("%s is not lower than the current level %s", describeLevel(request.NewLevel, request.IncidentLevel), describeLevel(result.OldLevel, result.OldIncidentLevel))}
+		return result, err
+	}
+	if levelModel.HasSubLevels(newLevel) && !levelModel.IsSubLevel(newLevel, request.IncidentLevel) {
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("incident level %q is not valid for %s", request.IncidentLevel, newLevel)}
+		return result, err
+	}
+
+	err = s.deescalate(tx, overview, existing, request, &result)
+	return result, err
+}
+
+// deescalate removes the "notdone" tasks left behind by the validated de-escalation in request, updates the event overview
+// and records the change in the escalation history using the given transaction, then commits it and updates the aggregations.
+func (s *EscalationService) deescalate(tx *sql.Tx, overview Overview, existing []ActiveEvents, request EscalationRequest, result *EscalationResult) error {
+	// Titles included at the old level but not at the new one
+	tasks, err := templateTasks(tx, overview.Type)
+	if err != nil {
+		return err
+	}
+	tasks = append(tasks, request.LocalTasks...)
+
+	removeTitles := make(map[string]bool)
+	for _, task := range FilterTasks(tasks, overview.Type, string(result.OldLevel), result.OldIncidentLevel) {
+		removeTitles[task.Title] = true
+	}
+	for _, task := range FilterTasks(tasks, overview.Type, string(request.NewLevel), request.IncidentLevel) {
+		delete(removeTitles, task.Title)
+	}
+
+	removed, err := s.activeEvents.removeForDeEscalation(tx, existing, removeTitles, overview.EventNumber, overview.CentralId)
+	if err != nil {
+		return err
+	}
+
+	if err = updateOverviewLevel(tx, &overview, request); err != nil {
+		return err
+	}
+
+	// Record who changed the level and why, with the tasks removed
+	entry := escalationHistoryEntry(overview, *result, EscalationDirectionDown, request)
+	entry.TasksRemoved = removed
+	if result.History, err = addEscalationHistoryEntry(tx, entry); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Keep in memory aggregations in sync now that the de-escalation is committed
+	s.escalationLevels.Set(overview.EventNumber, request.NewLevel)
+	if removed > 0 {
+		s.taskCompletion.RemoveNotDoneTasks(overview.EventNumber, removed)
+	}
+
+	result.Overview = overview
+	result.Removed = removed
+	return nil
+}
+
+// ChangeIncidentLevel moves an event between the incident levels of its current level, like the colours of incidente,
+// in both directions. Raising it adds the tasks of the incident levels above the current one, as Escalate does,
+// lowering it removes the "notdone" tasks of the incident levels left, as Deescalate does.
+// NewLevel in request must be the current level of the event, so a concurrent level change is never overwritten.
+// It returns an InvalidEscalationError if the event level has no incident levels, NewLevel is not the current level
+// or the incident level is not valid or unchanged, and a NoEventsFoundError if the event has no overview.
+func (s *EscalationService) ChangeIncidentLevel(request EscalationRequest) (result EscalationResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	overview, existing, err := s.readEvent(tx, request, &result)
+	if err != nil {
+		return result, err
+	}
+
+	levelModel := CurrentLevelModel()
+	level := string(result.OldLevel)
+	switch {
+	case request.NewLevel != result.OldLevel:
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("%s is not the current level %s, escalate or de-escalate the event instead", request.NewLevel, result.OldLevel)}
+	case !levelModel.HasSubLevels(level):
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("%s has no incident levels", level)}
+	case !levelModel.IsSubLevel(level, request.IncidentLevel):
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("incident level %q is not valid for %s", request.IncidentLevel, level)}
+	case request.IncidentLevel == result.OldIncidentLevel:
+		err = &InvalidEscalationError{Detail: fmt.Sprintf("the event is already at %s", describeLevel(request.NewLevel, request.IncidentLevel))}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if levelModel.SubRank(level, request.IncidentLevel) > levelModel.SubRank(level, result.OldIncidentLevel) {
+		err = s.escalate(tx, overview, existing, request, &result)
+	} else {
+		err = s.deescalate(tx, overview, existing, request, &result)
+	}
+	return result, err
+}
+
+// readEvent validates the requested level and reads the overview and the tasks of the event using the given transaction.
+// The current level is the overview one, or the highest level of the tasks if the overview has none,
+// it is stored in result together with the current incident level.
+func (s *EscalationService) readEvent(tx *sql.Tx, request EscalationRequest, result *EscalationResult) (Overview, []ActiveEvents, error) {
+	if !request.NewLevel.valid() {
+		return Overview{}, nil, &InvalidEscalationError{Detail: fmt.Sprintf("invalid level provided: %s", request.NewLevel)}
+	}
+
+	overview, err := readOverview(tx, request.EventNumber)
+	if err != nil {
+		return Overview{}, nil, err
+	}
+
+	existing, err := snapshotTasks(tx, overview.CentralId, overview.EventNumber)
+	if err != nil {
+		return Overview{}, nil, err
+	}
+
+	result.OldLevel = Level(overview.Level)
+	result.OldIncidentLevel = overview.IncidentLevel
+	if !result.OldLevel.valid() {
+		levels := NewEscalationLevels()
+		for _, task := range existing {
+			if Level(task.EscalationLevel).valid() {
+				levels.Add(overview.EventNumber, Level(task.EscalationLevel))
+			}
+		}
+		result.OldLevel = levels.Levels[overview.EventNumber]
+	}
+
+	return overview, existing, nil
+}
+
+// templateTasks reads the main tasks of a category, and the ones common to every category, using the given transaction
+func templateTasks(tx *sql.Tx, category string) ([]Task, error) {
+	rows, err := tx.Query(`SELECT id, priority, title, description, role, category, escalation_level, incident_level
+			FROM tasks WHERE category IN (?, ?) ORDER BY priority`, category, "PRO22")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tasks")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in templateTasks")
+	}()
+
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		if err := rows.Scan(&task.ID, &task.Priority, &task.Title, &task.Description, &task.Role, &task.Category,
+			&task.EscalationLevel, &task.IncidentLevel); err != nil {
+			return nil, errors.Wrap(err, "failed to scan task row")
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, errors.Wrap(rows.Err(), "error during row iteration")
+}
+
+// updateOverviewLevel sets the requested level and incident level on the overview using the given transaction
+func updateOverviewLevel(tx *sql.Tx, overview *Overview, request EscalationRequest) error {
+	_, err := tx.Exec(`UPDATE overview SET level = ?, incident_level = ? WHERE uuid = ?`,
+		request.NewLevel, request.IncidentLevel, overview.UUID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update overview level")
+	}
+
+	overview.Level = string(request.NewLevel)
+	overview.IncidentLevel = request.IncidentLevel
+	return nil
+}
+
+// escalationHistoryEntry builds the escalation history entry of a level change of the overview from the old level in result
+func escalationHistoryEntry(overview Overview, result EscalationResult, direction string, request EscalationRequest) EscalationHistoryEntry {
+	return EscalationHistoryEntry{
+		EventNumber:      overview.EventNumber,
+		CentralID:        overview.CentralId,
+		Direction:        direction,
+		OldLevel:         string(result.OldLevel),
+		NewLevel:         overview.Level,
+		OldIncidentLevel: result.OldIncidentLevel,
+		NewIncidentLevel: overview.IncidentLevel,
+		Operator:         request.Operator,
+		IpAddress:        request.IpAddress,
+		Reason:           request.Reason,
+	}
+}
+
+// describeLevel returns the level followed by its incident level, if any
+func describeLevel(level Level, incidentLevel string) string {
+	if incidentLevel == "" {
+		return string(level)
+	}
+	return string(level) + " " + incidentLevel
+}
@@ -0,0 +1,103 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"time"
+)
+
+// Constants representing the direction of a level change recorded in the escalation history.
+const (
+	EscalationDirectionUp   = "escalate"
+	EscalationDirectionDown = "deescalate"
+)
+
+// EscalationHistoryEntry represents a single append-only record of a level change of an event
+type EscalationHistoryEntry struct {
+	ID               int64     `json:"id"`
+	EventNumber      int       `json:"event_number"`
+	CentralID        string    `json:"central_id"`
+	Direction        string    `json:"direction"`
+	OldLevel         string    `json:"old_level"`
+	NewLevel         string    `json:"new_level"`
+	OldIncidentLevel string    `json:"old_incident_level"`
+	NewIncidentLevel string    `json:"new_incident_level"`
+	Operator         string    `json:"operator"`
+	IpAddress        string    `json:"ip_address"`
+	Reason           string    `json:"reason"`
+	TasksAdded       int       `json:"tasks_added"`
+	TasksRemoved     int       `json:"tasks_removed"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// EscalationHistoryRepository represents a read only repository over the escalation history.
+// Entries are written by the EscalationService, inside the same transaction as the level change they record.
+type EscalationHistoryRepository struct {
+	db *sql.DB
+}
+
+// NewEscalationHistoryRepository creates a new instance of EscalationHistoryRepository with the provided database connection.
+func NewEscalationHistoryRepository(db *sql.DB) *EscalationHistoryRepository {
+	return &EscalationHistoryRepository{db: db}
+}
+
+// addEscalationHistoryEntry appends an entry to the escalation_history table using the given transaction.
+// If the entry timestamp is zero, the current time is used. It returns the entry with its ID and timestamp set.
+func addEscalationHistoryEntry(tx *sql.Tx, entry EscalationHistoryEntry) (EscalationHistoryEntry, error) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	result, err := tx.Exec(`INSERT INTO escalation_history (event_number, central_id, direction, old_level, new_level,
+				old_incident_level, new_incident_level, operator, ip_address, reason, tasks_added, tasks_removed, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.EventNumber, entry.CentralID, entry.Direction, entry.OldLevel, entry.NewLevel, entry.OldIncidentLevel,
+		entry.NewIncidentLevel, entry.Operator, entry.IpAddress, entry.Reason, entry.TasksAdded, entry.TasksRemoved,
+		entry.Timestamp)
+	if err != nil {
+		return entry, errors.Wrap(err, "failed to add escalation history entry")
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return entry, errors.Wrap(err, "failed to read escalation history entry id")
+}
+
+// GetByCentralAndNumber retrieves every level change of an event, oldest first.
+func (h *EscalationHistoryRepository) GetByCentralAndNumber(eventNumber int, centralId string) ([]EscalationHistoryEntry, error) {
+	rows, err := h.db.Query(`SELECT id, event_number, central_id, direction, old_level, new_level, old_incident_level,
+				new_incident_level, operator, ip_address, reason, tasks_added, tasks_removed, timestamp
+			FROM escalation_history WHERE central_id = ? AND event_number = ? ORDER BY id`, centralId, eventNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query escalation history")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetByCentralAndNumber")
+	}()
+
+	layout := "2006-01-02 15:04:05.999999-07:00"
+	entries := []EscalationHistoryEntry{}
+
+	for rows.Next() {
+		var tmpTimestamp string // timestamp as string to be scanned to before parsing
+		var entry EscalationHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.EventNumber, &entry.CentralID, &entry.Direction, &entry.OldLevel,
+			&entry.NewLevel, &entry.OldIncidentLevel, &entry.NewIncidentLevel, &entry.Operator, &entry.IpAddress,
+			&entry.Reason, &entry.TasksAdded, &entry.TasksRemoved, &tmpTimestamp); err != nil {
+			return nil, errors.Wrap(err, "failed to scan escalation history row")
+		}
+		if entry.Timestamp, err = time.Parse(layout, tmpTimestamp); err != nil {
+			return nil, errors.Wrap(err, "failed to parse escalation history timestamp")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestEscalationHistory tests that every level change is recorded with its operator, reason and task counts,
+// that failed changes leave no entry and that entries can't be changed afterwards
+func TestEscalationHistory(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+	history := NewEscalationHistoryRepository(repos.Overview.db)
+
+	result, err := service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "verde",
+		Operator: "operator1", IpAddress: "10.0.0.1", Reason: "More patients"})
+	require.NoError(t, err)
+	assert.NotZero(t, result.History.ID)
+	assert.Equal(t, 3, result.History.TasksAdded)
+
+	_, err = service.Deescalate(EscalationRequest{EventNumber: 5, NewLevel: Emergenza, Operator: "supervisor1", Reason: "Fire under control"})
+	require.NoError(t, err)
+
+	// Rejected changes are not recorded
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Allarme, Operator: "operator1"})
+	require.Error(t, err)
+
+	entries, err := history.GetByCentralAndNumber(5, "SRA")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, result.History.ID, entries[0].ID)
+	assert.True(t, result.History.Timestamp.Equal(entries[0].Timestamp))
+	assert.Equal(t, EscalationDirectionUp, entries[0].Direction)
+	assert.Equal(t, string(Allarme), entries[0].OldLevel)
+	assert.Equal(t, string(Incidente), entries[0].NewLevel)
+	assert.Empty(t, entries[0].OldIncidentLevel)
+	assert.Equal(t, "verde", entries[0].NewIncidentLevel)
+	assert.Equal(t, "operator1", entries[0].Operator)
+	assert.Equal(t, "More patients", entries[0].Reason)
+
+	assert.Equal(t, EscalationDirectionDown, entries[1].Direction)
+	assert.Equal(t, string(Incidente), entries[1].OldLevel)
+	assert.Equal(t, "verde", entries[1].OldIncidentLevel)
+	assert.Equal(t, string(Emergenza), entries[1].NewLevel)
+	assert.Empty(t, entries[1].NewIncidentLevel)
+	assert.Equal(t, "supervisor1", entries[1].Operator)
+	assert.Zero(t, entries[1].TasksAdded)
+	assert.Equal(t, 1, entries[1].TasksRemoved)
+
+	_, err = repos.Overview.db.Exec(`UPDATE escalation_history SET reason = 'changed'`)
+	assert.Error(t, err)
+	_, err = repos.Overview.db.Exec(`DELETE FROM escalation_history`)
+	assert.Error(t, err)
+
+	entries, err = history.GetByCentralAndNumber(6, "SRA")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// TestEscalationHistory_Rollback tests that the history entry is rolled back with a failed escalation
+func TestEscalationHistory_Rollback(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+
+	_, err := repos.ActiveEvents.db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON active_events
+			BEGIN SELECT RAISE(ABORT, 'insert failed'); END`)
+	require.NoError(t, err)
+
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Emergenza, Operator: "operator1"})
+	require.Error(t, err)
+
+	entries, err := NewEscalationHistoryRepository(repos.Overview.db).GetByCentralAndNumber(5, "SRA")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
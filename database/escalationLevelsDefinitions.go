@@ -6,13 +6,20 @@ package database
 
 import (
 	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
 	"github.com/google/uuid"
 )
 
+// EscalationLevelsDefinition describes an escalation level.
+// Rank is the position of the level in the escalation ladder, 0 for descriptive only definitions outside the ladder.
+// SubLevels are the sub-levels allowed under the level, from the lowest.
 type EscalationLevelsDefinition struct {
 	UUID        uuid.UUID `json:"uuid"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Rank        int       `json:"rank"`
+	SubLevels   []string  `json:"sub_levels"`
 }
 
 type EscalationLevelsDefinitionRepository struct {
@@ -24,18 +31,67 @@ func NewEscalationLevelsDefinitionRepository(db *sql.DB) *EscalationLevelsDefini
 }
 
 // Add inserts a new EscalationLevelsDefinition record into the database.
-// It uses a SQL query to add the UUID, name, and description to the escalation_levels table.
+// It uses a SQL query to add the UUID, name, description and rank to the escalation_levels table,
+// and the sub-levels to the escalation_sub_levels table, then reloads the level model.
+// The new model is built before committing, so the level is only saved if the model can be reloaded.
 // Returns an error if the execution fails.
-func (eld *EscalationLevelsDefinitionRepository) Add(escalationLevel EscalationLevelsDefinition) error {
-	_, err := eld.db.Exec(`INSERT INTO escalation_levels (uuid, name, description) VALUES (?, ?, ?)`, escalationLevel.UUID, escalationLevel.Name, escalationLevel.Description)
-	return err
+func (eld *EscalationLevelsDefinitionRepository) Add(escalationLevel EscalationLevelsDefinition) (err error) {
+	tx, err := eld.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(`INSERT INTO escalation_levels (uuid, name, description, rank) VALUES (?, ?, ?, ?)`,
+		escalationLevel.UUID, escalationLevel.Name, escalationLevel.Description, escalationLevel.Rank)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert escalation level")
+	}
+
+	for i, subLevel := range escalationLevel.SubLevels {
+		_, err = tx.Exec(`INSERT INTO escalation_sub_levels (level, name, rank) VALUES (?, ?, ?)`, escalationLevel.Name, subLevel, i+1)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert escalation sub-level")
+		}
+	}
+
+	definitions, err := getDefinitions(tx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load escalation levels")
+	}
+	model, err := buildLevelModel(definitions)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	setLevelModel(model)
+	return nil
 }
 
-// GetAll retrieves all escalation levels from the database.
-// It executes a SQL query to fetch the UUID, name, and description of all entries in the escalation_levels table.
+// GetAll retrieves all escalation levels from the database, ordered by rank.
+// It executes a SQL query to fetch the UUID, name, description and rank of all entries in the escalation_levels table,
+// together with their sub-levels.
 // Returns a slice of EscalationLevelsDefinition or an error if the query fails or a row scan encounters an issue.
 func (eld *EscalationLevelsDefinitionRepository) GetAll() ([]EscalationLevelsDefinition, error) {
-	rows, err := eld.db.Query(`SELECT uuid, name, description FROM escalation_levels`)
+	return getDefinitions(eld.db)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// getDefinitions reads all escalation levels with their sub-levels, ordered by rank
+func getDefinitions(q querier) ([]EscalationLevelsDefinition, error) {
+	rows, err := q.Query(`SELECT uuid, name, COALESCE(description, ''), rank FROM escalation_levels ORDER BY rank, name`)
 	if err != nil {
 		return nil, err
 	}
@@ -44,30 +100,105 @@ func (eld *EscalationLevelsDefinitionRepository) GetAll() ([]EscalationLevelsDef
 	var escalationLevels []EscalationLevelsDefinition
 	for rows.Next() {
 		var escalationLevel EscalationLevelsDefinition
-		if err := rows.Scan(&escalationLevel.UUID, &escalationLevel.Name, &escalationLevel.Description); err != nil {
+		if err := rows.Scan(&escalationLevel.UUID, &escalationLevel.Name, &escalationLevel.Description, &escalationLevel.Rank); err != nil {
 			return escalationLevels, err
 		}
 		escalationLevels = append(escalationLevels, escalationLevel)
 	}
+	if err := rows.Err(); err != nil {
+		return escalationLevels, err
+	}
+
+	subLevels, err := getSubLevels(q)
+	if err != nil {
+		return escalationLevels, err
+	}
+	for i := range escalationLevels {
+		escalationLevels[i].SubLevels = subLevels[escalationLevels[i].Name]
+		if escalationLevels[i].SubLevels == nil {
+			escalationLevels[i].SubLevels = []string{}
+		}
+	}
 
 	return escalationLevels, nil
 }
 
 // GetByName retrieves an EscalationLevelsDefinition from the database by its name.
-// It executes a SQL query to fetch the UUID, name, and description where the name matches the provided value.
+// It executes a SQL query to fetch the UUID, name, description and rank where the name matches the provided value.
 // If no row is found or an error occurs during the query, it returns an error.
 func (eld *EscalationLevelsDefinitionRepository) GetByName(name string) (EscalationLevelsDefinition, error) {
-	query := `SELECT uuid, name, description FROM escalation_levels WHERE name = ?`
+	query := `SELECT uuid, name, COALESCE(description, ''), rank FROM escalation_levels WHERE name = ?`
 
 	row := eld.db.QueryRow(query, name)
 
 	var escalationLevel EscalationLevelsDefinition
 
 	// Scan row to return variable
-	err := row.Scan(&escalationLevel.UUID, &escalationLevel.Name, &escalationLevel.Description)
+	err := row.Scan(&escalationLevel.UUID, &escalationLevel.Name, &escalationLevel.Description, &escalationLevel.Rank)
+	if err != nil {
+		return escalationLevel, err
+	}
+
+	subLevels, err := getSubLevels(eld.db)
 	if err != nil {
 		return escalationLevel, err
 	}
+	escalationLevel.SubLevels = subLevels[escalationLevel.Name]
+	if escalationLevel.SubLevels == nil {
+		escalationLevel.SubLevels = []string{}
+	}
 
 	return escalationLevel, nil
 }
+
+// LoadModel reads the ranked escalation levels and their sub-levels and makes them the level model in use.
+// It fails if the ladder is empty, as no event could be opened.
+func (eld *EscalationLevelsDefinitionRepository) LoadModel() (*LevelModel, error) {
+	definitions, err := eld.GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load escalation levels")
+	}
+
+	model, err := buildLevelModel(definitions)
+	if err != nil {
+		return nil, err
+	}
+	setLevelModel(model)
+
+	return model, nil
+}
+
+// buildLevelModel builds the level model from the ranked definitions, it fails if there are none
+func buildLevelModel(definitions []EscalationLevelsDefinition) (*LevelModel, error) {
+	levels := []LevelDefinition{}
+	for _, definition := range definitions {
+		if definition.Rank > 0 {
+			levels = append(levels, LevelDefinition{Name: definition.Name, Rank: definition.Rank, SubLevels: definition.SubLevels})
+		}
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no ranked escalation levels defined")
+	}
+
+	return NewLevelModel(levels), nil
+}
+
+// getSubLevels returns the sub-levels of every level, ordered by rank
+func getSubLevels(q querier) (map[string][]string, error) {
+	rows, err := q.Query(`SELECT level, name FROM escalation_sub_levels ORDER BY level, rank`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subLevels := make(map[string][]string)
+	for rows.Next() {
+		var level, name string
+		if err := rows.Scan(&level, &name); err != nil {
+			return nil, err
+		}
+		subLevels[level] = append(subLevels[level], name)
+	}
+
+	return subLevels, rows.Err()
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestLevelModel tests ranks and sub-levels of the default ladder
+func TestLevelModel(t *testing.T) {
+	model := DefaultLevelModel()
+
+	assert.Equal(t, []string{"allarme", "emergenza", "incidente"}, model.Levels())
+	assert.Equal(t, 1, model.Rank(EscalationAlarm))
+	assert.Equal(t, 3, model.Rank(EscalationIncident))
+	assert.Equal(t, 0, model.Rank("unknown"))
+	assert.False(t, model.IsLevel("unknown"))
+
+	assert.True(t, model.HasSubLevels(EscalationIncident))
+	assert.False(t, model.HasSubLevels(EscalationEmergency))
+	assert.Equal(t, []string{"bianca", "verde", "gialla", "rossa"}, model.SubLevels(EscalationIncident))
+	assert.Equal(t, 4, model.SubRank(EscalationIncident, "rossa"))
+	assert.Equal(t, 0, model.SubRank(EscalationEmergency, "rossa"))
+	assert.True(t, model.IsAnySubLevel("verde"))
+	assert.False(t, model.IsSubLevel(EscalationAlarm, "verde"))
+	assert.Equal(t, []string{EscalationIncident}, model.LevelsWithSubLevels())
+}
+
+// TestEscalationLevelsDefinitionRepository_LoadModel tests the level model seeded by the migrations and
+// that a new level added to the db is used by filtering and accepted by the level constraints
+func TestEscalationLevelsDefinitionRepository_LoadModel(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+	t.Cleanup(func() { setLevelModel(DefaultLevelModel()) })
+
+	repo := NewEscalationLevelsDefinitionRepository(db)
+
+	model, err := repo.LoadModel()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLevelModel().Definitions(), model.Definitions())
+
+	definition, err := repo.GetByName(EscalationIncident)
+	require.NoError(t, err)
+	assert.Equal(t, 3, definition.Rank)
+	assert.Equal(t, []string{"bianca", "verde", "gialla", "rossa"}, definition.SubLevels)
+
+	// Unknown levels are rejected like the CHECK constraints did
+	_, err = db.Exec(`INSERT INTO tasks (priority, title, escalation_level) VALUES (1, 'Task', 'catastrofe')`)
+	assert.Error(t, err)
+	_, err = db.Exec(`INSERT INTO tasks (priority, title, escalation_level, incident_level) VALUES (1, 'Task', 'incidente', 'nera')`)
+	assert.Error(t, err)
+	activeEventsRepo := NewActiveEventRepository(db)
+	assert.Error(t, activeEventsRepo.CreateFromTaskList([]Task{{Priority: 1, Title: "Task", EscalationLevel: "catastrofe"}}, 1, "SRA"))
+
+	// A fourth level only needs a new definition
+	require.NoError(t, repo.Add(EscalationLevelsDefinition{UUID: uuid.New(), Name: "catastrofe", Description: "Catastrofe", Rank: 4}))
+	assert.Equal(t, []string{"allarme", "emergenza", "incidente", "catastrofe"}, GetEscalationLevels())
+
+	_, err = db.Exec(`INSERT INTO tasks (priority, title, escalation_level) VALUES (1, 'Task', 'catastrofe')`)
+	require.NoError(t, err)
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{{Priority: 1, Title: "Task", EscalationLevel: "catastrofe"}}, 1, "SRA"))
+
+	tasks := []Task{
+		{Priority: 1, Title: "Alarm", Category: "cat", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Incident", Category: "cat", EscalationLevel: EscalationIncident, IncidentLevel: "rossa"},
+		{Priority: 3, Title: "Catastrophe", Category: "cat", EscalationLevel: "catastrofe"},
+	}
+	assert.Len(t, FilterTasks(tasks, "cat", "catastrofe", ""), 3)
+	assert.Len(t, FilterTasks(tasks, "cat", EscalationIncident, "rossa"), 2)
+
+	escalated, err := FilterTasksForEscalation(tasks, "cat", EscalationIncident, "catastrofe", "")
+	require.NoError(t, err)
+	require.Len(t, escalated, 1)
+	assert.Equal(t, "Catastrophe", escalated[0].Title)
+
+	// Descriptive definitions are not part of the ladder
+	require.NoError(t, repo.Add(EscalationLevelsDefinition{UUID: uuid.New(), Name: "note", Description: "Not a level"}))
+	assert.False(t, CurrentLevelModel().IsLevel("note"))
+	_, err = db.Exec(`INSERT INTO tasks (priority, title, escalation_level) VALUES (1, 'Task', 'note')`)
+	assert.Error(t, err)
+}
+
+// TestEscalationLevelsDefinitionRepository_Add_ReloadFailure tests that a level is not saved when the model can't be reloaded
+func TestEscalationLevelsDefinitionRepository_Add_ReloadFailure(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+	t.Cleanup(func() { setLevelModel(DefaultLevelModel()) })
+
+	repo := NewEscalationLevelsDefinitionRepository(db)
+
+	// A definition whose uuid can't be scanned makes every reload fail
+	_, err := db.Exec(`INSERT INTO escalation_levels (uuid, name, description, rank) VALUES ('not a uuid', 'broken', '', 0)`)
+	require.NoError(t, err)
+
+	assert.Error(t, repo.Add(EscalationLevelsDefinition{UUID: uuid.New(), Name: "catastrofe", Description: "Catastrofe", Rank: 4}))
+	assert.False(t, CurrentLevelModel().IsLevel("catastrofe"))
+
+	_, err = repo.GetByName("catastrofe")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
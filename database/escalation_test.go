@@ -0,0 +1,236 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// setupEscalationTest creates an event of type fire at level allarme with its first task,
+// and an escalation service over fresh aggregations
+func setupEscalationTest(t *testing.T) (*Repositories, *EscalationService) {
+	db := setupSchemaTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repos := &Repositories{
+		Tasks:                       NewTaskRepository(db),
+		ActiveEvents:                NewActiveEventRepository(db),
+		Overview:                    NewOverviewRepository(db),
+		History:                     NewHistoryRepository(db),
+		TaskCompletionAggregation:   &TaskCompletionMap{Data: map[int]TaskCompletionInfo{5: {Total: 1}}},
+		EscalationLevelsAggregation: NewEscalationLevels(),
+	}
+
+	require.NoError(t, repos.Tasks.BulkAdd(nil, []Task{
+		{Priority: 1, Title: "Alarm task", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Emergency task", Category: "fire", EscalationLevel: EscalationEmergency},
+		{Priority: 3, Title: "Second emergency task", Category: "fire", EscalationLevel: EscalationEmergency},
+		{Priority: 4, Title: "Green task", Category: "fire", EscalationLevel: EscalationIncident, IncidentLevel: "verde"},
+		{Priority: 5, Title: "Red task", Category: "fire", EscalationLevel: EscalationIncident, IncidentLevel: "rossa"},
+	}))
+	require.NoError(t, repos.Overview.Add(&Overview{CentralId: "SRA", EventNumber: 5, Type: "fire", Level: string(Allarme)}))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Alarm task", Category: "fire", EscalationLevel: EscalationAlarm},
+	}, 5, "SRA"))
+	repos.EscalationLevelsAggregation.Add(5, Allarme)
+
+	return repos, NewEscalationService(repos)
+}
+
+// tasksByTitle returns the tasks of an event by title, events without tasks return an empty map
+func tasksByTitle(t *testing.T, repos *Repositories, eventNumber int, centralId string) map[string]ActiveEvents {
+	tasks, err := repos.ActiveEvents.GetByCentralAndNumber(eventNumber, centralId)
+	if _, empty := err.(*NoEventsFoundError); !empty {
+		require.NoError(t, err)
+	}
+
+	byTitle := make(map[string]ActiveEvents, len(tasks))
+	for _, task := range tasks {
+		byTitle[task.Title] = task
+	}
+	return byTitle
+}
+
+// TestEscalationService_Escalate_LocalOverride tests that a local task overrides the main task with the same title
+func TestEscalationService_Escalate_LocalOverride(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+
+	result, err := service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Emergenza, LocalTasks: []Task{
+		{Priority: 2, Title: "Emergency task", Description: "Local procedure", Role: "Local role", Category: "fire", EscalationLevel: EscalationEmergency},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Added)
+
+	tasks := tasksByTitle(t, repos, 5, "SRA")
+	require.Len(t, tasks, 3)
+	assert.Equal(t, "Local procedure", tasks["Emergency task"].Description)
+	assert.Equal(t, "Local role", tasks["Emergency task"].Role)
+}
+
+// TestEscalationService_EscalateAndDeescalate tests that escalations add the tasks of the new levels
+// and de-escalations remove only the notdone ones of the levels left, keeping every other task as is
+func TestEscalationService_EscalateAndDeescalate(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+
+	result, err := service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Emergenza})
+	require.NoError(t, err)
+	assert.Equal(t, Allarme, result.OldLevel)
+	assert.Equal(t, 2, result.Added)
+	assert.Equal(t, string(Emergenza), result.Overview.Level)
+	assert.Equal(t, Emergenza, repos.EscalationLevelsAggregation.GetLevels()[5])
+	assert.Equal(t, 3, repos.TaskCompletionAggregation.Data[5].Total)
+
+	tasks := tasksByTitle(t, repos, 5, "SRA")
+	require.Len(t, tasks, 3)
+	done, err := repos.ActiveEvents.UpdateStatus(tasks["Emergency task"].UUID, TaskDone, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+
+	// Incidente requires an incident colour
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+
+	result, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "verde"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Equal(t, 4, repos.TaskCompletionAggregation.Data[5].Total)
+
+	// Escalations only go up, de-escalations only down
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Allarme})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.Deescalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "rossa"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: "catastrofe"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.Escalate(EscalationRequest{EventNumber: 6, NewLevel: Emergenza})
+	assert.IsType(t, &NoEventsFoundError{}, err)
+
+	before := tasksByTitle(t, repos, 5, "SRA")
+	result, err = service.Deescalate(EscalationRequest{EventNumber: 5, NewLevel: Allarme})
+	require.NoError(t, err)
+	assert.Equal(t, Incidente, result.OldLevel)
+	assert.Equal(t, "verde", result.OldIncidentLevel)
+	assert.Equal(t, 2, result.Removed)
+	assert.Equal(t, Allarme, repos.EscalationLevelsAggregation.GetLevels()[5])
+	assert.Equal(t, 2, repos.TaskCompletionAggregation.Data[5].Total)
+
+	// The done task of the level left and the allarme task are kept with their UUID, status and history
+	after := tasksByTitle(t, repos, 5, "SRA")
+	require.Len(t, after, 2)
+	assert.Equal(t, before["Alarm task"], after["Alarm task"])
+	assert.Equal(t, done.UUID, after["Emergency task"].UUID)
+	assert.Equal(t, TaskDone, after["Emergency task"].Status)
+	assert.Equal(t, "operator1", after["Emergency task"].ModifiedBy)
+
+	overview, err := repos.Overview.GetOverviewById(5)
+	require.NoError(t, err)
+	assert.Equal(t, string(Allarme), overview.Level)
+	assert.Empty(t, overview.IncidentLevel)
+
+	entries, err := repos.History.GetByCentralAndNumber(5, "SRA")
+	require.NoError(t, err)
+	changes := map[string]int{}
+	for _, entry := range entries {
+		changes[entry.ChangeType]++
+	}
+	assert.Equal(t, 4, changes[HistoryCreated])
+	assert.Equal(t, 2, changes[HistoryDeEscalationRemoved])
+	assert.Zero(t, changes[HistoryDeEscalationRebuilt])
+}
+
+// TestEscalationService_Deescalate_IncidentLevel tests that lowering the incident colour removes only the higher colours tasks
+func TestEscalationService_Deescalate_IncidentLevel(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+
+	_, err := service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "rossa"})
+	require.NoError(t, err)
+	require.Len(t, tasksByTitle(t, repos, 5, "SRA"), 5)
+
+	result, err := service.Deescalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "verde"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Removed)
+
+	tasks := tasksByTitle(t, repos, 5, "SRA")
+	assert.Len(t, tasks, 4)
+	assert.NotContains(t, tasks, "Red task")
+}
+
+// TestEscalationService_Escalate_Rollback tests that a failed escalation leaves the db and the aggregations untouched
+func TestEscalationService_Escalate_Rollback(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+
+	// New tasks can't be added, the overview update must be rolled back too
+	_, err := repos.ActiveEvents.db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON active_events
+			BEGIN SELECT RAISE(ABORT, 'insert failed'); END`)
+	require.NoError(t, err)
+
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Emergenza})
+	require.Error(t, err)
+
+	overview, err := repos.Overview.GetOverviewById(5)
+	require.NoError(t, err)
+	assert.Equal(t, string(Allarme), overview.Level)
+	assert.Len(t, tasksByTitle(t, repos, 5, "SRA"), 1)
+	assert.Equal(t, Allarme, repos.EscalationLevelsAggregation.GetLevels()[5])
+	assert.Equal(t, 1, repos.TaskCompletionAggregation.Data[5].Total)
+}
+
+// TestEscalationService_ChangeIncidentLevel tests moving an event between incident levels in both directions,
+// raising it adds only the tasks of the higher colours and lowering it removes only the untouched ones
+func TestEscalationService_ChangeIncidentLevel(t *testing.T) {
+	repos, service := setupEscalationTest(t)
+	require.NoError(t, repos.Tasks.BulkAdd(nil, []Task{
+		{Priority: 6, Title: "Yellow task", Category: "fire", EscalationLevel: EscalationIncident, IncidentLevel: "gialla"},
+	}))
+
+	// Only events at a level with incident levels can change them
+	_, err := service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Allarme, IncidentLevel: "rossa"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+
+	_, err = service.Escalate(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "gialla"})
+	require.NoError(t, err)
+	require.Len(t, tasksByTitle(t, repos, 5, "SRA"), 5)
+
+	yellow, err := repos.ActiveEvents.UpdateStatus(tasksByTitle(t, repos, 5, "SRA")["Yellow task"].UUID, TaskDone, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+
+	result, err := service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "rossa", Operator: "operator1"})
+	require.NoError(t, err)
+	assert.Equal(t, "gialla", result.OldIncidentLevel)
+	assert.Equal(t, 1, result.Added)
+	assert.Zero(t, result.Updated)
+	assert.Equal(t, EscalationDirectionUp, result.History.Direction)
+	assert.Equal(t, "rossa", result.Overview.IncidentLevel)
+	assert.Equal(t, 6, repos.TaskCompletionAggregation.Data[5].Total)
+
+	tasks := tasksByTitle(t, repos, 5, "SRA")
+	require.Len(t, tasks, 6)
+	assert.Equal(t, yellow.UUID, tasks["Yellow task"].UUID)
+	assert.Equal(t, TaskDone, tasks["Yellow task"].Status)
+
+	// Wrong level, unknown or unchanged incident levels are rejected
+	_, err = service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Emergenza, IncidentLevel: "verde"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "nera"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "rossa"})
+	assert.IsType(t, &InvalidEscalationError{}, err)
+	_, err = service.ChangeIncidentLevel(EscalationRequest{EventNumber: 6, NewLevel: Incidente, IncidentLevel: "verde"})
+	assert.IsType(t, &NoEventsFoundError{}, err)
+
+	// The done yellow task is kept, the untouched red one is removed
+	result, err = service.ChangeIncidentLevel(EscalationRequest{EventNumber: 5, NewLevel: Incidente, IncidentLevel: "verde", Operator: "supervisor1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, EscalationDirectionDown, result.History.Direction)
+	assert.Equal(t, 5, repos.TaskCompletionAggregation.Data[5].Total)
+
+	tasks = tasksByTitle(t, repos, 5, "SRA")
+	assert.Len(t, tasks, 5)
+	assert.NotContains(t, tasks, "Red task")
+	assert.Contains(t, tasks, "Yellow task")
+	assert.Equal(t, Incidente, repos.EscalationLevelsAggregation.GetLevels()[5])
+
+	overview, err := repos.Overview.GetOverviewById(5)
+	require.NoError(t, err)
+	assert.Equal(t, "verde", overview.IncidentLevel)
+}
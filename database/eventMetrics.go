@@ -54,13 +54,25 @@ type TaskCompletionMap struct {
 	cm   *broadcast.ConnectionManager
 }
 
-// UpdateEventStatus updates the completion count of a specific event based on the provided status (e.g., "done").
-func (tcm *TaskCompletionMap) UpdateEventStatus(eventNumber int, status string) {
+// Len returns the number of events tracked in the map.
+func (tcm *TaskCompletionMap) Len() int {
+	tcm.mu.RLock()
+	defer tcm.mu.RUnlock()
+
+	return len(tcm.Data)
+}
+
+// UpdateEventStatus updates the completion count of a specific event after one of its tasks changed from oldStatus to
+// newStatus: the count goes up when a task becomes done and down when a done task is reopened, other changes leave it unchanged.
+func (tcm *TaskCompletionMap) UpdateEventStatus(eventNumber int, oldStatus string, newStatus string) {
 	tcm.mu.Lock()
 
 	if data, ok := tcm.Data[eventNumber]; ok {
-		if status == "done" {
+		switch {
+		case oldStatus != TaskDone && newStatus == TaskDone:
 			data.Completed++
+		case oldStatus == TaskDone && newStatus != TaskDone && data.Completed > 0:
+			data.Completed--
 		}
 		tcm.Data[eventNumber] = data
 	}
@@ -89,6 +101,26 @@ func (tcm *TaskCompletionMap) AddMultipleNotDoneTasks(eventNumber int, numberOfT
 	tcm.broadcastUpdate(eventNumber)
 }
 
+// RemoveNotDoneTasks removes the specified number of not done tasks from the total number of tasks
+// for the given event number, never going below the completed ones. If the event number does not exist in the map,
+// no action is taken. This method uses a lock to ensure concurrent-safe access to the map.
+func (tcm *TaskCompletionMap) RemoveNotDoneTasks(eventNumber int, numberOfTasks int) {
+	tcm.mu.Lock()
+
+	if data, ok := tcm.Data[eventNumber]; ok {
+		data.Total -= numberOfTasks
+		if data.Total < data.Completed {
+			data.Total = data.Completed
+		}
+		tcm.Data[eventNumber] = data
+	}
+
+	tcm.mu.Unlock()
+
+	// Broadcast the update
+	tcm.broadcastUpdate(eventNumber)
+}
+
 // AddNewEvent adds a new event to the TaskCompletionMap with the specified
 // event number and the number of tasks. If the event number already exists
 // in the map, no action is taken. This method uses a lock to ensure
@@ -112,6 +144,18 @@ func (tcm *TaskCompletionMap) AddNewEvent(eventNumber int, numberOfTasks int) {
 	tcm.broadcastUpdate(eventNumber)
 }
 
+// SetEvent sets the completion of the specified event number, adding the event
+// to the TaskCompletionMap if it is not tracked yet. This method uses a lock to ensure
+// concurrent-safe access to the map.
+func (tcm *TaskCompletionMap) SetEvent(eventNumber int, info TaskCompletionInfo) {
+	tcm.mu.Lock()
+	tcm.Data[eventNumber] = info
+	tcm.mu.Unlock()
+
+	// Broadcast the update
+	tcm.broadcastUpdate(eventNumber)
+}
+
 // DeleteEvent removes an event from the TaskCompletionMap with the specified
 // event ID. If the event ID does not exist in the map, no action is taken.
 // This method uses a lock to ensure concurrent-safe access to the map.
@@ -158,6 +202,11 @@ func (tcm *TaskCompletionMap) broadcastUpdate(eventNumber int) {
 	}
 	tcm.mu.RUnlock()
 
+	broadcastTaskCompletion(tcm.cm, data)
+}
+
+// broadcastTaskCompletion sends a task_completion_update message with the given data to the TopicTaskCompletionMapUpdate topic
+func broadcastTaskCompletion(cm *broadcast.ConnectionManager, data interface{}) {
 	message := map[string]interface{}{
 		"type": "task_completion_update",
 		"data": data,
@@ -170,7 +219,7 @@ func (tcm *TaskCompletionMap) broadcastUpdate(eventNumber int) {
 		return
 	}
 
-	tcm.cm.BroadcastToTopic(TopicTaskCompletionMapUpdate, jsonData)
+	cm.BroadcastToTopic(TopicTaskCompletionMapUpdate, jsonData)
 }
 
 // GetTaskCompletionMapInstance retrieves the singleton instance of TaskCompletionMap.
@@ -216,11 +265,14 @@ const (
 	Incidente Level = "incidente"
 )
 
-// rankedLevels is used to determine Level priority
-var rankedLevels = map[Level]int{
-	Allarme:   1,
-	Emergenza: 2,
-	Incidente: 3,
+// rank returns the Level priority in the level model, 0 if the level is unknown
+func (l Level) rank() int {
+	return CurrentLevelModel().Rank(string(l))
+}
+
+// valid reports whether the Level is part of the level model
+func (l Level) valid() bool {
+	return CurrentLevelModel().IsLevel(string(l))
 }
 
 // EscalationLevels is a struct type that represents a set of escalation levels for different event numbers.
@@ -258,7 +310,7 @@ func GetEscalationLevelsInstance(data map[int][]Level) *EscalationLevels {
 
 // convertDbResultToData converts the provided DB data into a map of event numbers and levels.
 // It iterates over the dbData slice and retrieves the escalation level for each event.
-// If the escalation level is one of the allowed levels (Allarme, Emergenza, Incidente), it adds it to the map under the respective event number.
+// If the escalation level is one of the levels of the level model, it adds it to the map under the respective event number.
 // If the escalation level is not recognized, it returns an error with a message indicating the unknown event number with associated wrong level.
 // The function returns the resulting map of event numbers and levels, along with any potential error.
 func convertDbResultToData(dbData []ActiveEvents) (map[int][]Level, error) {
@@ -266,16 +318,22 @@ func convertDbResultToData(dbData []ActiveEvents) (map[int][]Level, error) {
 	for _, event := range dbData {
 		level := Level(event.EscalationLevel)
 
-		switch level {
-		case Allarme, Emergenza, Incidente:
-			result[event.EventNumber] = append(result[event.EventNumber], level)
-		default:
+		if !level.valid() {
 			return nil, fmt.Errorf("unknown level: %s for event number: %d", level, event.EventNumber)
 		}
+		result[event.EventNumber] = append(result[event.EventNumber], level)
 	}
 	return result, nil
 }
 
+// Len returns the number of events with an escalation level.
+func (el *EscalationLevels) Len() int {
+	el.mu.RLock()
+	defer el.mu.RUnlock()
+
+	return len(el.Levels)
+}
+
 // GetLevels returns a thread-safe copy of the Levels map, ensuring the original map cannot be altered by the caller.
 func (el *EscalationLevels) GetLevels() map[int]Level {
 	el.mu.RLock()
@@ -297,76 +355,24 @@ func (el *EscalationLevels) Add(eventNumber int, level Level) {
 	defer el.mu.Unlock()
 
 	// Only add if it does not exist or level is higher
-	if existingLevel, ok := el.Levels[eventNumber]; !ok || rankedLevels[level] > rankedLevels[existingLevel] {
+	if existingLevel, ok := el.Levels[eventNumber]; !ok || level.rank() > existingLevel.rank() {
 		el.Levels[eventNumber] = level
 	}
 }
 
-// Remove deletes the escalation level for a specific event number from the Levels map.
-// If the event number is not present in the Levels map, nothing happens.
-func (el *EscalationLevels) Remove(eventNumber int) {
+// Set sets the escalation level of a specific event number, whether it is higher or lower than the existing one.
+func (el *EscalationLevels) Set(eventNumber int, level Level) {
 	el.mu.Lock()
 	defer el.mu.Unlock()
 
-	delete(el.Levels, eventNumber)
+	el.Levels[eventNumber] = level
 }
 
-// Escalate escalates the level of a specific event number in the EscalationLevels struct.
-// It checks if the newLevel is higher than the existing level for the given event number.
-// If it is, the newLevel is updated in the Levels map.
-// If the newLevel is not one of the allowed levels (Allarme, Emergenza, Incidente),
-// an error is returned with a message indicating the invalid level.
-//
-// Parameters:
-// - eventNumber: the number of the event for which the level is being escalated
-// - newLevel: the new level to be escalated to
-//
-// Returns:
-//   - error: an error if the newLevel is not one of the allowed levels
-//     or if the newLevel is not higher than the existing level for the event number
-func (el *EscalationLevels) Escalate(eventNumber int, newLevel Level) error {
-	el.mu.Lock()
-	defer el.mu.Unlock()
-
-	switch newLevel {
-	case Allarme, Emergenza, Incidente:
-		// Only escalate if newLevel is higher
-		if existingLevel, ok := el.Levels[eventNumber]; ok && rankedLevels[newLevel] > rankedLevels[existingLevel] {
-			el.Levels[eventNumber] = newLevel
-		}
-	default:
-		return fmt.Errorf("invalid level provided: %s", newLevel)
-	}
-
-	return nil
-}
-
-// Deescalate deescalates the level of a specific event number in the EscalationLevels struct.
-// It checks if the newLevel is lower than the existing level for the given event number.
-// If it is, the newLevel is updated in the Levels map.
-// If the newLevel is not one of the allowed levels (Allarme, Emergenza, Incidente),
-// an error is returned with a message indicating the invalid level.
-//
-// Parameters:
-// - eventNumber: the number of the event for which the level is being deescalated
-// - newLevel: the new level to be deescalated to
-//
-// Returns:
-//   - error: an error if the newLevel is not one of the allowed levels
-//     or if the newLevel is not lower than the existing level for the event number
-func (el *EscalationLevels) Deescalate(eventNumber int, newLevel Level) error {
+// Remove deletes the escalation level for a specific event number from the Levels map.
+// If the event number is not present in the Levels map, nothing happens.
+func (el *EscalationLevels) Remove(eventNumber int) {
 	el.mu.Lock()
 	defer el.mu.Unlock()
 
-	switch newLevel {
-	case Allarme, Emergenza, Incidente:
-		// Only deescalate if newLevel is lower
-		if existingLevel, ok := el.Levels[eventNumber]; ok && rankedLevels[newLevel] < rankedLevels[existingLevel] {
-			el.Levels[eventNumber] = newLevel
-		}
-	default:
-		return fmt.Errorf("invalid level provided: %s", newLevel)
-	}
-
-	return nil
+	delete(el.Levels, eventNumber)
 }
@@ -0,0 +1,100 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"dogeplus-backend/errors"
+	"dogeplus-backend/metrics"
+	"sort"
+)
+
+// GetOpenEventCentrals retrieves the central of every open event, keyed by event number.
+func (e *ActiveEventsRepository) GetOpenEventCentrals() (map[int]string, error) {
+	rows, err := e.db.Query(`SELECT DISTINCT event_number, COALESCE(central_id, '') FROM active_events`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query open events")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetOpenEventCentrals")
+	}()
+
+	events := make(map[int]string)
+	for rows.Next() {
+		var eventNumber int
+		var centralId string
+		if err := rows.Scan(&eventNumber, &centralId); err != nil {
+			return nil, errors.Wrap(err, "failed to scan open event row")
+		}
+		events[eventNumber] = centralId
+	}
+
+	return events, errors.Wrap(rows.Err(), "error during row iteration")
+}
+
+// CountTasksByStatus counts the active event tasks by central and status.
+func (e *ActiveEventsRepository) CountTasksByStatus() ([]metrics.TaskCount, error) {
+	rows, err := e.db.Query(`SELECT COALESCE(central_id, ''), COALESCE(status, ''), COUNT(*)
+							  FROM active_events
+							  GROUP BY 1, 2
+							  ORDER BY 1, 2`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count tasks by status")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in CountTasksByStatus")
+	}()
+
+	counts := []metrics.TaskCount{}
+	for rows.Next() {
+		var count metrics.TaskCount
+		if err := rows.Scan(&count.CentralID, &count.Status, &count.Count); err != nil {
+			return nil, errors.Wrap(err, "failed to scan task count row")
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, errors.Wrap(rows.Err(), "error during row iteration")
+}
+
+// EventMetricsSnapshot collects the open events per central, the tasks by status
+// and the events per escalation level, as reported on /metrics.
+// Escalation levels come from the in memory EscalationLevelsAggregation, events are mapped to their central from the db.
+func (r *Repositories) EventMetricsSnapshot() (metrics.EventSnapshot, error) {
+	eventCentrals, err := r.ActiveEvents.GetOpenEventCentrals()
+	if err != nil {
+		return metrics.EventSnapshot{}, err
+	}
+
+	tasks, err := r.ActiveEvents.CountTasksByStatus()
+	if err != nil {
+		return metrics.EventSnapshot{}, err
+	}
+
+	openEvents := make(map[string]int)
+	for _, centralId := range eventCentrals {
+		openEvents[centralId]++
+	}
+
+	type centralLevel struct{ central, level string }
+	levelCounts := make(map[centralLevel]int)
+	for eventNumber, level := range r.EscalationLevelsAggregation.GetLevels() {
+		if centralId, ok := eventCentrals[eventNumber]; ok {
+			levelCounts[centralLevel{centralId, string(level)}]++
+		}
+	}
+
+	escalationLevels := make([]metrics.EscalationCount, 0, len(levelCounts))
+	for key, count := range levelCounts {
+		escalationLevels = append(escalationLevels, metrics.EscalationCount{CentralID: key.central, Level: key.level, Count: count})
+	}
+	sort.Slice(escalationLevels, func(i, j int) bool {
+		if escalationLevels[i].CentralID != escalationLevels[j].CentralID {
+			return escalationLevels[i].CentralID < escalationLevels[j].CentralID
+		}
+		return escalationLevels[i].Level < escalationLevels[j].Level
+	})
+
+	return metrics.EventSnapshot{OpenEvents: openEvents, Tasks: tasks, EscalationLevels: escalationLevels}, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"dogeplus-backend/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestRepositories_EventMetricsSnapshot tests the open events, task status and escalation level counts reported on /metrics
+func TestRepositories_EventMetricsSnapshot(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repos := &Repositories{
+		ActiveEvents:                NewActiveEventRepository(db),
+		EscalationLevelsAggregation: NewEscalationLevels(),
+	}
+
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Task 2", EscalationLevel: EscalationAlarm},
+	}, 1, "SRA"))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+	}, 2, "SRA"))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+	}, 3, "SRL"))
+
+	tasks, err := repos.ActiveEvents.GetByCentralAndNumber(1, "SRA")
+	require.NoError(t, err)
+	_, err = repos.ActiveEvents.UpdateStatus(tasks[0].UUID, TaskDone, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+
+	repos.EscalationLevelsAggregation.Add(1, Allarme)
+	repos.EscalationLevelsAggregation.Add(2, Allarme)
+	repos.EscalationLevelsAggregation.Add(3, Incidente)
+	// Levels of events no longer open are ignored
+	repos.EscalationLevelsAggregation.Add(4, Emergenza)
+
+	snapshot, err := repos.EventMetricsSnapshot()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"SRA": 2, "SRL": 1}, snapshot.OpenEvents)
+	assert.Equal(t, []metrics.TaskCount{
+		{CentralID: "SRA", Status: TaskDone, Count: 1},
+		{CentralID: "SRA", Status: TaskNotdone, Count: 2},
+		{CentralID: "SRL", Status: TaskNotdone, Count: 1},
+	}, snapshot.Tasks)
+	assert.Equal(t, []metrics.EscalationCount{
+		{CentralID: "SRA", Level: "allarme", Count: 2},
+		{CentralID: "SRL", Level: "incidente", Count: 1},
+	}, snapshot.EscalationLevels)
+}
@@ -197,132 +197,6 @@ func TestEscalationLevels_Remove(t *testing.T) {
 	}
 }
 
-func TestEscalation(t *testing.T) {
-
-	tests := []struct {
-		name        string
-		eventNum    int
-		initLevel   Level
-		newLevel    Level
-		want        Level
-		expectError bool
-	}{
-		{
-			name:        "Escalate from Allarme to Emergenza",
-			eventNum:    1,
-			initLevel:   Allarme,
-			newLevel:    Emergenza,
-			want:        Emergenza,
-			expectError: false,
-		},
-		{
-			name:        "Escalate from Emergenza to Allarme (Downgrade)",
-			eventNum:    2,
-			initLevel:   Emergenza,
-			newLevel:    Allarme,
-			want:        Emergenza,
-			expectError: false,
-		},
-		{
-			name:        "Escalate from Allarme to Incidente",
-			eventNum:    3,
-			initLevel:   Allarme,
-			newLevel:    Incidente,
-			want:        Incidente,
-			expectError: false,
-		},
-		{
-			name:        "Invalid Escalation",
-			eventNum:    4,
-			initLevel:   Allarme,
-			newLevel:    "Random",
-			want:        Allarme,
-			expectError: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// GetEscalationLevelsInstance() is assumed to be like NewEscalationLevels() only
-			el := NewEscalationLevels()
-			el.Add(tt.eventNum, tt.initLevel)
-
-			err := el.Escalate(tt.eventNum, tt.newLevel)
-			if (err != nil) != tt.expectError {
-				t.Errorf("Escalate() for %v got error = %v, expectError = %v", tt.name, err, tt.expectError)
-			}
-
-			got := el.Levels[tt.eventNum]
-			if got != tt.want {
-				t.Errorf("Escalate() for %v got = %v, want = %v", tt.name, got, tt.want)
-			}
-		})
-	}
-}
-
-func TestDeescalation(t *testing.T) {
-
-	tests := []struct {
-		name        string
-		eventNum    int
-		initLevel   Level
-		newLevel    Level
-		want        Level
-		expectError bool
-	}{
-		{
-			name:        "Deescalate from Emergenza to Allarme",
-			eventNum:    1,
-			initLevel:   Emergenza,
-			newLevel:    Allarme,
-			want:        Allarme,
-			expectError: false,
-		},
-		{
-			name:        "Deescalate from Allarme to Emergenza (Upgrade)",
-			eventNum:    2,
-			initLevel:   Allarme,
-			newLevel:    Emergenza,
-			want:        Allarme,
-			expectError: false,
-		},
-		{
-			name:        "Deescalate from Incidente to Allarme",
-			eventNum:    3,
-			initLevel:   Incidente,
-			newLevel:    Allarme,
-			want:        Allarme,
-			expectError: false,
-		},
-		{
-			name:        "Invalid Deescalation",
-			eventNum:    4,
-			initLevel:   Allarme,
-			newLevel:    "Random",
-			want:        Allarme,
-			expectError: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// GetEscalationLevelsInstance() is assumed to be like NewEscalationLevels() only
-			el := NewEscalationLevels()
-			el.Add(tt.eventNum, tt.initLevel)
-
-			err := el.Deescalate(tt.eventNum, tt.newLevel)
-			if (err != nil) != tt.expectError {
-				t.Errorf("Escalate() for %v got error = %v, expectError = %v", tt.name, err, tt.expectError)
-			}
-
-			got := el.Levels[tt.eventNum]
-			if got != tt.want {
-				t.Errorf("Escalate() for %v got = %v, want = %v", tt.name, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestGetTaskCompletionMapInstance(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -369,67 +243,92 @@ func TestUpdateEventStatus(t *testing.T) {
 	tests := []struct {
 		name         string
 		eventNumber  int
-		status       string
+		oldStatus    string
+		newStatus    string
 		initialData  map[int]TaskCompletionInfo
 		expectedData map[int]TaskCompletionInfo
 	}{
 		{
-			name:        "IncreaseCompletedCount",
-			eventNumber: 1,
-			status:      "done",
-			initialData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
-			expectedData: map[int]TaskCompletionInfo{
-				1: {Completed: 3, Total: 5},
-			},
+			name:         "NotdoneToDone",
+			eventNumber:  1,
+			oldStatus:    TaskNotdone,
+			newStatus:    TaskDone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 3, Total: 5}},
 		},
 		{
-			name:        "DecreaseCompletedCount",
-			eventNumber: 1,
-			status:      "working",
-			initialData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
-			expectedData: map[int]TaskCompletionInfo{
-				1: {Completed: 1, Total: 5},
-			},
+			name:         "WorkingToDone",
+			eventNumber:  1,
+			oldStatus:    TaskWorking,
+			newStatus:    TaskDone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 3, Total: 5}},
 		},
 		{
-			name:        "EventDoesNotExists",
-			eventNumber: 2,
-			status:      "working",
-			initialData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
-			expectedData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
+			name:         "DoneToDone",
+			eventNumber:  1,
+			oldStatus:    TaskDone,
+			newStatus:    TaskDone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
 		},
 		{
-			name:        "StatusNotAllowed",
-			eventNumber: 1,
-			status:      "not allowed",
-			initialData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
-			expectedData: map[int]TaskCompletionInfo{
-				1: {Completed: 2, Total: 5},
-			},
+			name:         "DoneToNotdone",
+			eventNumber:  1,
+			oldStatus:    TaskDone,
+			newStatus:    TaskNotdone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 1, Total: 5}},
+		},
+		{
+			name:         "DoneToWorking",
+			eventNumber:  1,
+			oldStatus:    TaskDone,
+			newStatus:    TaskWorking,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 1, Total: 5}},
+		},
+		{
+			name:         "DoneToNotdoneNeverBelowZero",
+			eventNumber:  1,
+			oldStatus:    TaskDone,
+			newStatus:    TaskNotdone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 0, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 0, Total: 5}},
+		},
+		{
+			name:         "NotdoneToWorking",
+			eventNumber:  1,
+			oldStatus:    TaskNotdone,
+			newStatus:    TaskWorking,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+		},
+		{
+			name:         "EventDoesNotExists",
+			eventNumber:  2,
+			oldStatus:    TaskNotdone,
+			newStatus:    TaskDone,
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+		},
+		{
+			name:         "StatusNotAllowed",
+			eventNumber:  1,
+			oldStatus:    TaskNotdone,
+			newStatus:    "not allowed",
+			initialData:  map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
+			expectedData: map[int]TaskCompletionInfo{1: {Completed: 2, Total: 5}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.name == "DecreaseCompletedCount" {
-				t.Skip("Skipping test as current implementation doesn't support decrementing 'Completed' tasks for status 'working'")
-			}
-
 			tcm := &TaskCompletionMap{
 				Data: tt.initialData,
 			}
 
-			tcm.UpdateEventStatus(tt.eventNumber, tt.status)
+			tcm.UpdateEventStatus(tt.eventNumber, tt.oldStatus, tt.newStatus)
 
 			if !reflect.DeepEqual(tcm.Data, tt.expectedData) {
 				t.Errorf("Expected %+v, but got %+v", tt.expectedData, tcm.Data)
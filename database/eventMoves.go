@@ -0,0 +1,184 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// moveEvent moves the overview, tasks, notes and attachments of an event to another central using the given transaction,
+// and records the transfer of every task in the history. It returns the number of moved tasks.
+func moveEvent(tx *sql.Tx, overview Overview, centralId string, operator string, ipAddress string) (int, error) {
+	tasks, err := snapshotTasks(tx, overview.CentralId, overview.EventNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, table := range []string{"overview", "active_events", "task_notes", "task_attachments"} {
+		_, err = tx.Exec(`UPDATE `+table+` SET central_id = ? WHERE central_id = ? AND event_number = ?`,
+			centralId, overview.CentralId, overview.EventNumber)
+		if err != nil {
+			return 0, fmt.Errorf("failed to transfer %s: %w", table, err)
+		}
+	}
+
+	for _, task := range tasks {
+		err = addHistoryEntry(tx, HistoryEntry{
+			TaskUUID:    task.UUID,
+			EventNumber: overview.EventNumber,
+			CentralID:   centralId,
+			Title:       task.Title,
+			ChangeType:  HistoryTransferred,
+			OldStatus:   task.Status,
+			NewStatus:   task.Status,
+			ModifiedBy:  operator,
+			IpAddress:   ipAddress,
+			Detail:      fmt.Sprintf("%s/%d -> %s/%d", overview.CentralId, overview.EventNumber, centralId, overview.EventNumber),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(tasks), nil
+}
+
+// moveTasks moves the tasks of the source event to the target event using the given transaction, only the ones in only if not nil.
+// A task with the title of a target task is merged into it: the target task keeps the most advanced status of the two,
+// the source task is deleted and its notes and attachments are moved to the target task. Every move is recorded in the history.
+// It returns the number of moved and merged tasks.
+func moveTasks(tx *sql.Tx, source Overview, target Overview, only map[uuid.UUID]bool, operator string, ipAddress string) (moved int, merged int, err error) {
+	sourceTasks, err := snapshotTasks(tx, source.CentralId, source.EventNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	targetTasks, err := snapshotTasks(tx, target.CentralId, target.EventNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	targetByTitle := make(map[string]ActiveEvents, len(targetTasks))
+	for _, task := range targetTasks {
+		targetByTitle[task.Title] = task
+	}
+	detail := fmt.Sprintf("%s/%d -> %s/%d", source.CentralId, source.EventNumber, target.CentralId, target.EventNumber)
+
+	for _, task := range sourceTasks {
+		if only != nil && !only[task.UUID] {
+			continue
+		}
+
+		existing, duplicate := targetByTitle[task.Title]
+		if !duplicate {
+			_, err = tx.Exec(`UPDATE active_events SET event_number = ?, central_id = ? WHERE uuid = ?`,
+				target.EventNumber, target.CentralId, task.UUID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to move event task: %w", err)
+			}
+			if err = moveTaskNotes(tx, task.UUID, task.UUID, target); err != nil {
+				return 0, 0, err
+			}
+
+			err = addHistoryEntry(tx, HistoryEntry{
+				TaskUUID:    task.UUID,
+				EventNumber: target.EventNumber,
+				CentralID:   target.CentralId,
+				Title:       task.Title,
+				ChangeType:  HistoryTransferred,
+				OldStatus:   task.Status,
+				NewStatus:   task.Status,
+				ModifiedBy:  operator,
+				IpAddress:   ipAddress,
+				Detail:      detail,
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			task.EventNumber, task.CentralID = target.EventNumber, target.CentralId
+			targetByTitle[task.Title] = task
+			moved++
+			continue
+		}
+
+		// The target task takes the status, and who set it, of the source task when it is more advanced
+		kept := existing
+		if statusRank(task.Status) > statusRank(existing.Status) {
+			_, err = tx.Exec(`UPDATE active_events SET status = ?, modified_by = ?, ip_address = ?, timestamp = ?,
+					assigned_to = NULLIF(?, ''), assigned_at = ? WHERE uuid = ?`,
+				task.Status, task.ModifiedBy, task.IpAddress, task.Timestamp, task.AssignedTo, nullTime(task.AssignedAt), existing.UUID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to update merged event task: %w", err)
+			}
+			kept.Status, kept.ModifiedBy, kept.IpAddress, kept.Timestamp = task.Status, task.ModifiedBy, task.IpAddress, task.Timestamp
+			kept.AssignedTo, kept.AssignedAt = task.AssignedTo, task.AssignedAt
+		}
+
+		if _, err = tx.Exec(`DELETE FROM active_events WHERE uuid = ?`, task.UUID); err != nil {
+			return 0, 0, fmt.Errorf("failed to delete merged event task: %w", err)
+		}
+		if err = moveTaskNotes(tx, task.UUID, existing.UUID, target); err != nil {
+			return 0, 0, err
+		}
+
+		err = addHistoryEntry(tx, HistoryEntry{
+			TaskUUID:    existing.UUID,
+			EventNumber: target.EventNumber,
+			CentralID:   target.CentralId,
+			Title:       existing.Title,
+			ChangeType:  HistoryMerged,
+			OldStatus:   existing.Status,
+			NewStatus:   kept.Status,
+			ModifiedBy:  operator,
+			IpAddress:   ipAddress,
+			Detail:      fmt.Sprintf("%s merged from %s", task.UUID, detail),
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		targetByTitle[task.Title] = kept
+		merged++
+	}
+
+	return moved, merged, nil
+}
+
+// moveTaskNotes moves the notes and attachments of a task to another task of the target event using the given transaction
+func moveTaskNotes(tx *sql.Tx, from uuid.UUID, to uuid.UUID, target Overview) error {
+	for _, table := range []string{"task_notes", "task_attachments"} {
+		_, err := tx.Exec(`UPDATE `+table+` SET task_uuid = ?, event_number = ?, central_id = ? WHERE task_uuid = ?`,
+			to, target.EventNumber, target.CentralId, from)
+		if err != nil {
+			return fmt.Errorf("failed to move %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// eventCompletion counts the done and total tasks of an event using the given transaction
+func eventCompletion(tx *sql.Tx, eventNumber int) (TaskCompletionInfo, error) {
+	var completion TaskCompletionInfo
+	err := tx.QueryRow(`SELECT COALESCE(SUM(CASE WHEN status = 'done' THEN 1 ELSE 0 END), 0), COUNT(*)
+			FROM active_events WHERE event_number = ?`, eventNumber).Scan(&completion.Completed, &completion.Total)
+
+	return completion, errors.Wrap(err, "failed to count event tasks")
+}
+
+// statusRank ranks task statuses by progress, "notdone" first and "done" last
+func statusRank(status string) int {
+	switch status {
+	case TaskWorking:
+		return 1
+	case TaskDone:
+		return 2
+	default:
+		return 0
+	}
+}
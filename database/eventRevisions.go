@@ -0,0 +1,187 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"sort"
+)
+
+// TaskGroup lists the tasks of an event sharing the same role and category, ordered by priority
+type TaskGroup struct {
+	Role     string         `json:"role"`
+	Category string         `json:"category"`
+	Tasks    []ActiveEvents `json:"tasks"`
+}
+
+// EventSnapshot is the whole state of an open event as read at a given revision.
+// Overview is nil for events opened without an overview.
+type EventSnapshot struct {
+	CentralID       string             `json:"central_id"`
+	EventNumber     int                `json:"event_number"`
+	Revision        int64              `json:"revision"`
+	Overview        *Overview          `json:"overview"`
+	Level           Level              `json:"level"`
+	Completion      TaskCompletionInfo `json:"completion"`
+	CompletionRatio float32            `json:"completion_ratio"`
+	Tasks           []TaskGroup        `json:"tasks"`
+}
+
+// EventRevisionsRepository keeps a monotonically increasing revision for every event.
+// The revision is bumped after every change broadcast to the clients and is sent along with it,
+// so clients holding a snapshot can tell whether a websocket update is newer than their snapshot.
+type EventRevisionsRepository struct {
+	db *sql.DB
+}
+
+// NewEventRevisionsRepository creates a new instance of EventRevisionsRepository with the provided database connection.
+func NewEventRevisionsRepository(db *sql.DB) *EventRevisionsRepository {
+	return &EventRevisionsRepository{db: db}
+}
+
+// Bump increments the revision of an event and returns the new revision, the first revision of an event is 1.
+// It must be called after the change is committed, so a snapshot never holds a revision newer than its data.
+func (r *EventRevisionsRepository) Bump(centralId string, eventNumber int) (int64, error) {
+	var revision int64
+	err := r.db.QueryRow(`INSERT INTO event_revisions (central_id, event_number, revision) VALUES (?, ?, 1)
+			ON CONFLICT (central_id, event_number) DO UPDATE SET revision = revision + 1
+			RETURNING revision`, centralId, eventNumber).Scan(&revision)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to bump event revision")
+	}
+
+	return revision, nil
+}
+
+// GetRevision returns the current revision of an event, 0 for events never changed.
+func (r *EventRevisionsRepository) GetRevision(centralId string, eventNumber int) (int64, error) {
+	return getRevision(r.db, centralId, eventNumber)
+}
+
+// GetSnapshot reads the overview, the tasks and the revision of an open event in a single transaction.
+// The revision is read first, a snapshot may include changes newer than its revision but never miss older ones.
+// The level is the overview one, events without an overview report the highest level of their tasks.
+// It returns a NoEventsFoundError if neither tasks nor overview exist for the given central ID and event number.
+func (r *EventRevisionsRepository) GetSnapshot(centralId string, eventNumber int) (snapshot EventSnapshot, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return EventSnapshot{}, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		// Read only transaction, nothing to commit
+		_ = tx.Rollback()
+	}()
+
+	snapshot = EventSnapshot{CentralID: centralId, EventNumber: eventNumber, Tasks: []TaskGroup{}}
+
+	if snapshot.Revision, err = getRevision(tx, centralId, eventNumber); err != nil {
+		return EventSnapshot{}, err
+	}
+
+	var overview Overview
+	err = tx.QueryRow(`SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level
+			FROM overview WHERE central_id = ? AND event_number = ?`, centralId, eventNumber).
+		Scan(&overview.UUID, &overview.CentralId, &overview.EventNumber, &overview.Location, &overview.LocationDetail,
+			&overview.Type, &overview.Level, &overview.IncidentLevel)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return EventSnapshot{}, errors.Wrap(err, "failed to get event overview")
+	default:
+		snapshot.Overview = &overview
+	}
+
+	tasks, err := snapshotTasks(tx, centralId, eventNumber)
+	if err != nil {
+		return EventSnapshot{}, err
+	}
+
+	if snapshot.Overview == nil && len(tasks) == 0 {
+		return EventSnapshot{}, &NoEventsFoundError{Detail: "No events found for specified centralId and event number"}
+	}
+
+	levels := NewEscalationLevels()
+	groups := make(map[[2]string]int)
+	for _, task := range tasks {
+		if task.Status == TaskDone {
+			snapshot.Completion.Completed++
+		}
+		snapshot.Completion.Total++
+
+		if Level(task.EscalationLevel).valid() {
+			levels.Add(eventNumber, Level(task.EscalationLevel))
+		}
+
+		key := [2]string{task.Role, task.Category}
+		index, ok := groups[key]
+		if !ok {
+			index = len(snapshot.Tasks)
+			groups[key] = index
+			snapshot.Tasks = append(snapshot.Tasks, TaskGroup{Role: task.Role, Category: task.Category})
+		}
+		snapshot.Tasks[index].Tasks = append(snapshot.Tasks[index].Tasks, task)
+	}
+	snapshot.CompletionRatio = snapshot.Completion.Ratio()
+
+	snapshot.Level = levels.Levels[eventNumber]
+	if snapshot.Overview != nil {
+		if Level(snapshot.Overview.Level).valid() {
+			snapshot.Level = Level(snapshot.Overview.Level)
+		}
+	}
+
+	sort.SliceStable(snapshot.Tasks, func(i, j int) bool {
+		if snapshot.Tasks[i].Role != snapshot.Tasks[j].Role {
+			return snapshot.Tasks[i].Role < snapshot.Tasks[j].Role
+		}
+		return snapshot.Tasks[i].Category < snapshot.Tasks[j].Category
+	})
+
+	return snapshot, nil
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getRevision returns the current revision of an event, 0 for events never changed
+func getRevision(q queryRower, centralId string, eventNumber int) (int64, error) {
+	var revision int64
+	err := q.QueryRow(`SELECT revision FROM event_revisions WHERE central_id = ? AND event_number = ?`, centralId, eventNumber).
+		Scan(&revision)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get event revision")
+	}
+
+	return revision, nil
+}
+
+// snapshotTasks reads the tasks of an event ordered by priority using the given transaction
+func snapshotTasks(tx *sql.Tx, centralId string, eventNumber int) ([]ActiveEvents, error) {
+	rows, err := tx.Query(`SELECT `+activeEventColumns+` FROM active_events WHERE central_id = ? AND event_number = ?
+			ORDER BY priority`, centralId, eventNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query event tasks")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in snapshotTasks")
+	}()
+
+	tasks := []ActiveEvents{}
+	for rows.Next() {
+		task, err := scanActiveEvent(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan event task row")
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, errors.Wrap(rows.Err(), "error during row iteration")
+}
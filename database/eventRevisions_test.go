@@ -0,0 +1,90 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestEventRevisionsRepository_Bump tests that revisions start at 1 and increase independently for every event
+func TestEventRevisionsRepository_Bump(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	revisionsRepo := NewEventRevisionsRepository(db)
+
+	revision, err := revisionsRepo.GetRevision("SRA", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), revision)
+
+	for want := int64(1); want <= 3; want++ {
+		revision, err = revisionsRepo.Bump("SRA", 1)
+		require.NoError(t, err)
+		assert.Equal(t, want, revision)
+	}
+
+	revision, err = revisionsRepo.Bump("SRL", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revision)
+
+	revision, err = revisionsRepo.GetRevision("SRA", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), revision)
+}
+
+// TestEventRevisionsRepository_GetSnapshot tests the grouping, completion and level of an event snapshot
+func TestEventRevisionsRepository_GetSnapshot(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	activeEventsRepo := NewActiveEventRepository(db)
+	overviewRepo := NewOverviewRepository(db)
+	revisionsRepo := NewEventRevisionsRepository(db)
+
+	_, err := revisionsRepo.GetSnapshot("SRA", 1)
+	assert.IsType(t, &NoEventsFoundError{}, err)
+
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{
+		{Priority: 3, Title: "Task 3", Role: "medico", Category: "generale", EscalationLevel: EscalationEmergency},
+		{Priority: 1, Title: "Task 1", Role: "infermiere", Category: "generale", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Task 2", Role: "infermiere", Category: "incendio", EscalationLevel: EscalationAlarm},
+		{Priority: 4, Title: "Task 4", Role: "infermiere", Category: "generale", EscalationLevel: EscalationAlarm},
+	}, 1, "SRA"))
+
+	tasks, err := activeEventsRepo.GetByCentralAndNumber(1, "SRA")
+	require.NoError(t, err)
+	for _, task := range tasks {
+		if task.Title == "Task 1" {
+			_, err = activeEventsRepo.UpdateStatus(task.UUID, TaskDone, "operator1", "10.0.0.1")
+			require.NoError(t, err)
+		}
+	}
+	_, err = revisionsRepo.Bump("SRA", 1)
+	require.NoError(t, err)
+
+	// Without overview the level is the highest one of the tasks
+	snapshot, err := revisionsRepo.GetSnapshot("SRA", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), snapshot.Revision)
+	assert.Nil(t, snapshot.Overview)
+	assert.Equal(t, Emergenza, snapshot.Level)
+	assert.Equal(t, TaskCompletionInfo{Completed: 1, Total: 4}, snapshot.Completion)
+	assert.Equal(t, float32(0.25), snapshot.CompletionRatio)
+
+	require.Len(t, snapshot.Tasks, 3)
+	assert.Equal(t, "infermiere", snapshot.Tasks[0].Role)
+	assert.Equal(t, "generale", snapshot.Tasks[0].Category)
+	require.Len(t, snapshot.Tasks[0].Tasks, 2)
+	assert.Equal(t, "Task 1", snapshot.Tasks[0].Tasks[0].Title)
+	assert.Equal(t, "Task 4", snapshot.Tasks[0].Tasks[1].Title)
+	assert.Equal(t, "incendio", snapshot.Tasks[1].Category)
+	assert.Equal(t, "medico", snapshot.Tasks[2].Role)
+
+	// The overview level follows de-escalations and wins over the tasks levels
+	require.NoError(t, overviewRepo.Add(&Overview{CentralId: "SRA", EventNumber: 1, Type: "generale", Level: string(Allarme)}))
+	snapshot, err = revisionsRepo.GetSnapshot("SRA", 1)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot.Overview)
+	assert.Equal(t, "generale", snapshot.Overview.Type)
+	assert.Equal(t, Allarme, snapshot.Level)
+}
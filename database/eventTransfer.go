@@ -0,0 +1,331 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type InvalidTransferError struct {
+	Detail string
+}
+
+func (e InvalidTransferError) Error() string {
+	return fmt.Sprintf("invalid transfer: %s", e.Detail)
+}
+
+// MergeRequest describes an event merged into another one, the merged event is closed once its tasks are moved.
+// CentralId, when set, must be the central of the merged event. Operator and IpAddress are recorded in the history of the moved tasks.
+type MergeRequest struct {
+	CentralId         string
+	EventNumber       int
+	TargetEventNumber int
+	Operator          string
+	IpAddress         string
+}
+
+// TransferRequest describes an event, or some of its tasks, moved to another central.
+// Without TaskUUIDs the whole event is moved keeping its number. Otherwise only those tasks are moved to TargetEventNumber,
+// an open event of the target central or a new one created from the overview of the source event.
+// CentralId, when set, must be the central of the source event. Operator and IpAddress are recorded in the history of the moved tasks.
+type TransferRequest struct {
+	CentralId         string
+	EventNumber       int
+	TargetCentralId   string
+	TargetEventNumber int
+	TaskUUIDs         []uuid.UUID
+	Operator          string
+	IpAddress         string
+}
+
+// TransferResult reports the changes applied by a merge or a transfer.
+// Source is the overview of the source event before the change, Target the overview of the event the tasks were moved to.
+type TransferResult struct {
+	Source        Overview `json:"source"`
+	Target        Overview `json:"target"`
+	SourceClosed  bool     `json:"source_closed"`
+	TargetCreated bool     `json:"target_created"`
+	Moved         int      `json:"moved"`
+	Merged        int      `json:"merged"`
+}
+
+// EventTransferService merges events and transfers them, or some of their tasks, between centrals.
+// Every operation runs in a single transaction, the in memory TaskCompletionMap and EscalationLevels aggregations
+// of the events involved are updated only once it is committed.
+type EventTransferService struct {
+	mu               sync.Mutex
+	db               *sql.DB
+	taskCompletion   *TaskCompletionMap
+	escalationLevels *EscalationLevels
+}
+
+// NewEventTransferService creates an EventTransferService over the db and the aggregations of repos.
+func NewEventTransferService(repos *Repositories) *EventTransferService {
+	return &EventTransferService{
+		db:               repos.ActiveEvents.db,
+		taskCompletion:   repos.TaskCompletionAggregation,
+		escalationLevels: repos.EscalationLevelsAggregation,
+	}
+}
+
+// Merge moves every task of an event into the target event, whatever their centrals.
+// Tasks with the same title are merged into the target one, which keeps the most advanced status of the two,
+// the notes and attachments of the merged tasks follow them. The target event takes the higher level of the two,
+// the merged event overview is archived with the target event as closure reason.
+// It returns an InvalidTransferError if the events are the same, and a NoEventsFoundError if any has no overview.
+func (s *EventTransferService) Merge(request MergeRequest) (result TransferResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request.EventNumber == request.TargetEventNumber {
+		return result, &InvalidTransferError{Detail: fmt.Sprintf("event %d can't be merged into itself", request.EventNumber)}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if result.Source, err = readSourceOverview(tx, request.CentralId, request.EventNumber); err != nil {
+		return result, err
+	}
+	if result.Target, err = readOverview(tx, request.TargetEventNumber); err != nil {
+		return result, err
+	}
+
+	result.Moved, result.Merged, err = moveTasks(tx, result.Source, result.Target, nil, request.Operator, request.IpAddress)
+	if err != nil {
+		return result, err
+	}
+
+	// The merged event keeps the higher level of the two
+	if compareLevels(result.Source, result.Target) > 0 {
+		_, err = tx.Exec(`UPDATE overview SET level = ?, incident_level = ? WHERE uuid = ?`,
+			result.Source.Level, result.Source.IncidentLevel, result.Target.UUID)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to update overview level")
+		}
+		result.Target.Level = result.Source.Level
+		result.Target.IncidentLevel = result.Source.IncidentLevel
+	}
+
+	// The merged event is closed, its history stays under its own number
+	_, err = tx.Exec(`INSERT INTO archived_overview (uuid, central_id, event_number, location, location_detail, type,
+				level, incident_level, closed_at, closed_by, closure_reason)
+			SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level, ?, ?, ?
+			FROM overview WHERE uuid = ?`,
+		time.Now(), request.Operator, fmt.Sprintf("Merged into event %d", result.Target.EventNumber), result.Source.UUID)
+	if err != nil {
+		return result, fmt.Errorf("failed to archive event overview: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM overview WHERE uuid = ?`, result.Source.UUID); err != nil {
+		return result, fmt.Errorf("failed to delete event overview: %w", err)
+	}
+	result.SourceClosed = true
+
+	targetCompletion, err := eventCompletion(tx, result.Target.EventNumber)
+	if err != nil {
+		return result, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Keep in memory aggregations in sync now that the merge is committed
+	s.taskCompletion.DeleteEvent(result.Source.EventNumber)
+	s.escalationLevels.Remove(result.Source.EventNumber)
+	s.setCompletion(result.Target.EventNumber, targetCompletion)
+	s.escalationLevels.Set(result.Target.EventNumber, Level(result.Target.Level))
+
+	return result, nil
+}
+
+// Transfer moves an event, or some of its tasks, to another central.
+// A whole event keeps its number, tasks, notes and attachments. Some of its tasks are moved to the target event instead,
+// merged by title with its tasks like Merge does, and the target event is created if it is not open yet.
+// It returns an InvalidTransferError if the request doesn't describe a transfer, the target event belongs to another central
+// or a task is not part of the source event, and a NoEventsFoundError if the source event has no overview.
+func (s *EventTransferService) Transfer(request TransferRequest) (result TransferResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partial := len(request.TaskUUIDs) > 0
+	switch {
+	case request.TargetCentralId == "":
+		err = &InvalidTransferError{Detail: "the target central is required"}
+	case !partial && request.TargetEventNumber != 0 && request.TargetEventNumber != request.EventNumber:
+		err = &InvalidTransferError{Detail: "a whole event keeps its number, merge it to move its tasks to another event"}
+	case partial && request.TargetEventNumber == 0:
+		err = &InvalidTransferError{Detail: "the target event number is required to transfer tasks"}
+	case partial && request.TargetEventNumber == request.EventNumber:
+		err = &InvalidTransferError{Detail: fmt.Sprintf("tasks can't be transferred to their own event %d", request.EventNumber)}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if result.Source, err = readSourceOverview(tx, request.CentralId, request.EventNumber); err != nil {
+		return result, err
+	}
+
+	if !partial {
+		if result.Source.CentralId == request.TargetCentralId {
+			err = &InvalidTransferError{Detail: fmt.Sprintf("event %d already belongs to central %s", request.EventNumber, request.TargetCentralId)}
+			return result, err
+		}
+
+		result.Target = result.Source
+		result.Target.CentralId = request.TargetCentralId
+		result.Moved, err = moveEvent(tx, result.Source, request.TargetCentralId, request.Operator, request.IpAddress)
+		if err != nil {
+			return result, err
+		}
+
+		// The event number doesn't change, neither do the aggregations
+		if err = tx.Commit(); err != nil {
+			return result, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return result, nil
+	}
+
+	// Only the requested tasks of the source event are moved
+	sourceTasks, err := snapshotTasks(tx, result.Source.CentralId, result.Source.EventNumber)
+	if err != nil {
+		return result, err
+	}
+	sourceUUIDs := make(map[uuid.UUID]bool, len(sourceTasks))
+	for _, task := range sourceTasks {
+		sourceUUIDs[task.UUID] = true
+	}
+	only := make(map[uuid.UUID]bool, len(request.TaskUUIDs))
+	for _, taskUUID := range request.TaskUUIDs {
+		if !sourceUUIDs[taskUUID] {
+			err = &InvalidTransferError{Detail: fmt.Sprintf("task %s is not a task of event %d", taskUUID, request.EventNumber)}
+			return result, err
+		}
+		only[taskUUID] = true
+	}
+
+	result.Target, err = readOverview(tx, request.TargetEventNumber)
+	switch err.(type) {
+	case nil:
+		if result.Target.CentralId != request.TargetCentralId {
+			err = &InvalidTransferError{Detail: fmt.Sprintf("event %d belongs to central %s", request.TargetEventNumber, result.Target.CentralId)}
+			return result, err
+		}
+	case *NoEventsFoundError:
+		// The split event starts from the overview of the source event
+		result.Target = result.Source
+		result.Target.UUID = uuid.New()
+		result.Target.CentralId = request.TargetCentralId
+		result.Target.EventNumber = request.TargetEventNumber
+		_, err = tx.Exec(`INSERT INTO overview (uuid, central_id, event_number, location, location_detail, type, level, incident_level)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			result.Target.UUID, result.Target.CentralId, result.Target.EventNumber, result.Target.Location,
+			result.Target.LocationDetail, result.Target.Type, result.Target.Level, result.Target.IncidentLevel)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to add overview")
+		}
+		result.TargetCreated = true
+	default:
+		return result, err
+	}
+
+	result.Moved, result.Merged, err = moveTasks(tx, result.Source, result.Target, only, request.Operator, request.IpAddress)
+	if err != nil {
+		return result, err
+	}
+
+	sourceCompletion, err := eventCompletion(tx, result.Source.EventNumber)
+	if err != nil {
+		return result, err
+	}
+	targetCompletion, err := eventCompletion(tx, result.Target.EventNumber)
+	if err != nil {
+		return result, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Keep in memory aggregations in sync now that the transfer is committed
+	s.setCompletion(result.Source.EventNumber, sourceCompletion)
+	s.setCompletion(result.Target.EventNumber, targetCompletion)
+	s.escalationLevels.Set(result.Target.EventNumber, Level(result.Target.Level))
+
+	return result, nil
+}
+
+// setCompletion sets the completion of an event in the TaskCompletionMap, events without tasks are not tracked
+func (s *EventTransferService) setCompletion(eventNumber int, completion TaskCompletionInfo) {
+	if completion.Total == 0 {
+		s.taskCompletion.DeleteEvent(eventNumber)
+		return
+	}
+	s.taskCompletion.SetEvent(eventNumber, completion)
+}
+
+// readOverview reads the overview of an event using the given transaction.
+// It returns a NoEventsFoundError if the event has no overview.
+func readOverview(tx *sql.Tx, eventNumber int) (Overview, error) {
+	var overview Overview
+	err := tx.QueryRow(`SELECT uuid, central_id, event_number, location, location_detail, type, level, incident_level
+			FROM overview WHERE event_number = ?`, eventNumber).
+		Scan(&overview.UUID, &overview.CentralId, &overview.EventNumber, &overview.Location, &overview.LocationDetail,
+			&overview.Type, &overview.Level, &overview.IncidentLevel)
+	if err == sql.ErrNoRows {
+		return Overview{}, &NoEventsFoundError{Detail: fmt.Sprintf("No overview found for event number %d", eventNumber)}
+	}
+
+	return overview, errors.Wrap(err, "failed to read event overview")
+}
+
+// readSourceOverview reads the overview of the event merged or transferred using the given transaction.
+// It returns a NoEventsFoundError if the event has no overview, or it doesn't belong to centralId when set.
+func readSourceOverview(tx *sql.Tx, centralId string, eventNumber int) (Overview, error) {
+	overview, err := readOverview(tx, eventNumber)
+	if err == nil && centralId != "" && overview.CentralId != centralId {
+		return Overview{}, &NoEventsFoundError{Detail: fmt.Sprintf("No overview found for event number %d of central %s", eventNumber, centralId)}
+	}
+
+	return overview, err
+}
+
+// compareLevels compares the levels of two events, and their incident levels when the level is the same.
+// It returns a positive number if a is higher than b, a negative one if it is lower and 0 if they are the same.
+func compareLevels(a Overview, b Overview) int {
+	if rankA, rankB := Level(a.Level).rank(), Level(b.Level).rank(); rankA != rankB {
+		return rankA - rankB
+	}
+
+	levelModel := CurrentLevelModel()
+	return levelModel.SubRank(a.Level, a.IncidentLevel) - levelModel.SubRank(b.Level, b.IncidentLevel)
+}
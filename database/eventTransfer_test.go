@@ -0,0 +1,175 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// setupTransferTest creates event 5 of central SRA at level allarme and event 7 of central SRL at level emergenza,
+// both with tasks titled "Shared" and "Both", and a transfer service over fresh aggregations
+func setupTransferTest(t *testing.T) (*Repositories, *EventTransferService) {
+	db := setupSchemaTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	repos := &Repositories{
+		ActiveEvents:                NewActiveEventRepository(db),
+		Overview:                    NewOverviewRepository(db),
+		History:                     NewHistoryRepository(db),
+		Notes:                       NewNotesRepository(db),
+		Archive:                     NewArchiveRepository(db),
+		TaskCompletionAggregation:   &TaskCompletionMap{Data: map[int]TaskCompletionInfo{5: {Total: 2}, 7: {Total: 3}}},
+		EscalationLevelsAggregation: NewEscalationLevels(),
+	}
+
+	require.NoError(t, repos.Overview.Add(&Overview{CentralId: "SRA", EventNumber: 5, Location: "Milano", Type: "fire", Level: string(Allarme)}))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Shared", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Both", Category: "fire", EscalationLevel: EscalationAlarm},
+	}, 5, "SRA"))
+	require.NoError(t, repos.Overview.Add(&Overview{CentralId: "SRL", EventNumber: 7, Location: "Lecco", Type: "fire", Level: string(Emergenza)}))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Shared", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Both", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 3, Title: "Only seven", Category: "fire", EscalationLevel: EscalationEmergency},
+	}, 7, "SRL"))
+	repos.EscalationLevelsAggregation.Add(5, Allarme)
+	repos.EscalationLevelsAggregation.Add(7, Emergenza)
+
+	return repos, NewEventTransferService(repos)
+}
+
+// TestEventTransferService_Merge tests that merging keeps a task per title with the most advanced status,
+// moves notes along and closes the merged event keeping the aggregations in sync
+func TestEventTransferService_Merge(t *testing.T) {
+	repos, service := setupTransferTest(t)
+
+	target, source := tasksByTitle(t, repos, 5, "SRA"), tasksByTitle(t, repos, 7, "SRL")
+	_, err := repos.ActiveEvents.UpdateStatus(target["Shared"].UUID, TaskDone, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+	_, err = repos.ActiveEvents.UpdateStatus(source["Both"].UUID, TaskDone, "operator2", "10.0.0.2")
+	require.NoError(t, err)
+	note, err := repos.Notes.AddNote(source["Both"].UUID, "operator2", "Road closed")
+	require.NoError(t, err)
+
+	_, err = service.Merge(MergeRequest{EventNumber: 5, TargetEventNumber: 5})
+	assert.IsType(t, &InvalidTransferError{}, err)
+	_, err = service.Merge(MergeRequest{EventNumber: 8, TargetEventNumber: 5})
+	assert.IsType(t, &NoEventsFoundError{}, err)
+
+	result, err := service.Merge(MergeRequest{EventNumber: 7, TargetEventNumber: 5, Operator: "supervisor1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Moved)
+	assert.Equal(t, 2, result.Merged)
+	assert.True(t, result.SourceClosed)
+	assert.Equal(t, "SRL", result.Source.CentralId)
+	assert.Equal(t, string(Emergenza), result.Target.Level)
+
+	// The target tasks keep their UUID and the most advanced status of the two
+	merged := tasksByTitle(t, repos, 5, "SRA")
+	require.Len(t, merged, 3)
+	assert.Equal(t, target["Shared"].UUID, merged["Shared"].UUID)
+	assert.Equal(t, TaskDone, merged["Shared"].Status)
+	assert.Equal(t, target["Both"].UUID, merged["Both"].UUID)
+	assert.Equal(t, TaskDone, merged["Both"].Status)
+	assert.Equal(t, "operator2", merged["Both"].ModifiedBy)
+	assert.Equal(t, source["Only seven"].UUID, merged["Only seven"].UUID)
+	assert.Empty(t, tasksByTitle(t, repos, 7, "SRL"))
+
+	notes, err := repos.Notes.GetNotesByTask(target["Both"].UUID)
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	assert.Equal(t, note.ID, notes[0].ID)
+	assert.Equal(t, 5, notes[0].EventNumber)
+	assert.Equal(t, "SRA", notes[0].CentralID)
+
+	overview, err := repos.Overview.GetOverviewById(5)
+	require.NoError(t, err)
+	assert.Equal(t, string(Emergenza), overview.Level)
+	_, err = repos.Overview.GetOverviewById(7)
+	assert.Error(t, err)
+	archived, err := repos.Archive.GetOverviewsByCentralIdAndEventNumber("SRL", 7)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, "Merged into event 5", archived[0].ClosureReason)
+
+	assert.Equal(t, TaskCompletionInfo{Completed: 2, Total: 3}, repos.TaskCompletionAggregation.Data[5])
+	assert.NotContains(t, repos.TaskCompletionAggregation.Data, 7)
+	assert.Equal(t, map[int]Level{5: Emergenza}, repos.EscalationLevelsAggregation.GetLevels())
+
+	entries, err := repos.History.GetByCentralAndNumber(5, "SRA")
+	require.NoError(t, err)
+	changes := map[string]int{}
+	for _, entry := range entries {
+		changes[entry.ChangeType]++
+	}
+	assert.Equal(t, 2, changes[HistoryMerged])
+	assert.Equal(t, 1, changes[HistoryTransferred])
+}
+
+// TestEventTransferService_Transfer tests moving a whole event to another central and splitting some of its tasks
+// into a new event of another central
+func TestEventTransferService_Transfer(t *testing.T) {
+	repos, service := setupTransferTest(t)
+
+	tasks := tasksByTitle(t, repos, 7, "SRL")
+	note, err := repos.Notes.AddNote(tasks["Shared"].UUID, "operator1", "On site")
+	require.NoError(t, err)
+
+	_, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRL"})
+	assert.IsType(t, &InvalidTransferError{}, err)
+	_, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRM", TargetEventNumber: 5})
+	assert.IsType(t, &InvalidTransferError{}, err)
+
+	result, err := service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRM", Operator: "supervisor1"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Moved)
+	assert.Equal(t, "SRL", result.Source.CentralId)
+	assert.Equal(t, "SRM", result.Target.CentralId)
+	assert.Equal(t, 7, result.Target.EventNumber)
+
+	overview, err := repos.Overview.GetOverviewById(7)
+	require.NoError(t, err)
+	assert.Equal(t, "SRM", overview.CentralId)
+	assert.Empty(t, tasksByTitle(t, repos, 7, "SRL"))
+	moved := tasksByTitle(t, repos, 7, "SRM")
+	require.Len(t, moved, 3)
+	assert.Equal(t, tasks["Shared"].UUID, moved["Shared"].UUID)
+	notes, err := repos.Notes.GetNotesByEvent(7, "SRM")
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	assert.Equal(t, note.ID, notes[0].ID)
+	assert.Equal(t, TaskCompletionInfo{Total: 3}, repos.TaskCompletionAggregation.Data[7])
+
+	// Tasks need an event of the target central, or a new one
+	_, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRM", TargetEventNumber: 5, TaskUUIDs: []uuid.UUID{tasks["Shared"].UUID}})
+	assert.IsType(t, &InvalidTransferError{}, err)
+	_, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRP", TargetEventNumber: 9, TaskUUIDs: []uuid.UUID{uuid.New()}})
+	assert.IsType(t, &InvalidTransferError{}, err)
+
+	result, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRP", TargetEventNumber: 9,
+		TaskUUIDs: []uuid.UUID{tasks["Shared"].UUID, tasks["Only seven"].UUID}, Operator: "supervisor1"})
+	require.NoError(t, err)
+	assert.True(t, result.TargetCreated)
+	assert.Equal(t, 2, result.Moved)
+	assert.Equal(t, "Lecco", result.Target.Location)
+	assert.Equal(t, string(Emergenza), result.Target.Level)
+
+	assert.Len(t, tasksByTitle(t, repos, 7, "SRM"), 1)
+	split := tasksByTitle(t, repos, 9, "SRP")
+	require.Len(t, split, 2)
+	assert.Equal(t, tasks["Only seven"].UUID, split["Only seven"].UUID)
+
+	assert.Equal(t, TaskCompletionInfo{Total: 1}, repos.TaskCompletionAggregation.Data[7])
+	assert.Equal(t, TaskCompletionInfo{Total: 2}, repos.TaskCompletionAggregation.Data[9])
+	assert.Equal(t, Emergenza, repos.EscalationLevelsAggregation.GetLevels()[9])
+
+	// Moving the last task leaves the source event open without tasks
+	result, err = service.Transfer(TransferRequest{EventNumber: 7, TargetCentralId: "SRP", TargetEventNumber: 9,
+		TaskUUIDs: []uuid.UUID{tasks["Both"].UUID}})
+	require.NoError(t, err)
+	assert.False(t, result.TargetCreated)
+	assert.NotContains(t, repos.TaskCompletionAggregation.Data, 7)
+	assert.Equal(t, TaskCompletionInfo{Total: 3}, repos.TaskCompletionAggregation.Data[9])
+}
@@ -0,0 +1,40 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"dogeplus-backend/errors"
+)
+
+// HealthRepository provides the database checks used by the readiness and diagnostics endpoints
+type HealthRepository struct {
+	db *sql.DB
+}
+
+func NewHealthRepository(db *sql.DB) *HealthRepository {
+	return &HealthRepository{db: db}
+}
+
+// Ping checks the database connection is alive.
+func (h *HealthRepository) Ping(ctx context.Context) error {
+	return errors.Wrap(h.db.PingContext(ctx), "failed to ping db")
+}
+
+// PendingMigrations returns the embedded migrations not yet applied to the database.
+func (h *HealthRepository) PendingMigrations() ([]Migration, error) {
+	return PendingMigrations(h.db)
+}
+
+// SchemaVersion returns the version of the last applied migration.
+func (h *HealthRepository) SchemaVersion() (int, error) {
+	return currentSchemaVersion(h.db)
+}
+
+// Stats returns the connection pool statistics.
+func (h *HealthRepository) Stats() sql.DBStats {
+	return h.db.Stats()
+}
@@ -0,0 +1,119 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Constants representing the kind of change recorded in the active event history.
+const (
+	HistoryCreated             = "created"
+	HistoryStatusChange        = "status_change"
+	HistoryEscalationUpdate    = "escalation_update"
+	HistoryDeEscalationRemoved = "deescalation_removed"
+	HistoryDeEscalationRebuilt = "deescalation_rebuilt" // tasks re-created by de-escalations before they kept their UUID
+	HistoryAssignment          = "assignment"
+	HistoryTransferred         = "transferred" // tasks moved to another event or central, detail holds the old and new event
+	HistoryMerged              = "merged"      // tasks merged into a task with the same title of the event they were moved to
+)
+
+// HistoryEntry represents a single append-only record of a change made to an active event task
+type HistoryEntry struct {
+	ID          int64     `json:"id"`
+	TaskUUID    uuid.UUID `json:"task_uuid"`
+	EventNumber int       `json:"event_number"`
+	CentralID   string    `json:"central_id"`
+	Title       string    `json:"title"`
+	ChangeType  string    `json:"change_type"`
+	OldStatus   string    `json:"old_status"`
+	NewStatus   string    `json:"new_status"`
+	ModifiedBy  string    `json:"modified_by"`
+	IpAddress   string    `json:"ip_address"`
+	Timestamp   time.Time `json:"timestamp"`
+	Detail      string    `json:"detail"`
+}
+
+// HistoryRepository represents a read only repository over the active event history.
+// Entries are written by the other repositories, inside the same transaction as the change they record.
+type HistoryRepository struct {
+	db *sql.DB
+}
+
+// NewHistoryRepository creates a new instance of HistoryRepository with the provided database connection.
+func NewHistoryRepository(db *sql.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+// addHistoryEntry appends an entry to the active_event_history table using the given transaction.
+// If the entry timestamp is zero, the current time is used.
+func addHistoryEntry(tx *sql.Tx, entry HistoryEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	_, err := tx.Exec(`INSERT INTO active_event_history (task_uuid, event_number, central_id, title, change_type,
+				old_status, new_status, modified_by, ip_address, timestamp, detail)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.TaskUUID, entry.EventNumber, entry.CentralID, entry.Title, entry.ChangeType, entry.OldStatus,
+		entry.NewStatus, entry.ModifiedBy, entry.IpAddress, entry.Timestamp, entry.Detail)
+
+	return errors.Wrap(err, "failed to add history entry")
+}
+
+// GetByTaskUUID retrieves the full timeline of a single task, oldest change first.
+func (h *HistoryRepository) GetByTaskUUID(taskUUID uuid.UUID) ([]HistoryEntry, error) {
+	query := `SELECT id, task_uuid, event_number, central_id, title, change_type, old_status, new_status,
+				modified_by, ip_address, timestamp, detail
+			FROM active_event_history WHERE task_uuid = ? ORDER BY id`
+
+	return h.queryEntries(query, taskUUID)
+}
+
+// GetByCentralAndNumber retrieves the full timeline of every task of an event, oldest change first.
+func (h *HistoryRepository) GetByCentralAndNumber(eventNumber int, centralId string) ([]HistoryEntry, error) {
+	query := `SELECT id, task_uuid, event_number, central_id, title, change_type, old_status, new_status,
+				modified_by, ip_address, timestamp, detail
+			FROM active_event_history WHERE central_id = ? AND event_number = ? ORDER BY id`
+
+	return h.queryEntries(query, centralId, eventNumber)
+}
+
+// queryEntries executes the given history query and scans the resulting rows.
+func (h *HistoryRepository) queryEntries(query string, args ...interface{}) ([]HistoryEntry, error) {
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query history")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in queryEntries")
+	}()
+
+	layout := "2006-01-02 15:04:05.999999-07:00"
+	entries := []HistoryEntry{}
+
+	for rows.Next() {
+		var tmpTimestamp string // timestamp as string to be scanned to before parsing
+		var entry HistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.TaskUUID, &entry.EventNumber, &entry.CentralID, &entry.Title,
+			&entry.ChangeType, &entry.OldStatus, &entry.NewStatus, &entry.ModifiedBy, &entry.IpAddress,
+			&tmpTimestamp, &entry.Detail); err != nil {
+			return nil, errors.Wrap(err, "failed to scan history row")
+		}
+		if entry.Timestamp, err = time.Parse(layout, tmpTimestamp); err != nil {
+			return nil, errors.Wrap(err, "failed to parse history timestamp")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestHistoryRepository_StatusTimeline tests that creation and status changes are recorded in order
+func TestHistoryRepository_StatusTimeline(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	activeEventsRepo := NewActiveEventRepository(db)
+	historyRepo := NewHistoryRepository(db)
+
+	eventNumber := 7
+	centralID := "SRA"
+
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+	}, eventNumber, centralID))
+
+	tasks, err := activeEventsRepo.GetByCentralAndNumber(eventNumber, centralID)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	taskUUID := tasks[0].UUID
+
+	_, err = activeEventsRepo.UpdateStatus(taskUUID, TaskWorking, "operator1", "10.0.0.1")
+	require.NoError(t, err)
+	_, err = activeEventsRepo.UpdateStatus(taskUUID, TaskDone, "operator2", "10.0.0.2")
+	require.NoError(t, err)
+
+	entries, err := historyRepo.GetByTaskUUID(taskUUID)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, HistoryCreated, entries[0].ChangeType)
+	assert.Equal(t, TaskNotdone, entries[0].NewStatus)
+
+	assert.Equal(t, HistoryStatusChange, entries[1].ChangeType)
+	assert.Equal(t, TaskNotdone, entries[1].OldStatus)
+	assert.Equal(t, TaskWorking, entries[1].NewStatus)
+	assert.Equal(t, "operator1", entries[1].ModifiedBy)
+
+	assert.Equal(t, TaskWorking, entries[2].OldStatus)
+	assert.Equal(t, TaskDone, entries[2].NewStatus)
+	assert.Equal(t, "10.0.0.2", entries[2].IpAddress)
+
+	// Event timeline should match the task one as the event has a single task
+	eventEntries, err := historyRepo.GetByCentralAndNumber(eventNumber, centralID)
+	require.NoError(t, err)
+	assert.Equal(t, entries, eventEntries)
+
+	// History is append-only
+	_, err = db.Exec("UPDATE active_event_history SET new_status = 'notdone'")
+	assert.Error(t, err)
+	_, err = db.Exec("DELETE FROM active_event_history")
+	assert.Error(t, err)
+}
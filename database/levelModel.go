@@ -0,0 +1,151 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// LevelDefinition is a level of the escalation ladder.
+// SubLevels are the sub-levels allowed under the level, e.g. the incident colours of "incidente", from the lowest.
+type LevelDefinition struct {
+	Name      string   `json:"name"`
+	Rank      int      `json:"rank"`
+	SubLevels []string `json:"sub_levels"`
+}
+
+// LevelModel is the escalation ladder and the sub-level ladder of each level, as defined in the escalation_levels table.
+// Filtering, merging and validation of tasks and events rely on it instead of hard-coded level lists.
+// Ranks are positions in the ladders starting from 1, unknown levels and sub-levels rank 0.
+type LevelModel struct {
+	levels    []LevelDefinition
+	ranks     map[string]int
+	subRanks  map[string]map[string]int
+	subLevels map[string]bool
+}
+
+// NewLevelModel builds a LevelModel from the given definitions, ordered by their rank
+func NewLevelModel(definitions []LevelDefinition) *LevelModel {
+	levels := make([]LevelDefinition, len(definitions))
+	copy(levels, definitions)
+	sort.SliceStable(levels, func(i, j int) bool { return levels[i].Rank < levels[j].Rank })
+
+	model := &LevelModel{
+		levels:    levels,
+		ranks:     make(map[string]int, len(levels)),
+		subRanks:  make(map[string]map[string]int, len(levels)),
+		subLevels: make(map[string]bool),
+	}
+	for i, level := range levels {
+		model.ranks[level.Name] = i + 1
+
+		subRanks := make(map[string]int, len(level.SubLevels))
+		for j, subLevel := range level.SubLevels {
+			subRanks[subLevel] = j + 1
+			model.subLevels[subLevel] = true
+		}
+		model.subRanks[level.Name] = subRanks
+	}
+
+	return model
+}
+
+// DefaultLevelModel returns the ladder seeded by the migrations, used until the model is loaded from the db
+func DefaultLevelModel() *LevelModel {
+	return NewLevelModel([]LevelDefinition{
+		{Name: EscalationAlarm, Rank: 1},
+		{Name: EscalationEmergency, Rank: 2},
+		{Name: EscalationIncident, Rank: 3, SubLevels: []string{"bianca", "verde", "gialla", "rossa"}},
+	})
+}
+
+// currentLevelModel holds the level model in use, replaced when it is loaded from the db
+var currentLevelModel atomic.Pointer[LevelModel]
+
+func init() {
+	currentLevelModel.Store(DefaultLevelModel())
+}
+
+// CurrentLevelModel returns the level model in use.
+func CurrentLevelModel() *LevelModel {
+	return currentLevelModel.Load()
+}
+
+// setLevelModel replaces the level model in use
+func setLevelModel(model *LevelModel) {
+	currentLevelModel.Store(model)
+}
+
+// Definitions returns a copy of the levels, from the lowest
+func (m *LevelModel) Definitions() []LevelDefinition {
+	definitions := make([]LevelDefinition, len(m.levels))
+	for i, level := range m.levels {
+		definitions[i] = LevelDefinition{Name: level.Name, Rank: level.Rank, SubLevels: append([]string{}, level.SubLevels...)}
+	}
+	return definitions
+}
+
+// Levels returns the level names, from the lowest
+func (m *LevelModel) Levels() []string {
+	names := make([]string, len(m.levels))
+	for i, level := range m.levels {
+		names[i] = level.Name
+	}
+	return names
+}
+
+// Rank returns the position of a level in the ladder starting from 1, 0 if the level is unknown
+func (m *LevelModel) Rank(level string) int {
+	return m.ranks[level]
+}
+
+// IsLevel reports whether level is part of the ladder
+func (m *LevelModel) IsLevel(level string) bool {
+	_, ok := m.ranks[level]
+	return ok
+}
+
+// SubLevels returns the sub-levels allowed under a level, from the lowest, empty if it has none
+func (m *LevelModel) SubLevels(level string) []string {
+	for _, definition := range m.levels {
+		if definition.Name == level {
+			return append([]string{}, definition.SubLevels...)
+		}
+	}
+	return []string{}
+}
+
+// HasSubLevels reports whether a level requires a sub-level, like "incidente" requires an incident colour
+func (m *LevelModel) HasSubLevels(level string) bool {
+	return len(m.subRanks[level]) > 0
+}
+
+// SubRank returns the position of a sub-level under a level starting from 1, 0 if it is not allowed under the level
+func (m *LevelModel) SubRank(level, subLevel string) int {
+	return m.subRanks[level][subLevel]
+}
+
+// IsSubLevel reports whether subLevel is allowed under level
+func (m *LevelModel) IsSubLevel(level, subLevel string) bool {
+	_, ok := m.subRanks[level][subLevel]
+	return ok
+}
+
+// IsAnySubLevel reports whether subLevel is allowed under at least one level
+func (m *LevelModel) IsAnySubLevel(subLevel string) bool {
+	return m.subLevels[subLevel]
+}
+
+// LevelsWithSubLevels returns the names of the levels requiring a sub-level, from the lowest
+func (m *LevelModel) LevelsWithSubLevels() []string {
+	names := []string{}
+	for _, level := range m.levels {
+		if len(level.SubLevels) > 0 {
+			names = append(names, level.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,196 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/config"
+	"dogeplus-backend/errors"
+	"embed"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the up-migrations embedded in the binary.
+// Files are named "<version>_<name>.sql", where version is a positive integer defining the apply order.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration represents a single versioned schema change
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+// loadMigrations reads the embedded migration files and returns them ordered by version.
+// It returns an error if a file name does not follow the "<version>_<name>.sql" format or if a version is duplicated.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read embedded migrations")
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+		versionPart, name, found := strings.Cut(strings.TrimSuffix(fileName, ".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in file name %q", fileName)
+		}
+
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicated migration version %d: %q and %q", version, other, fileName)
+		}
+		seen[version] = fileName
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read migration %s", fileName)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// ensureSchemaVersionTable creates the table keeping track of the applied migrations
+func ensureSchemaVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL)`)
+
+	return errors.Wrap(err, "failed to create schema_version table")
+}
+
+// currentSchemaVersion returns the highest applied migration version, 0 if none has been applied
+// or if the schema_version table does not exist yet.
+func currentSchemaVersion(db *sql.DB) (int, error) {
+	var tables int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&tables)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to look up schema_version table")
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+
+	return version, errors.Wrap(err, "failed to read schema version")
+}
+
+// PendingMigrations returns the embedded migrations not yet applied to the database, ordered by version.
+// It only reads from the database.
+func PendingMigrations(db *sql.DB) ([]Migration, error) {
+	current, err := currentSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// applyMigration executes a single migration and records it in schema_version within one transaction.
+// If the migration fails the transaction is rolled back and the database is left untouched.
+func applyMigration(db *sql.DB, migration Migration) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	// Ensure the transaction will be closed before returning
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(migration.SQL); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Name, time.Now())
+
+	return errors.Wrap(err, "failed to record schema version")
+}
+
+// migrate brings the database schema up to date by applying every pending migration in version order.
+// Each migration runs in its own transaction, so a failure stops the process leaving the schema
+// at the last successfully applied version.
+func migrate(db *sql.DB) error {
+	if err := ensureSchemaVersionTable(db); err != nil {
+		return err
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		if err := applyMigration(db, migration); err != nil {
+			return err
+		}
+		log.Infof("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+
+	return nil
+}
+
+// DryRunMigrations opens the configured database and reports the pending migrations without applying them.
+// It does not go through the GetInstance singleton, so the application database is never migrated by a dry run.
+func DryRunMigrations(configFile config.Config) ([]Migration, error) {
+	dbFile := config.GetEnvWithFallback(configFile, config.DbFile)
+	if err := config.SanitizeFilePath(dbFile); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		errors.HandleCloser(db.Close(), "error closing db in DryRunMigrations")
+	}()
+
+	return PendingMigrations(db)
+}
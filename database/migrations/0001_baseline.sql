@@ -0,0 +1,114 @@
+-- Baseline schema, matches the tables created before versioned migrations were introduced.
+-- Every statement is idempotent so that existing databases can adopt it safely.
+
+CREATE TABLE IF NOT EXISTS test (id INTEGER PRIMARY KEY, data TEXT);
+
+-- Tasks table
+CREATE TABLE IF NOT EXISTS tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    category TEXT,
+    escalation_level TEXT CHECK ( escalation_level IN ('allarme','emergenza','incidente')),
+    incident_level TEXT CHECK ( incident_level IN ('','bianca', 'verde', 'gialla', 'rossa')));
+-- No trigger for task table
+
+-- Active events table
+CREATE TABLE IF NOT EXISTS active_events (
+    uuid TEXT PRIMARY KEY,
+    event_number INTEGER,
+    event_date TEXT NOT NULL,
+    central_id TEXT CHECK ( central_id IN ('HQ','SRA','SRL','SRM','SRP')),
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    status TEXT CHECK ( status IN ('notdone','working','done')),
+    modified_by TEXT,
+    ip_address TEXT DEFAULT '0.0.0.0',
+    timestamp TEXT,
+    escalation_level TEXT CHECK (escalation_level in ('allarme', 'emergenza', 'incidente')));
+
+-- Overview table
+create table IF NOT EXISTS overview(
+    uuid            text    not null
+        constraint overview_pk
+        primary key,
+    central_id      text    not null,
+    event_number    integer not null
+        constraint event_number_unique_ck
+        unique,
+    location        text    not null,
+    location_detail text,
+    type            text    not null,
+    level           text    not null,
+    incident_level  text);
+
+-- Escalation levels definition table
+create table IF NOT EXISTS escalation_levels(
+    uuid        TEXT not null,
+    name        text not null,
+    description text,
+        constraint escalation_levels_pk
+        primary key (uuid));
+
+-- Archived events table, holds the tasks of closed events for post-event review
+CREATE TABLE IF NOT EXISTS archived_events (
+    uuid TEXT PRIMARY KEY,
+    event_number INTEGER,
+    event_date TEXT NOT NULL,
+    central_id TEXT,
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    status TEXT,
+    modified_by TEXT,
+    ip_address TEXT,
+    timestamp TEXT,
+    escalation_level TEXT,
+    closed_at TEXT NOT NULL,
+    closed_by TEXT NOT NULL,
+    closure_reason TEXT NOT NULL);
+
+-- Archived overview table, event_number is not unique as numbers can be reused once an event is closed
+CREATE TABLE IF NOT EXISTS archived_overview (
+    uuid            TEXT    NOT NULL PRIMARY KEY,
+    central_id      TEXT    NOT NULL,
+    event_number    INTEGER NOT NULL,
+    location        TEXT    NOT NULL,
+    location_detail TEXT,
+    type            TEXT    NOT NULL,
+    level           TEXT    NOT NULL,
+    incident_level  TEXT,
+    closed_at       TEXT    NOT NULL,
+    closed_by       TEXT    NOT NULL,
+    closure_reason  TEXT    NOT NULL);
+
+-- Active event history table, append-only audit trail of every change made to active event tasks
+CREATE TABLE IF NOT EXISTS active_event_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_uuid TEXT NOT NULL,
+    event_number INTEGER NOT NULL,
+    central_id TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    change_type TEXT NOT NULL,
+    old_status TEXT NOT NULL DEFAULT '',
+    new_status TEXT NOT NULL DEFAULT '',
+    modified_by TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    timestamp TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '');
+
+CREATE INDEX IF NOT EXISTS active_event_history_task_idx ON active_event_history (task_uuid);
+
+CREATE INDEX IF NOT EXISTS active_event_history_event_idx ON active_event_history (central_id, event_number);
+
+-- History is an audit trail, reject any attempt to rewrite it
+CREATE TRIGGER IF NOT EXISTS active_event_history_no_update BEFORE UPDATE ON active_event_history
+    BEGIN SELECT RAISE(ABORT, 'active_event_history is append-only'); END;
+
+CREATE TRIGGER IF NOT EXISTS active_event_history_no_delete BEFORE DELETE ON active_event_history
+    BEGIN SELECT RAISE(ABORT, 'active_event_history is append-only'); END;
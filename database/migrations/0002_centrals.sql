@@ -0,0 +1,40 @@
+-- Operations centrals, replaces the central_id CHECK constraint hard-coded in active_events
+CREATE TABLE centrals (
+    id        TEXT    NOT NULL PRIMARY KEY,
+    name      TEXT    NOT NULL,
+    task_file TEXT    NOT NULL DEFAULT '',
+    active    INTEGER NOT NULL DEFAULT 1 CHECK (active IN (0, 1)));
+
+-- Seed with the centrals previously allowed by the CHECK constraint, local task file named after the central
+INSERT INTO centrals (id, name, task_file) VALUES
+    ('HQ', 'HQ', 'HQ.xlsx'),
+    ('SRA', 'SOREU Alpina', 'SRA.xlsx'),
+    ('SRL', 'SOREU Laghi', 'SRL.xlsx'),
+    ('SRM', 'SOREU Metropolitana', 'SRM.xlsx'),
+    ('SRP', 'SOREU Pianura', 'SRP.xlsx');
+
+-- SQLite can't drop a constraint, rebuild active_events without the central_id CHECK
+CREATE TABLE active_events_new (
+    uuid TEXT PRIMARY KEY,
+    event_number INTEGER,
+    event_date TEXT NOT NULL,
+    central_id TEXT,
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    status TEXT CHECK ( status IN ('notdone','working','done')),
+    modified_by TEXT,
+    ip_address TEXT DEFAULT '0.0.0.0',
+    timestamp TEXT,
+    escalation_level TEXT CHECK (escalation_level in ('allarme', 'emergenza', 'incidente')));
+
+INSERT INTO active_events_new (uuid, event_number, event_date, central_id, priority, title, description, role, status,
+                               modified_by, ip_address, timestamp, escalation_level)
+SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status,
+       modified_by, ip_address, timestamp, escalation_level
+FROM active_events;
+
+DROP TABLE active_events;
+
+ALTER TABLE active_events_new RENAME TO active_events;
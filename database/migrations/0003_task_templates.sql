@@ -0,0 +1,38 @@
+-- Task template versions, every main task upload or rollback is stored as a new numbered version
+CREATE TABLE task_template_versions (
+    version       INTEGER PRIMARY KEY AUTOINCREMENT,
+    uploaded_by   TEXT    NOT NULL,
+    uploaded_at   TEXT    NOT NULL,
+    filename      TEXT    NOT NULL,
+    checksum      TEXT    NOT NULL,
+    task_count    INTEGER NOT NULL,
+    restored_from INTEGER REFERENCES task_template_versions (version));
+
+-- Tasks of each template version, the tasks table always holds a copy of the latest version
+CREATE TABLE task_template_tasks (
+    version          INTEGER NOT NULL REFERENCES task_template_versions (version),
+    priority         INTEGER,
+    title            TEXT,
+    description      TEXT,
+    role             TEXT,
+    category         TEXT,
+    escalation_level TEXT,
+    incident_level   TEXT);
+
+CREATE INDEX task_template_tasks_version_idx ON task_template_tasks (version);
+
+-- Keep the tasks loaded before versioning as the first version
+INSERT INTO task_template_versions (uploaded_by, uploaded_at, filename, checksum, task_count)
+SELECT 'migration', strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'), 'pre-versioning', '', COUNT(*)
+FROM tasks
+HAVING COUNT(*) > 0;
+
+INSERT INTO task_template_tasks (version, priority, title, description, role, category, escalation_level, incident_level)
+SELECT v.version, COALESCE(t.priority, 0), COALESCE(t.title, ''), COALESCE(t.description, ''), COALESCE(t.role, ''),
+       COALESCE(t.category, ''), COALESCE(t.escalation_level, ''), COALESCE(t.incident_level, '')
+FROM tasks t, task_template_versions v
+ORDER BY t.id;
+
+-- Template version each event task was built from, NULL for tasks created before versioning
+ALTER TABLE active_events ADD COLUMN template_version INTEGER;
+ALTER TABLE archived_events ADD COLUMN template_version INTEGER;
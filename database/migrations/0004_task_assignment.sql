@@ -0,0 +1,7 @@
+-- Operator responsible for an event task, NULL while the task is unassigned
+ALTER TABLE active_events ADD COLUMN assigned_to TEXT;
+ALTER TABLE active_events ADD COLUMN assigned_at DATETIME;
+ALTER TABLE archived_events ADD COLUMN assigned_to TEXT;
+ALTER TABLE archived_events ADD COLUMN assigned_at DATETIME;
+
+CREATE INDEX idx_active_events_assigned_to ON active_events (assigned_to, status);
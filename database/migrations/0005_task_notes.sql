@@ -0,0 +1,28 @@
+-- Timestamped free text notes on active event tasks
+CREATE TABLE task_notes (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_uuid    TEXT     NOT NULL,
+    event_number INTEGER  NOT NULL,
+    central_id   TEXT     NOT NULL,
+    author       TEXT     NOT NULL,
+    body         TEXT     NOT NULL,
+    created_at   DATETIME NOT NULL);
+
+CREATE INDEX idx_task_notes_task ON task_notes (task_uuid);
+CREATE INDEX idx_task_notes_event ON task_notes (central_id, event_number);
+
+-- Files attached to active event tasks, the content is stored under the attachments directory
+CREATE TABLE task_attachments (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_uuid    TEXT     NOT NULL,
+    event_number INTEGER  NOT NULL,
+    central_id   TEXT     NOT NULL,
+    uploaded_by  TEXT     NOT NULL,
+    filename     TEXT     NOT NULL,
+    stored_name  TEXT     NOT NULL UNIQUE,
+    content_type TEXT     NOT NULL,
+    size         INTEGER  NOT NULL,
+    created_at   DATETIME NOT NULL);
+
+CREATE INDEX idx_task_attachments_task ON task_attachments (task_uuid);
+CREATE INDEX idx_task_attachments_event ON task_attachments (central_id, event_number);
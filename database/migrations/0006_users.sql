@@ -0,0 +1,7 @@
+-- Local users allowed to access the API, passwords are stored as bcrypt hashes
+CREATE TABLE users (
+    username      TEXT PRIMARY KEY,
+    password_hash TEXT     NOT NULL,
+    role          TEXT     NOT NULL CHECK (role IN ('operator', 'supervisor', 'procedure-admin')),
+    active        BOOLEAN  NOT NULL DEFAULT 1,
+    created_at    DATETIME NOT NULL);
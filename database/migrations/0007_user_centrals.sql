@@ -0,0 +1,5 @@
+-- Centrals a user belongs to, operators can only follow the realtime updates of their centrals
+CREATE TABLE user_centrals (
+    username   TEXT NOT NULL,
+    central_id TEXT NOT NULL,
+    PRIMARY KEY (username, central_id));
@@ -0,0 +1,11 @@
+-- Category of the task an event task was created from, the event snapshot groups tasks by role and category
+ALTER TABLE active_events ADD COLUMN category TEXT;
+ALTER TABLE archived_events ADD COLUMN category TEXT;
+
+-- Revision of every event, bumped at each change broadcast to the clients.
+-- Rows outlive the event so a reused event number keeps increasing its revision.
+CREATE TABLE event_revisions (
+    central_id   TEXT    NOT NULL,
+    event_number INTEGER NOT NULL,
+    revision     INTEGER NOT NULL,
+    PRIMARY KEY (central_id, event_number));
@@ -0,0 +1,117 @@
+-- Level model: the escalation_levels definitions get their rank in the ladder and the sub-levels allowed under them,
+-- replacing the level ladders hard-coded in the code and in the tasks and active_events CHECK constraints.
+-- Definitions with rank 0 are descriptive only and not part of the ladder.
+ALTER TABLE escalation_levels ADD COLUMN rank INTEGER NOT NULL DEFAULT 0;
+
+-- Levels are referenced by their lowercase name, like in tasks and active_events
+UPDATE escalation_levels SET name = lower(name) WHERE lower(name) IN ('allarme', 'emergenza', 'incidente');
+
+-- Levels are referenced by name, keep the first definition of duplicated names
+DELETE FROM escalation_levels WHERE rowid NOT IN (SELECT MIN(rowid) FROM escalation_levels GROUP BY name);
+
+CREATE UNIQUE INDEX escalation_levels_name_uq ON escalation_levels (name);
+
+-- Seed the levels previously hard-coded, keeping the descriptions of the ones already defined
+INSERT INTO escalation_levels (uuid, name, description)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+             substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       l.name, l.description
+FROM (SELECT 'allarme' AS name, 'Allarme' AS description
+      UNION ALL SELECT 'emergenza', 'Emergenza'
+      UNION ALL SELECT 'incidente', 'Incidente') l
+WHERE NOT EXISTS (SELECT 1 FROM escalation_levels e WHERE lower(e.name) = l.name);
+
+UPDATE escalation_levels SET rank = CASE name WHEN 'allarme' THEN 1 WHEN 'emergenza' THEN 2 WHEN 'incidente' THEN 3 END
+WHERE name IN ('allarme', 'emergenza', 'incidente');
+
+-- Sub-levels allowed under a level, ordered by rank, e.g. the incident colours of incidente
+CREATE TABLE escalation_sub_levels (
+    level TEXT    NOT NULL REFERENCES escalation_levels (name),
+    name  TEXT    NOT NULL,
+    rank  INTEGER NOT NULL,
+    PRIMARY KEY (level, name));
+
+INSERT INTO escalation_sub_levels (level, name, rank) VALUES
+    ('incidente', 'bianca', 1),
+    ('incidente', 'verde', 2),
+    ('incidente', 'gialla', 3),
+    ('incidente', 'rossa', 4);
+
+-- SQLite can't drop a constraint, rebuild tasks without the level CHECKs
+CREATE TABLE tasks_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    category TEXT,
+    escalation_level TEXT,
+    incident_level TEXT);
+
+INSERT INTO tasks_new (id, priority, title, description, role, category, escalation_level, incident_level)
+SELECT id, priority, title, description, role, category, escalation_level, incident_level
+FROM tasks;
+
+DROP TABLE tasks;
+
+ALTER TABLE tasks_new RENAME TO tasks;
+
+-- Same for active_events, keeping the status CHECK and the assignment index
+CREATE TABLE active_events_new (
+    uuid TEXT PRIMARY KEY,
+    event_number INTEGER,
+    event_date TEXT NOT NULL,
+    central_id TEXT,
+    priority INTEGER,
+    title TEXT,
+    description TEXT,
+    role TEXT,
+    status TEXT CHECK ( status IN ('notdone','working','done')),
+    modified_by TEXT,
+    ip_address TEXT DEFAULT '0.0.0.0',
+    timestamp TEXT,
+    escalation_level TEXT,
+    template_version INTEGER,
+    assigned_to TEXT,
+    assigned_at DATETIME,
+    category TEXT);
+
+INSERT INTO active_events_new (uuid, event_number, event_date, central_id, priority, title, description, role, status,
+                               modified_by, ip_address, timestamp, escalation_level, template_version, assigned_to,
+                               assigned_at, category)
+SELECT uuid, event_number, event_date, central_id, priority, title, description, role, status,
+       modified_by, ip_address, timestamp, escalation_level, template_version, assigned_to,
+       assigned_at, category
+FROM active_events;
+
+DROP TABLE active_events;
+
+ALTER TABLE active_events_new RENAME TO active_events;
+
+CREATE INDEX idx_active_events_assigned_to ON active_events (assigned_to, status);
+
+-- The level CHECKs become triggers validating against the level model.
+-- Like the CHECKs they replace, NULL levels are accepted and incident levels are not tied to the task level.
+CREATE TRIGGER tasks_levels_insert BEFORE INSERT ON tasks
+    WHEN (NEW.escalation_level IS NOT NULL
+              AND NOT EXISTS (SELECT 1 FROM escalation_levels WHERE name = NEW.escalation_level AND rank > 0))
+        OR (NEW.incident_level IS NOT NULL AND NEW.incident_level <> ''
+              AND NOT EXISTS (SELECT 1 FROM escalation_sub_levels WHERE name = NEW.incident_level))
+    BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: tasks escalation_level or incident_level not in the level model'); END;
+
+CREATE TRIGGER tasks_levels_update BEFORE UPDATE OF escalation_level, incident_level ON tasks
+    WHEN (NEW.escalation_level IS NOT NULL
+              AND NOT EXISTS (SELECT 1 FROM escalation_levels WHERE name = NEW.escalation_level AND rank > 0))
+        OR (NEW.incident_level IS NOT NULL AND NEW.incident_level <> ''
+              AND NOT EXISTS (SELECT 1 FROM escalation_sub_levels WHERE name = NEW.incident_level))
+    BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: tasks escalation_level or incident_level not in the level model'); END;
+
+CREATE TRIGGER active_events_level_insert BEFORE INSERT ON active_events
+    WHEN NEW.escalation_level IS NOT NULL
+        AND NOT EXISTS (SELECT 1 FROM escalation_levels WHERE name = NEW.escalation_level AND rank > 0)
+    BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: active_events escalation_level not in the level model'); END;
+
+CREATE TRIGGER active_events_level_update BEFORE UPDATE OF escalation_level ON active_events
+    WHEN NEW.escalation_level IS NOT NULL
+        AND NOT EXISTS (SELECT 1 FROM escalation_levels WHERE name = NEW.escalation_level AND rank > 0)
+    BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: active_events escalation_level not in the level model'); END;
@@ -0,0 +1,26 @@
+-- Change log of the escalations and de-escalations of every event, with who changed the level and why.
+-- Rows outlive the event for the incident timeline and the regional reporting.
+CREATE TABLE escalation_history (
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_number       INTEGER NOT NULL,
+    central_id         TEXT    NOT NULL,
+    direction          TEXT    NOT NULL CHECK ( direction IN ('escalate', 'deescalate')),
+    old_level          TEXT    NOT NULL DEFAULT '',
+    new_level          TEXT    NOT NULL,
+    old_incident_level TEXT    NOT NULL DEFAULT '',
+    new_incident_level TEXT    NOT NULL DEFAULT '',
+    operator           TEXT    NOT NULL DEFAULT '',
+    ip_address         TEXT    NOT NULL DEFAULT '',
+    reason             TEXT    NOT NULL DEFAULT '',
+    tasks_added        INTEGER NOT NULL DEFAULT 0,
+    tasks_removed      INTEGER NOT NULL DEFAULT 0,
+    timestamp          TEXT    NOT NULL);
+
+CREATE INDEX escalation_history_event_idx ON escalation_history (central_id, event_number);
+
+-- Like the task history, the change log is an audit trail
+CREATE TRIGGER escalation_history_no_update BEFORE UPDATE ON escalation_history
+    BEGIN SELECT RAISE(ABORT, 'escalation_history is append-only'); END;
+
+CREATE TRIGGER escalation_history_no_delete BEFORE DELETE ON escalation_history
+    BEGIN SELECT RAISE(ABORT, 'escalation_history is append-only'); END;
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestMigrate tests that migrations are applied once, in order, and recorded in schema_version
+func TestMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version, "Migrations should be ordered by version")
+	}
+
+	// Fresh database has everything pending, without creating anything
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+	version, err := currentSchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, migrate(db))
+
+	pending, err = PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	version, err = currentSchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+
+	// Running again is a no-op
+	require.NoError(t, migrate(db))
+	var applied int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+}
+
+// TestApplyMigration_Rollback tests that a failing migration leaves the database untouched
+func TestApplyMigration_Rollback(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	require.NoError(t, ensureSchemaVersionTable(db))
+
+	err = applyMigration(db, Migration{
+		Version: 1,
+		Name:    "broken",
+		SQL:     "CREATE TABLE half_done (id INTEGER); INSERT INTO missing_table VALUES (1);",
+	})
+	require.Error(t, err)
+
+	var tables int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&tables))
+	assert.Equal(t, 0, tables)
+
+	version, err := currentSchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+}
+
+// TestMigrate_CapitalizedLevels tests that the level model migration ranks the capitalized level definitions
+// of existing databases, keeping their descriptions instead of seeding duplicates
+func TestMigrate_CapitalizedLevels(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	require.NoError(t, ensureSchemaVersionTable(db))
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	for _, migration := range migrations {
+		if migration.Version >= 9 {
+			break
+		}
+		require.NoError(t, applyMigration(db, migration))
+	}
+
+	_, err = db.Exec(`INSERT INTO escalation_levels (uuid, name, description) VALUES
+		('E547219D-77E2-4920-A162-A4EF76AF61A6', 'Allarme', 'Evento senza notizie dirette'),
+		('9BABDD03-4733-49D0-B84B-B871FE1EF9F2', 'Emergenza', 'Evento con potenziale pericolo'),
+		('48474E33-8718-483B-8D6C-25225DBCDADF', 'Incidente', 'Evento dannoso'),
+		('39B8D8B1-5A2B-4765-9EFF-D5DDAADE2938', 'Bianca', 'Fino a 10 coinvolti')`)
+	require.NoError(t, err)
+
+	require.NoError(t, migrate(db))
+
+	definitions, err := NewEscalationLevelsDefinitionRepository(db).GetAll()
+	require.NoError(t, err)
+	descriptions := map[string]string{}
+	ranks := map[string]int{}
+	for _, definition := range definitions {
+		descriptions[definition.Name] = definition.Description
+		ranks[definition.Name] = definition.Rank
+	}
+	assert.Equal(t, map[string]int{"allarme": 1, "emergenza": 2, "incidente": 3, "Bianca": 0}, ranks)
+	assert.Equal(t, "Evento senza notizie dirette", descriptions["allarme"])
+	assert.Equal(t, "Evento dannoso", descriptions["incidente"])
+}
@@ -0,0 +1,226 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+//
+// This file contains the operational log of active event tasks:
+// - free text notes
+// - attachments metadata, the content is stored on disk by the caller
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type AttachmentNotFoundError struct {
+	Detail string
+}
+
+func (e AttachmentNotFoundError) Error() string {
+	return fmt.Sprintf("attachment not found: %s", e.Detail)
+}
+
+// TaskNote represents a timestamped free text note on an active event task
+type TaskNote struct {
+	ID          int64     `json:"id"`
+	TaskUUID    uuid.UUID `json:"task_uuid"`
+	EventNumber int       `json:"event_number"`
+	CentralID   string    `json:"central_id"`
+	Author      string    `json:"author"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TaskAttachment represents a file attached to an active event task.
+// StoredName is the path of the content relative to the attachments directory, it is never exposed.
+type TaskAttachment struct {
+	ID          int64     `json:"id"`
+	TaskUUID    uuid.UUID `json:"task_uuid"`
+	EventNumber int       `json:"event_number"`
+	CentralID   string    `json:"central_id"`
+	UploadedBy  string    `json:"uploaded_by"`
+	Filename    string    `json:"filename"`
+	StoredName  string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NotesRepository represents a repository for managing notes and attachments of active event tasks
+type NotesRepository struct {
+	db *sql.DB
+}
+
+// NewNotesRepository creates a new instance of NotesRepository with the provided database connection.
+func NewNotesRepository(db *sql.DB) *NotesRepository {
+	return &NotesRepository{db: db}
+}
+
+// GetTask retrieves the active event task notes and attachments are added to.
+// It returns a TaskNotFoundError if the task does not exist.
+func (n *NotesRepository) GetTask(taskUUID uuid.UUID) (ActiveEvents, error) {
+	task, err := scanActiveEvent(n.db.QueryRow("SELECT "+activeEventColumns+" FROM active_events WHERE uuid = ?", taskUUID))
+	if err == sql.ErrNoRows {
+		return ActiveEvents{}, &TaskNotFoundError{Detail: taskUUID.String()}
+	} else if err != nil {
+		return ActiveEvents{}, errors.Wrap(err, "failed to get task")
+	}
+
+	return task, nil
+}
+
+// AddNote adds a note to an active event task, event number and central are taken from the task.
+// It returns the stored note, or a TaskNotFoundError if the task does not exist.
+func (n *NotesRepository) AddNote(taskUUID uuid.UUID, author, body string) (TaskNote, error) {
+	task, err := n.GetTask(taskUUID)
+	if err != nil {
+		return TaskNote{}, err
+	}
+
+	note := TaskNote{
+		TaskUUID:    task.UUID,
+		EventNumber: task.EventNumber,
+		CentralID:   task.CentralID,
+		Author:      author,
+		Body:        body,
+		CreatedAt:   time.Now(),
+	}
+
+	result, err := n.db.Exec(`INSERT INTO task_notes (task_uuid, event_number, central_id, author, body, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+		note.TaskUUID, note.EventNumber, note.CentralID, note.Author, note.Body, note.CreatedAt)
+	if err != nil {
+		return TaskNote{}, errors.Wrap(err, "failed to add note")
+	}
+
+	if note.ID, err = result.LastInsertId(); err != nil {
+		return TaskNote{}, errors.Wrap(err, "failed to get note id")
+	}
+
+	return note, nil
+}
+
+// GetNotesByTask retrieves the notes of a task, oldest first.
+func (n *NotesRepository) GetNotesByTask(taskUUID uuid.UUID) ([]TaskNote, error) {
+	return n.queryNotes(`SELECT id, task_uuid, event_number, central_id, author, body, created_at
+			FROM task_notes WHERE task_uuid = ? ORDER BY created_at, id`, taskUUID)
+}
+
+// GetNotesByEvent retrieves the notes of all the tasks of an event, oldest first.
+func (n *NotesRepository) GetNotesByEvent(eventNumber int, centralId string) ([]TaskNote, error) {
+	return n.queryNotes(`SELECT id, task_uuid, event_number, central_id, author, body, created_at
+			FROM task_notes WHERE central_id = ? AND event_number = ? ORDER BY created_at, id`, centralId, eventNumber)
+}
+
+// queryNotes runs a task_notes query and scans the resulting rows
+func (n *NotesRepository) queryNotes(query string, args ...interface{}) ([]TaskNote, error) {
+	rows, err := n.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query notes")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in queryNotes")
+	}()
+
+	notes := []TaskNote{}
+	for rows.Next() {
+		var note TaskNote
+		if err := rows.Scan(&note.ID, &note.TaskUUID, &note.EventNumber, &note.CentralID, &note.Author, &note.Body,
+			&note.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan note row")
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return notes, nil
+}
+
+// AddAttachment stores the metadata of a file attached to a task.
+// Task, event number and central must be set, the content must already be stored under StoredName.
+// It returns the attachment with its id and creation date set.
+func (n *NotesRepository) AddAttachment(attachment TaskAttachment) (TaskAttachment, error) {
+	attachment.CreatedAt = time.Now()
+
+	result, err := n.db.Exec(`INSERT INTO task_attachments (task_uuid, event_number, central_id, uploaded_by, filename,
+				stored_name, content_type, size, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		attachment.TaskUUID, attachment.EventNumber, attachment.CentralID, attachment.UploadedBy, attachment.Filename,
+		attachment.StoredName, attachment.ContentType, attachment.Size, attachment.CreatedAt)
+	if err != nil {
+		return TaskAttachment{}, errors.Wrap(err, "failed to add attachment")
+	}
+
+	if attachment.ID, err = result.LastInsertId(); err != nil {
+		return TaskAttachment{}, errors.Wrap(err, "failed to get attachment id")
+	}
+
+	return attachment, nil
+}
+
+// GetAttachment retrieves a single attachment.
+// It returns an AttachmentNotFoundError if the attachment does not exist.
+func (n *NotesRepository) GetAttachment(id int64) (TaskAttachment, error) {
+	attachments, err := n.queryAttachments(`SELECT id, task_uuid, event_number, central_id, uploaded_by, filename,
+				stored_name, content_type, size, created_at
+			FROM task_attachments WHERE id = ?`, id)
+	if err != nil {
+		return TaskAttachment{}, err
+	}
+
+	if len(attachments) == 0 {
+		return TaskAttachment{}, &AttachmentNotFoundError{Detail: fmt.Sprintf("id %d", id)}
+	}
+
+	return attachments[0], nil
+}
+
+// GetAttachmentsByTask retrieves the attachments of a task, oldest first.
+func (n *NotesRepository) GetAttachmentsByTask(taskUUID uuid.UUID) ([]TaskAttachment, error) {
+	return n.queryAttachments(`SELECT id, task_uuid, event_number, central_id, uploaded_by, filename, stored_name,
+				content_type, size, created_at
+			FROM task_attachments WHERE task_uuid = ? ORDER BY created_at, id`, taskUUID)
+}
+
+// GetAttachmentsByEvent retrieves the attachments of all the tasks of an event, oldest first.
+func (n *NotesRepository) GetAttachmentsByEvent(eventNumber int, centralId string) ([]TaskAttachment, error) {
+	return n.queryAttachments(`SELECT id, task_uuid, event_number, central_id, uploaded_by, filename, stored_name,
+				content_type, size, created_at
+			FROM task_attachments WHERE central_id = ? AND event_number = ? ORDER BY created_at, id`,
+		centralId, eventNumber)
+}
+
+// queryAttachments runs a task_attachments query and scans the resulting rows
+func (n *NotesRepository) queryAttachments(query string, args ...interface{}) ([]TaskAttachment, error) {
+	rows, err := n.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query attachments")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in queryAttachments")
+	}()
+
+	attachments := []TaskAttachment{}
+	for rows.Next() {
+		var attachment TaskAttachment
+		if err := rows.Scan(&attachment.ID, &attachment.TaskUUID, &attachment.EventNumber, &attachment.CentralID,
+			&attachment.UploadedBy, &attachment.Filename, &attachment.StoredName, &attachment.ContentType,
+			&attachment.Size, &attachment.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan attachment row")
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return attachments, nil
+}
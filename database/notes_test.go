@@ -0,0 +1,70 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestNotesRepository tests notes and attachments per task and per event
+func TestNotesRepository(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	activeEventsRepo := NewActiveEventRepository(db)
+	notesRepo := NewNotesRepository(db)
+
+	require.NoError(t, activeEventsRepo.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Call prefecture", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Send team", EscalationLevel: EscalationEmergency},
+	}, 11, "SRA"))
+
+	tasks, err := activeEventsRepo.GetByCentralAndNumber(11, "SRA")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+
+	note, err := notesRepo.AddNote(tasks[0].UUID, "operator1", "called prefecture, awaiting callback")
+	require.NoError(t, err)
+	assert.NotZero(t, note.ID)
+	assert.Equal(t, 11, note.EventNumber)
+	assert.Equal(t, "SRA", note.CentralID)
+
+	_, err = notesRepo.AddNote(tasks[1].UUID, "operator2", "team on the way")
+	require.NoError(t, err)
+
+	_, err = notesRepo.AddNote(uuid.New(), "operator1", "lost note")
+	assert.IsType(t, &TaskNotFoundError{}, err)
+
+	taskNotes, err := notesRepo.GetNotesByTask(tasks[0].UUID)
+	require.NoError(t, err)
+	require.Len(t, taskNotes, 1)
+	assert.Equal(t, "called prefecture, awaiting callback", taskNotes[0].Body)
+
+	eventNotes, err := notesRepo.GetNotesByEvent(11, "SRA")
+	require.NoError(t, err)
+	assert.Len(t, eventNotes, 2)
+
+	attachment, err := notesRepo.AddAttachment(TaskAttachment{
+		TaskUUID:    tasks[1].UUID,
+		EventNumber: 11,
+		CentralID:   "SRA",
+		UploadedBy:  "operator2",
+		Filename:    "scene.jpg",
+		StoredName:  "SRA/11/scene.jpg",
+		ContentType: "image/jpeg",
+		Size:        42,
+	})
+	require.NoError(t, err)
+
+	stored, err := notesRepo.GetAttachment(attachment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "SRA/11/scene.jpg", stored.StoredName)
+
+	_, err = notesRepo.GetAttachment(attachment.ID + 1)
+	assert.IsType(t, &AttachmentNotFoundError{}, err)
+
+	eventAttachments, err := notesRepo.GetAttachmentsByEvent(11, "SRA")
+	require.NoError(t, err)
+	assert.Len(t, eventAttachments, 1)
+}
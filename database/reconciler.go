@@ -0,0 +1,334 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"context"
+	"dogeplus-backend/broadcast"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// TopicEscalationLevelsUpdate is the topic for broadcasting EscalationLevels corrections
+	TopicEscalationLevelsUpdate = "escalation_levels_update"
+)
+
+// TaskCompletionDrift is an event whose in memory completion info differs from the db.
+// Memory or Db is nil when the event is missing on that side.
+type TaskCompletionDrift struct {
+	EventNumber int                 `json:"event_number"`
+	Memory      *TaskCompletionInfo `json:"memory"`
+	Db          *TaskCompletionInfo `json:"db"`
+}
+
+// EscalationLevelDrift is an event whose in memory escalation level differs from the db.
+// Memory or Db is empty when the event is missing on that side.
+type EscalationLevelDrift struct {
+	EventNumber int   `json:"event_number"`
+	Memory      Level `json:"memory"`
+	Db          Level `json:"db"`
+}
+
+// ReconcileReport lists the drifts corrected by a reconciliation run
+type ReconcileReport struct {
+	ReconciledAt     time.Time              `json:"reconciled_at"`
+	TaskCompletion   []TaskCompletionDrift  `json:"task_completion"`
+	EscalationLevels []EscalationLevelDrift `json:"escalation_levels"`
+}
+
+// Drifted reports whether any aggregation had to be corrected
+func (r ReconcileReport) Drifted() bool {
+	return len(r.TaskCompletion) > 0 || len(r.EscalationLevels) > 0
+}
+
+// Reconciler recomputes the in memory TaskCompletionMap and EscalationLevels aggregations from the db
+// and corrects them when they drifted, e.g. because a task went back from done to notdone.
+type Reconciler struct {
+	mu               sync.Mutex
+	activeEvents     *ActiveEventsRepository
+	overview         *OverviewRepository
+	taskCompletion   *TaskCompletionMap
+	escalationLevels *EscalationLevels
+	cm               *broadcast.ConnectionManager
+}
+
+// NewReconciler creates a Reconciler for the aggregations of repos, corrections are broadcast through cm.
+func NewReconciler(repos *Repositories, cm *broadcast.ConnectionManager) *Reconciler {
+	return &Reconciler{
+		activeEvents:     repos.ActiveEvents,
+		overview:         repos.Overview,
+		taskCompletion:   repos.TaskCompletionAggregation,
+		escalationLevels: repos.EscalationLevelsAggregation,
+		cm:               cm,
+	}
+}
+
+// expectedTaskCompletion computes the task completion of every open event from the db
+func (r *Reconciler) expectedTaskCompletion() (map[int]TaskCompletionInfo, error) {
+	events, err := r.activeEvents.GetAggregatedEventStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(map[int]TaskCompletionInfo, len(events))
+	for _, event := range events {
+		expected[event.EventNumber] = TaskCompletionInfo{Completed: event.Done, Total: event.Total}
+	}
+
+	return expected, nil
+}
+
+// expectedEscalationLevels computes the escalation level of every open event from the db.
+// The overview level is the current one, as it follows escalations and de-escalations,
+// events without an overview fall back to the highest level of their tasks like at startup.
+func (r *Reconciler) expectedEscalationLevels() (map[int]Level, error) {
+	rawLevels, err := r.activeEvents.GetRawEscalationLevels()
+	if err != nil {
+		return nil, err
+	}
+
+	taskLevels, err := convertDbResultToData(rawLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := NewEscalationLevels()
+	for eventNumber, levels := range taskLevels {
+		for _, level := range levels {
+			expected.Add(eventNumber, level)
+		}
+	}
+
+	overviews, err := r.overview.GetAllOverview()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, overview := range overviews {
+		level := Level(overview.Level)
+		if level.valid() {
+			expected.Levels[overview.EventNumber] = level
+		}
+	}
+
+	return expected.Levels, nil
+}
+
+// Reconcile compares the in memory aggregations with the db and replaces the values that drifted.
+// Every drift is logged and the corrected aggregations are broadcast to their topics.
+// Only the values unchanged since before the db was read are replaced, so updates applied in the meantime are kept,
+// the next run checks them.
+func (r *Reconciler) Reconcile() (ReconcileReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := ReconcileReport{
+		ReconciledAt:     time.Now(),
+		TaskCompletion:   []TaskCompletionDrift{},
+		EscalationLevels: []EscalationLevelDrift{},
+	}
+
+	// Values changing after the snapshot are updated concurrently and must not be reverted
+	completionBefore := r.taskCompletion.snapshot()
+	levelsBefore := r.escalationLevels.GetLevels()
+
+	expectedCompletion, err := r.expectedTaskCompletion()
+	if err != nil {
+		return report, err
+	}
+
+	expectedLevels, err := r.expectedEscalationLevels()
+	if err != nil {
+		return report, err
+	}
+
+	report.TaskCompletion = r.taskCompletion.replace(completionBefore, expectedCompletion)
+	report.EscalationLevels = r.escalationLevels.replace(levelsBefore, expectedLevels)
+
+	for _, drift := range report.TaskCompletion {
+		log.Warnf("Task completion of event %d drifted: memory %s, db %s", drift.EventNumber, formatCompletion(drift.Memory), formatCompletion(drift.Db))
+	}
+	for _, drift := range report.EscalationLevels {
+		log.Warnf("Escalation level of event %d drifted: memory %q, db %q", drift.EventNumber, drift.Memory, drift.Db)
+	}
+
+	if r.cm != nil {
+		if len(report.TaskCompletion) > 0 {
+			broadcastTaskCompletion(r.cm, r.taskCompletion.snapshot())
+		}
+		if len(report.EscalationLevels) > 0 {
+			r.broadcastEscalationLevels(r.escalationLevels.GetLevels())
+		}
+	}
+
+	return report, nil
+}
+
+// Run reconciles the aggregations every interval until ctx is done.
+// A panicking reconciliation is logged and retried at the next tick.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcileRecovered()
+		}
+	}
+}
+
+// reconcileRecovered runs a scheduled reconciliation, logging its error or panic
+func (r *Reconciler) reconcileRecovered() {
+	// Recover from panics so a failed reconciliation doesn't stop the next ones
+	defer func() {
+		if p := recover(); p != nil {
+			log.Errorf("Recovered from panic reconciling aggregations: %v", p)
+		}
+	}()
+
+	if _, err := r.Reconcile(); err != nil {
+		log.Errorf("Error reconciling aggregations: %v", err)
+	}
+}
+
+// broadcastEscalationLevels sends the corrected escalation levels to the TopicEscalationLevelsUpdate topic
+func (r *Reconciler) broadcastEscalationLevels(levels map[int]Level) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type": "escalation_levels_update",
+		"data": levels,
+	})
+	if err != nil {
+		log.Errorf("Error marshalling escalation levels: %v", err)
+		return
+	}
+
+	r.cm.BroadcastToTopic(TopicEscalationLevelsUpdate, jsonData)
+}
+
+// formatCompletion formats a completion info of a drift for logging
+func formatCompletion(info *TaskCompletionInfo) string {
+	if info == nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%d/%d done", info.Completed, info.Total)
+}
+
+// snapshot returns a copy of the map content
+func (tcm *TaskCompletionMap) snapshot() map[int]TaskCompletionInfo {
+	tcm.mu.RLock()
+	defer tcm.mu.RUnlock()
+
+	data := make(map[int]TaskCompletionInfo, len(tcm.Data))
+	for eventNumber, info := range tcm.Data {
+		data[eventNumber] = info
+	}
+	return data
+}
+
+// replace sets the completion info of every event to data, unless it changed since before was taken,
+// and returns the events whose completion info was replaced, ordered by event number
+func (tcm *TaskCompletionMap) replace(before map[int]TaskCompletionInfo, data map[int]TaskCompletionInfo) []TaskCompletionDrift {
+	tcm.mu.Lock()
+	defer tcm.mu.Unlock()
+
+	drifts := []TaskCompletionDrift{}
+	events := make(map[int]bool)
+	for eventNumber := range tcm.Data {
+		events[eventNumber] = true
+	}
+	for eventNumber := range before {
+		events[eventNumber] = true
+	}
+	for eventNumber := range data {
+		events[eventNumber] = true
+	}
+
+	for _, eventNumber := range sortedEventNumbers(events) {
+		info, inMemory := tcm.Data[eventNumber]
+		seen, inSnapshot := before[eventNumber]
+		if inMemory != inSnapshot || info != seen {
+			continue
+		}
+
+		expected, inDb := data[eventNumber]
+		if inMemory == inDb && info == expected {
+			continue
+		}
+
+		drift := TaskCompletionDrift{EventNumber: eventNumber}
+		if inMemory {
+			drift.Memory = &info
+		}
+		if inDb {
+			drift.Db = &expected
+			tcm.Data[eventNumber] = expected
+		} else {
+			delete(tcm.Data, eventNumber)
+		}
+		drifts = append(drifts, drift)
+	}
+
+	return drifts
+}
+
+// replace sets the level of every event to levels, unless it changed since before was taken,
+// and returns the events whose level was replaced, ordered by event number
+func (el *EscalationLevels) replace(before map[int]Level, levels map[int]Level) []EscalationLevelDrift {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+
+	drifts := []EscalationLevelDrift{}
+	events := make(map[int]bool)
+	for eventNumber := range el.Levels {
+		events[eventNumber] = true
+	}
+	for eventNumber := range before {
+		events[eventNumber] = true
+	}
+	for eventNumber := range levels {
+		events[eventNumber] = true
+	}
+
+	for _, eventNumber := range sortedEventNumbers(events) {
+		level, inMemory := el.Levels[eventNumber]
+		seen, inSnapshot := before[eventNumber]
+		if inMemory != inSnapshot || level != seen {
+			continue
+		}
+
+		expected, inDb := levels[eventNumber]
+		if inMemory == inDb && level == expected {
+			continue
+		}
+
+		if inDb {
+			el.Levels[eventNumber] = expected
+		} else {
+			delete(el.Levels, eventNumber)
+		}
+		drifts = append(drifts, EscalationLevelDrift{EventNumber: eventNumber, Memory: level, Db: expected})
+	}
+
+	return drifts
+}
+
+// sortedEventNumbers returns the event numbers of a set, sorted
+func sortedEventNumbers(events map[int]bool) []int {
+	numbers := make([]int, 0, len(events))
+	for eventNumber := range events {
+		numbers = append(numbers, eventNumber)
+	}
+
+	sort.Ints(numbers)
+	return numbers
+}
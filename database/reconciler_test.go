@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestReconciler_Reconcile tests that drifted aggregations are corrected from the db and that a second run finds nothing to fix
+func TestReconciler_Reconcile(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repos := &Repositories{
+		ActiveEvents: NewActiveEventRepository(db),
+		Overview:     NewOverviewRepository(db),
+		TaskCompletionAggregation: &TaskCompletionMap{
+			Data: map[int]TaskCompletionInfo{
+				// Task went back from done to notdone, memory still counts it
+				1: {Completed: 1, Total: 2},
+				// Closed event left behind
+				9: {Completed: 3, Total: 3},
+			},
+		},
+		EscalationLevelsAggregation: NewEscalationLevels(),
+	}
+
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Task 2", EscalationLevel: EscalationEmergency},
+	}, 1, "SRA"))
+	require.NoError(t, repos.ActiveEvents.CreateFromTaskList([]Task{
+		{Priority: 1, Title: "Task 1", EscalationLevel: EscalationAlarm},
+	}, 2, "SRL"))
+
+	// Event 1 was de-escalated, the overview level wins over the tasks levels
+	require.NoError(t, repos.Overview.Add(&Overview{CentralId: "SRA", EventNumber: 1, Type: "test", Level: string(Allarme)}))
+	repos.EscalationLevelsAggregation.Add(1, Emergenza)
+
+	reconciler := NewReconciler(repos, nil)
+	report, err := reconciler.Reconcile()
+	require.NoError(t, err)
+
+	assert.True(t, report.Drifted())
+	assert.Equal(t, []TaskCompletionDrift{
+		{EventNumber: 1, Memory: &TaskCompletionInfo{Completed: 1, Total: 2}, Db: &TaskCompletionInfo{Completed: 0, Total: 2}},
+		{EventNumber: 2, Db: &TaskCompletionInfo{Completed: 0, Total: 1}},
+		{EventNumber: 9, Memory: &TaskCompletionInfo{Completed: 3, Total: 3}},
+	}, report.TaskCompletion)
+	assert.Equal(t, []EscalationLevelDrift{
+		{EventNumber: 1, Memory: Emergenza, Db: Allarme},
+		{EventNumber: 2, Db: Allarme},
+	}, report.EscalationLevels)
+
+	assert.Equal(t, map[int]TaskCompletionInfo{1: {Completed: 0, Total: 2}, 2: {Completed: 0, Total: 1}}, repos.TaskCompletionAggregation.Data)
+	assert.Equal(t, map[int]Level{1: Allarme, 2: Allarme}, repos.EscalationLevelsAggregation.GetLevels())
+
+	report, err = reconciler.Reconcile()
+	require.NoError(t, err)
+	assert.False(t, report.Drifted())
+}
+
+// TestReconciler_Run_RecoversFromPanic tests that a panicking reconciliation doesn't stop the loop
+func TestReconciler_Run_RecoversFromPanic(t *testing.T) {
+	// Without repositories every reconciliation panics
+	reconciler := &Reconciler{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		reconciler.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() didn't return after ctx was done")
+	}
+}
+
+// TestReconciler_Replace_KeepsConcurrentUpdates tests that values changed between the db read and the replacement
+// are not reverted to the values read from the db
+func TestReconciler_Replace_KeepsConcurrentUpdates(t *testing.T) {
+	taskCompletion := &TaskCompletionMap{Data: map[int]TaskCompletionInfo{1: {Completed: 1, Total: 2}, 2: {Completed: 0, Total: 1}}}
+	escalationLevels := NewEscalationLevels()
+	escalationLevels.Add(1, Allarme)
+	escalationLevels.Add(2, Allarme)
+
+	completionBefore := taskCompletion.snapshot()
+	levelsBefore := escalationLevels.GetLevels()
+
+	// The db is read, meanwhile a task of event 1 is done, event 1 is escalated and event 3 is opened
+	expectedCompletion := map[int]TaskCompletionInfo{1: {Completed: 1, Total: 2}, 2: {Completed: 1, Total: 1}}
+	expectedLevels := map[int]Level{1: Allarme, 2: Emergenza}
+	taskCompletion.SetEvent(1, TaskCompletionInfo{Completed: 2, Total: 2})
+	taskCompletion.SetEvent(3, TaskCompletionInfo{Completed: 0, Total: 4})
+	escalationLevels.Add(1, Emergenza)
+	escalationLevels.Add(3, Allarme)
+
+	assert.Equal(t, []TaskCompletionDrift{
+		{EventNumber: 2, Memory: &TaskCompletionInfo{Completed: 0, Total: 1}, Db: &TaskCompletionInfo{Completed: 1, Total: 1}},
+	}, taskCompletion.replace(completionBefore, expectedCompletion))
+	assert.Equal(t, []EscalationLevelDrift{
+		{EventNumber: 2, Memory: Allarme, Db: Emergenza},
+	}, escalationLevels.replace(levelsBefore, expectedLevels))
+
+	assert.Equal(t, map[int]TaskCompletionInfo{1: {Completed: 2, Total: 2}, 2: {Completed: 1, Total: 1}, 3: {Completed: 0, Total: 4}}, taskCompletion.Data)
+	assert.Equal(t, map[int]Level{1: Emergenza, 2: Emergenza, 3: Allarme}, escalationLevels.GetLevels())
+}
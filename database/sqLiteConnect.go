@@ -7,6 +7,7 @@ package database
 import (
 	"database/sql"
 	"dogeplus-backend/config"
+	"dogeplus-backend/errors"
 	"github.com/gofiber/fiber/v2/log"
 	_ "github.com/mattn/go-sqlite3"
 	"sync"
@@ -33,7 +34,8 @@ func retry(maxRetries int, delay time.Duration, fn func() error) error {
 
 // GetInstance returns a singleton instance of *sql.DB and an error.
 // If the instance has already been created, it returns the existing one.
-// If the instance hasn't been created yet, it creates a new one using the specified database driver and connection string.
+// If the instance hasn't been created yet, it creates a new one using the specified database driver and connection string
+// and applies every pending schema migration.
 func GetInstance(configFile config.Config) (*sql.DB, error) {
 	var initErr error
 	db := config.GetEnvWithFallback(configFile, config.DbFile)
@@ -60,12 +62,12 @@ func GetInstance(configFile config.Config) (*sql.DB, error) {
 
 			log.Info("Db connection established")
 
-			// Create table structure if not already exist
-			if err = createTables(instance); err != nil {
+			// Bring table structure up to date applying pending migrations
+			if err = migrate(instance); err != nil {
 				return err
 			}
 
-			log.Info("Db table structure created")
+			log.Info("Db schema up to date")
 			return nil
 		})
 	})
@@ -73,96 +75,14 @@ func GetInstance(configFile config.Config) (*sql.DB, error) {
 	return instance, initErr
 }
 
-// createTables creates the necessary tables in the provided *sql.DB instance.
-// It takes a transactional *sql.DB instance as an input parameter.
-// The function begins a transaction, executes the table creation commands,
-// and commits the transaction if all commands were successful.
-//
-// The table creation commands are defined in the `commands` slice and are executed one by one.
-// If any error occurs while executing a command, the transaction is rolled back and the error is returned.
-//
-// Upon successful execution of all commands, the transaction is committed and nil is returned.
-//
-// Note: This function assumes that the provided *sql.DB instance is already connected to the database.
-// The function does not check the connection status of the *sql.DB instance.
-func createTables(db *sql.DB) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	// Define table creation commands
-	commands := []string{
-		`CREATE TABLE IF NOT EXISTS test (id INTEGER PRIMARY KEY, data TEXT)`,
-
-		// Tasks table
-		`CREATE TABLE IF NOT EXISTS tasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT, 
-			priority INTEGER, 
-			title TEXT, 
-			description TEXT, 
-			role TEXT, 
-			category TEXT,
-			escalation_level TEXT CHECK ( escalation_level IN ('allarme','emergenza','incidente')),
-			incident_level TEXT CHECK ( incident_level IN ('','bianca', 'verde', 'gialla', 'rossa')))`,
-		// No trigger for task table
-
-		// Active events table
-		`CREATE TABLE IF NOT EXISTS active_events (
-			uuid TEXT PRIMARY KEY,
-			event_number INTEGER, 
-			event_date TEXT NOT NULL,
-			central_id TEXT CHECK ( central_id IN ('HQ','SRA','SRL','SRM','SRP')),
-			priority INTEGER, 
-			title TEXT,
-			description TEXT,
-			role TEXT,
-			status TEXT CHECK ( status IN ('notdone','working','done')), 
-			modified_by TEXT, 
-			ip_address TEXT DEFAULT '0.0.0.0',
-			timestamp TEXT,
-			escalation_level TEXT CHECK (escalation_level in ('allarme', 'emergenza', 'incidente')))`,
-
-		// Overview table
-		`create table IF NOT EXISTS overview(
-			uuid            text    not null
-				constraint overview_pk
-				primary key,
-			central_id      text    not null,
-			event_number    integer not null
-				constraint event_number_unique_ck
-				unique,
-			location        text    not null,
-			location_detail text,
-			type            text    not null,
-			level			text 	not null,
-			incident_level	text)`,
-
-		// Escalation levels definition table
-		`create table IF NOT EXISTS escalation_levels(
-			uuid        TEXT not null,
-			name        text not null,
-			description text,
-				constraint escalation_levels_pk
-				primary key (uuid));`,
+// Close checkpoints the write-ahead log, if any, into the main database file and closes the connection.
+// A failed checkpoint is only logged, the connection is closed anyway.
+func Close(db *sql.DB) error {
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		log.Warnf("Error checkpointing db before closing: %v", err)
 	}
 
-	// Execute each command within the transaction
-	for _, command := range commands {
-		_, err := tx.Exec(command)
-		if err != nil {
-			_ = tx.Rollback()
-			return err
-		}
-	}
-
-	// Commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return errors.Wrap(db.Close(), "failed to close db")
 }
 
 // Repositories represents a collection of different repositories for managing tasks and active events.
@@ -173,6 +93,16 @@ type Repositories struct {
 	TaskCompletionAggregation   *TaskCompletionMap
 	EscalationLevelsAggregation *EscalationLevels
 	EscalationLevelsDefinition  *EscalationLevelsDefinitionRepository
+	Archive                     *ArchiveRepository
+	History                     *HistoryRepository
+	Centrals                    *CentralsRepository
+	Notes                       *NotesRepository
+	Users                       *UsersRepository
+	Health                      *HealthRepository
+	Revisions                   *EventRevisionsRepository
+	Escalation                  *EscalationService
+	EscalationHistory           *EscalationHistoryRepository
+	Transfer                    *EventTransferService
 }
 
 // NewRepositories initializes a new instance of Repositories with the provided *sql.DB object.
@@ -183,6 +113,19 @@ func NewRepositories(db *sql.DB) *Repositories {
 		ActiveEvents:               NewActiveEventRepository(db),
 		Overview:                   NewOverviewRepository(db),
 		EscalationLevelsDefinition: NewEscalationLevelsDefinitionRepository(db),
+		Archive:                    NewArchiveRepository(db),
+		History:                    NewHistoryRepository(db),
+		Centrals:                   NewCentralsRepository(db),
+		Notes:                      NewNotesRepository(db),
+		Users:                      NewUsersRepository(db),
+		Health:                     NewHealthRepository(db),
+		Revisions:                  NewEventRevisionsRepository(db),
+		EscalationHistory:          NewEscalationHistoryRepository(db),
+	}
+
+	// load the level model used by filtering, merging and validation, the aggregations rely on it too
+	if _, err := repos.EscalationLevelsDefinition.LoadModel(); err != nil {
+		log.Fatal(err)
 	}
 
 	// initialize aggregation map using data from db trough repos
@@ -210,5 +153,9 @@ func NewRepositories(db *sql.DB) *Repositories {
 	repos.TaskCompletionAggregation = GetTaskCompletionMapInstance(initialTaskAggregation, nil)
 	repos.EscalationLevelsAggregation = initialEscalationLevelsAggregation
 
+	// escalations, merges and transfers update the aggregations once committed
+	repos.Escalation = NewEscalationService(repos)
+	repos.Transfer = NewEventTransferService(repos)
+
 	return repos
 }
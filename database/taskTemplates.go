@@ -0,0 +1,281 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+//
+// This file contains the main task template versioning:
+// - every upload or rollback of the main tasks is stored as a new numbered version
+// - the tasks table always holds a copy of the latest version
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type TemplateVersionNotFoundError struct {
+	Detail string
+}
+
+func (e TemplateVersionNotFoundError) Error() string {
+	return fmt.Sprintf("template version not found: %s", e.Detail)
+}
+
+// TaskTemplateVersion represents a stored version of the main task template.
+// RestoredFrom is set when the version was created by rolling back to an earlier one.
+type TaskTemplateVersion struct {
+	Version      int       `json:"version"`
+	UploadedBy   string    `json:"uploaded_by"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+	Filename     string    `json:"filename"`
+	Checksum     string    `json:"checksum"`
+	TaskCount    int       `json:"task_count"`
+	RestoredFrom int       `json:"restored_from,omitempty"`
+}
+
+// TemplateTaskChange represents a task present in both compared versions with different content
+type TemplateTaskChange struct {
+	Before Task `json:"before"`
+	After  Task `json:"after"`
+}
+
+// TemplateDiff represents the task by task differences between two template versions.
+// Tasks are matched by category, escalation level, incident level and title.
+type TemplateDiff struct {
+	From    int                  `json:"from"`
+	To      int                  `json:"to"`
+	Added   []Task               `json:"added"`
+	Removed []Task               `json:"removed"`
+	Changed []TemplateTaskChange `json:"changed"`
+}
+
+// TemplateChecksum returns the hex encoded SHA-256 checksum of an uploaded template file
+func TemplateChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// currentTemplateVersion returns the latest template version, 0 if no template has been stored yet.
+func currentTemplateVersion(tx *sql.Tx) (int, error) {
+	var version int
+	err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM task_template_versions`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read current template version: %w", err)
+	}
+
+	return version, nil
+}
+
+// SaveTemplateVersion stores the given tasks as a new template version within the transaction.
+// restoredFrom is the version being restored by a rollback, 0 for a regular upload.
+// It returns the stored version.
+func (trx *TaskRepositoryTransaction) SaveTemplateVersion(tasks []Task, uploadedBy, filename, checksum string, restoredFrom int) (TaskTemplateVersion, error) {
+	version := TaskTemplateVersion{
+		UploadedBy:   uploadedBy,
+		UploadedAt:   time.Now(),
+		Filename:     filename,
+		Checksum:     checksum,
+		TaskCount:    len(tasks),
+		RestoredFrom: restoredFrom,
+	}
+
+	result, err := trx.Exec(`INSERT INTO task_template_versions (uploaded_by, uploaded_at, filename, checksum, task_count, restored_from)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, 0))`,
+		version.UploadedBy, version.UploadedAt, version.Filename, version.Checksum, version.TaskCount, version.RestoredFrom)
+	if err != nil {
+		return TaskTemplateVersion{}, fmt.Errorf("failed to add template version: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return TaskTemplateVersion{}, fmt.Errorf("failed to get template version: %v", err)
+	}
+	version.Version = int(id)
+
+	for _, task := range tasks {
+		_, err = trx.Exec(`INSERT INTO task_template_tasks (version, priority, title, description, role, category, escalation_level, incident_level)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			version.Version, task.Priority, task.Title, task.Description, task.Role, task.Category, task.EscalationLevel, task.IncidentLevel)
+		if err != nil {
+			return TaskTemplateVersion{}, fmt.Errorf("failed to insert template task: %v", err)
+		}
+	}
+
+	return version, nil
+}
+
+// GetTemplateVersions retrieves all the stored template versions, newest first.
+func (t *TaskRepository) GetTemplateVersions() ([]TaskTemplateVersion, error) {
+	rows, err := t.db.Query(`SELECT version, uploaded_by, uploaded_at, filename, checksum, task_count, COALESCE(restored_from, 0)
+			FROM task_template_versions ORDER BY version DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query template versions: %v", err)
+	}
+	defer rows.Close()
+
+	versions := []TaskTemplateVersion{}
+	for rows.Next() {
+		version, err := scanTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetTemplateVersion retrieves a single template version.
+// It returns a TemplateVersionNotFoundError if the version does not exist.
+func (t *TaskRepository) GetTemplateVersion(version int) (TaskTemplateVersion, error) {
+	row := t.db.QueryRow(`SELECT version, uploaded_by, uploaded_at, filename, checksum, task_count, COALESCE(restored_from, 0)
+			FROM task_template_versions WHERE version = ?`, version)
+
+	templateVersion, err := scanTemplateVersion(row)
+	if err == sql.ErrNoRows {
+		return TaskTemplateVersion{}, &TemplateVersionNotFoundError{Detail: fmt.Sprintf("version %d", version)}
+	}
+
+	return templateVersion, err
+}
+
+// GetTemplateVersionTasks retrieves the tasks of a template version, in upload order.
+// It returns a TemplateVersionNotFoundError if the version does not exist.
+func (t *TaskRepository) GetTemplateVersionTasks(version int) ([]Task, error) {
+	if _, err := t.GetTemplateVersion(version); err != nil {
+		return nil, err
+	}
+
+	rows, err := t.db.Query(`SELECT priority, title, description, role, category, escalation_level, incident_level
+			FROM task_template_tasks WHERE version = ? ORDER BY rowid`, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query template tasks: %v", err)
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		var task Task
+		if err := rows.Scan(&task.Priority, &task.Title, &task.Description, &task.Role, &task.Category,
+			&task.EscalationLevel, &task.IncidentLevel); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// DiffTemplateVersions compares two template versions task by task.
+// A task only in "to" is added, a task only in "from" is removed, a task in both with a different
+// priority, description or role is changed.
+func (t *TaskRepository) DiffTemplateVersions(from, to int) (TemplateDiff, error) {
+	fromTasks, err := t.GetTemplateVersionTasks(from)
+	if err != nil {
+		return TemplateDiff{}, err
+	}
+	toTasks, err := t.GetTemplateVersionTasks(to)
+	if err != nil {
+		return TemplateDiff{}, err
+	}
+
+	diff := TemplateDiff{From: from, To: to, Added: []Task{}, Removed: []Task{}, Changed: []TemplateTaskChange{}}
+
+	fromByKey := make(map[string]Task, len(fromTasks))
+	for _, task := range fromTasks {
+		fromByKey[templateTaskKey(task)] = task
+	}
+
+	toKeys := make(map[string]bool, len(toTasks))
+	for _, task := range toTasks {
+		key := templateTaskKey(task)
+		toKeys[key] = true
+
+		before, exists := fromByKey[key]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, task)
+		case before != task:
+			diff.Changed = append(diff.Changed, TemplateTaskChange{Before: before, After: task})
+		}
+	}
+
+	// Keep removed tasks in the "from" upload order
+	for _, task := range fromTasks {
+		if !toKeys[templateTaskKey(task)] {
+			diff.Removed = append(diff.Removed, task)
+		}
+	}
+
+	return diff, nil
+}
+
+// RollbackTemplate restores the tasks of an earlier template version.
+// The restore is stored as a new version referencing the restored one, so the version history is never rewritten.
+// Tasks table reset and new version are written in a single transaction.
+func (t *TaskRepository) RollbackTemplate(version int, restoredBy string) (TaskTemplateVersion, error) {
+	source, err := t.GetTemplateVersion(version)
+	if err != nil {
+		return TaskTemplateVersion{}, err
+	}
+
+	tasks, err := t.GetTemplateVersionTasks(version)
+	if err != nil {
+		return TaskTemplateVersion{}, err
+	}
+
+	var restored TaskTemplateVersion
+	err = t.WithTransaction(func(trx *TaskRepositoryTransaction) error {
+		if err := trx.DropTasksTable(); err != nil {
+			return fmt.Errorf("failed to drop tasks table: %v", err)
+		}
+
+		if err := trx.BulkAdd(tasks); err != nil {
+			return fmt.Errorf("failed to add tasks to database: %v", err)
+		}
+
+		var err error
+		restored, err = trx.SaveTemplateVersion(tasks, restoredBy, source.Filename, source.Checksum, source.Version)
+		return err
+	})
+	if err != nil {
+		return TaskTemplateVersion{}, err
+	}
+
+	return restored, nil
+}
+
+// templateTaskKey identifies a task across template versions
+func templateTaskKey(task Task) string {
+	return task.Category + "|" + task.EscalationLevel + "|" + task.IncidentLevel + "|" + task.Title
+}
+
+// scanTemplateVersion scans a task_template_versions row, parsing the upload date
+func scanTemplateVersion(row rowScanner) (TaskTemplateVersion, error) {
+	var tmpUploadedAt string // upload date as string to be scanned to before parsing
+	var version TaskTemplateVersion
+
+	if err := row.Scan(&version.Version, &version.UploadedBy, &tmpUploadedAt, &version.Filename, &version.Checksum,
+		&version.TaskCount, &version.RestoredFrom); err != nil {
+		return TaskTemplateVersion{}, err
+	}
+
+	uploadedAt, err := time.Parse("2006-01-02 15:04:05.999999-07:00", tmpUploadedAt)
+	if err != nil {
+		return TaskTemplateVersion{}, fmt.Errorf("failed to parse upload date: %v", err)
+	}
+	version.UploadedAt = uploadedAt
+
+	return version, nil
+}
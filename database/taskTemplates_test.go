@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// uploadTemplate mimics a main task upload, resetting the tasks table and storing a new version
+func uploadTemplate(t *testing.T, repo *TaskRepository, tasks []Task, filename string) TaskTemplateVersion {
+	var version TaskTemplateVersion
+	require.NoError(t, repo.WithTransaction(func(trx *TaskRepositoryTransaction) error {
+		if err := trx.DropTasksTable(); err != nil {
+			return err
+		}
+		if err := trx.BulkAdd(tasks); err != nil {
+			return err
+		}
+		var err error
+		version, err = trx.SaveTemplateVersion(tasks, "admin", filename, TemplateChecksum([]byte(filename)), 0)
+		return err
+	}))
+	return version
+}
+
+// TestTaskRepository_TemplateVersions tests upload, diff and rollback of main task templates
+func TestTaskRepository_TemplateVersions(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	activeEventsRepo := NewActiveEventRepository(db)
+
+	v1Tasks := []Task{
+		{Priority: 1, Title: "Call", Role: "operator", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 2, Title: "Notify", Role: "operator", Category: "fire", EscalationLevel: EscalationAlarm},
+	}
+	v2Tasks := []Task{
+		{Priority: 1, Title: "Call", Role: "supervisor", Category: "fire", EscalationLevel: EscalationAlarm},
+		{Priority: 3, Title: "Evacuate", Role: "operator", Category: "fire", EscalationLevel: EscalationAlarm},
+	}
+
+	v1 := uploadTemplate(t, repo, v1Tasks, "v1.xlsx")
+	v2 := uploadTemplate(t, repo, v2Tasks, "v2.xlsx")
+	assert.Equal(t, v1.Version+1, v2.Version)
+
+	versions, err := repo.GetTemplateVersions()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, v2.Version, versions[0].Version, "Newest version should come first")
+	assert.Equal(t, "v2.xlsx", versions[0].Filename)
+	assert.Equal(t, 2, versions[0].TaskCount)
+
+	// Events record the template version they are built from
+	require.NoError(t, activeEventsRepo.CreateFromTaskList(v2Tasks, 11, "SRA"))
+	tasks, err := activeEventsRepo.GetByCentralAndNumber(11, "SRA")
+	require.NoError(t, err)
+	assert.Equal(t, v2.Version, tasks[0].TemplateVersion)
+
+	diff, err := repo.DiffTemplateVersions(v1.Version, v2.Version)
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "Evacuate", diff.Added[0].Title)
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "Notify", diff.Removed[0].Title)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "operator", diff.Changed[0].Before.Role)
+	assert.Equal(t, "supervisor", diff.Changed[0].After.Role)
+
+	// Rollback restores the old tasks as a new version
+	restored, err := repo.RollbackTemplate(v1.Version, "supervisor")
+	require.NoError(t, err)
+	assert.Equal(t, v2.Version+1, restored.Version)
+	assert.Equal(t, v1.Version, restored.RestoredFrom)
+	assert.Equal(t, v1.Checksum, restored.Checksum)
+
+	current, err := repo.GetByCategories("fire")
+	require.NoError(t, err)
+	require.Len(t, current, 2)
+	assert.Equal(t, "Notify", current[1].Title)
+
+	_, err = repo.GetTemplateVersion(99)
+	_, ok := err.(*TemplateVersionNotFoundError)
+	assert.True(t, ok, "Expected TemplateVersionNotFoundError, got %T", err)
+}
+
+// TestTaskRepository_WithTransaction_Rollback tests that a failing upload leaves tasks and versions untouched
+func TestTaskRepository_WithTransaction_Rollback(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	uploadTemplate(t, repo, []Task{{Priority: 1, Title: "Call", Category: "fire", EscalationLevel: EscalationAlarm}}, "v1.xlsx")
+
+	err := repo.WithTransaction(func(trx *TaskRepositoryTransaction) error {
+		if err := trx.DropTasksTable(); err != nil {
+			return err
+		}
+		return fmt.Errorf("parse failure")
+	})
+	require.Error(t, err)
+
+	current, err := repo.GetByCategories("fire")
+	require.NoError(t, err)
+	assert.Len(t, current, 1, "Tasks should survive a failed upload")
+}
@@ -43,6 +43,33 @@ func padBlock(block []string, blockSize int) []string {
 	return paddedBlock
 }
 
+// forEachTaskBlock calls fn for every non-empty block of 5 columns of a sheet, skipping the first 2 header rows.
+// fn receives the row index, the block starting column index and the block padded to exactly 5 columns.
+func forEachTaskBlock(rows [][]string, fn func(i, j int, block []string)) {
+	// Iterate over each row in the sheet, skipping the first 2 rows
+	for i, row := range rows {
+		if i < 2 {
+			continue // Skip the first 2 rows
+		}
+
+		// Iterate in blocks of 5 columns starting from the first block
+		for j := 0; j < len(row); j += 5 {
+			// Ensure there's a block to process
+			block := row[j:min(j+5, len(row))]
+
+			// Pad the block to ensure it has exactly 5 columns
+			block = padBlock(block, 5)
+
+			// Skip if the block is empty
+			if isBlockEmpty(block) {
+				continue
+			}
+
+			fn(i, j, block)
+		}
+	}
+}
+
 // ParseXLSXToTasks converts an Excel file into a slice of Task instances, parsing data from each sheet and handling errors.
 func ParseXLSXToTasks(f *excelize.File) ([]Task, error) {
 	var tasks []Task
@@ -68,46 +95,27 @@ func ParseXLSXToTasks(f *excelize.File) ([]Task, error) {
 
 		headerRow := rows[0]
 
-		// Iterate over each row in the sheet, skipping the first 2 rows
-		for i, row := range rows {
-			if i < 2 {
-				continue // Skip the first 2 rows
+		forEachTaskBlock(rows, func(_, j int, block []string) {
+			// Fetch the role from the header row based on the block's starting column
+			role := ""
+			if j < len(headerRow) {
+				role = headerRow[j]
 			}
 
-			// Iterate in blocks of 5 columns starting from the first block
-			for j := 0; j < len(row); j += 5 {
-				// Ensure there's a block to process
-				block := row[j:min(j+5, len(row))]
-
-				// Pad the block to ensure it has exactly 5 columns
-				block = padBlock(block, 5)
-
-				// Skip if the block is empty
-				if isBlockEmpty(block) {
-					continue
-				}
-
-				// Fetch the role from the header row based on the block's starting column
-				role := ""
-				if j < len(headerRow) {
-					role = headerRow[j]
-				}
-
-				// Create a new Task struct with mapped fields
-				task := Task{
-					Category:        sheetName,
-					Role:            role,
-					Priority:        parsePriority(block[0]),   // Convert and map the priority
-					Title:           block[1],                  // Map the title field
-					Description:     block[2],                  // Map the description field
-					EscalationLevel: strings.ToLower(block[3]), // Map the escalation level field
-					IncidentLevel:   strings.ToLower(block[4]), // Map the incident level field
-				}
-
-				// Append the new task to the tasks slice
-				tasks = append(tasks, task)
+			// Create a new Task struct with mapped fields
+			task := Task{
+				Category:        sheetName,
+				Role:            role,
+				Priority:        parsePriority(block[0]),   // Convert and map the priority
+				Title:           block[1],                  // Map the title field
+				Description:     block[2],                  // Map the description field
+				EscalationLevel: strings.ToLower(block[3]), // Map the escalation level field
+				IncidentLevel:   strings.ToLower(block[4]), // Map the incident level field
 			}
-		}
+
+			// Append the new task to the tasks slice
+			tasks = append(tasks, task)
+		})
 	}
 
 	return tasks, nil
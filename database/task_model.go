@@ -18,21 +18,7 @@ type Task struct {
 
 const PRO22 = "pro22"
 
-// GetEscalationLevels returns a slice of escalation levels in order of severity.
+// GetEscalationLevels returns a slice of escalation levels in order of severity, as defined by the level model.
 func GetEscalationLevels() []string {
-	return []string{"allarme", "emergenza", "incidente"}
-}
-
-// Escalation and incident level maps for comparison
-var escalationLevels = map[string]int{
-	"allarme":   1,
-	"emergenza": 2,
-	"incidente": 3,
-}
-
-var incidentLevels = map[string]int{
-	"bianca": 1,
-	"verde":  2,
-	"gialla": 3,
-	"rossa":  4,
+	return CurrentLevelModel().Levels()
 }
@@ -0,0 +1,66 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+// Constants representing where a task of a new event comes from
+const (
+	TaskOriginMain          = "main"
+	TaskOriginLocalOverride = "local_override"
+	TaskOriginLocalAddition = "local_addition"
+	TaskOriginLocalRemoval  = "local_removal"
+)
+
+// TaskPreview represents a task a new event would be created with, annotated with its origin.
+// Tasks with TaskOriginLocalRemoval are main tasks removed by the local task file, they are not created.
+type TaskPreview struct {
+	Task
+	Origin string `json:"origin"`
+}
+
+// AnnotateTaskOrigins annotates the result of MergeTasks with the origin of every task.
+// main and local are the filtered main and local tasks given to MergeTasks, merged its result.
+// Main tasks removed by a local title only row are appended with TaskOriginLocalRemoval.
+func AnnotateTaskOrigins(main, local, merged []Task) []TaskPreview {
+	mainByTitle := make(map[string]Task, len(main))
+	for _, task := range main {
+		if _, exists := mainByTitle[task.Title]; !exists {
+			mainByTitle[task.Title] = task
+		}
+	}
+
+	localTitles := make(map[string]bool, len(local))
+	for _, task := range local {
+		if !IsRemovalTask(task) {
+			localTitles[task.Title] = true
+		}
+	}
+
+	previews := make([]TaskPreview, 0, len(merged))
+	mergedTitles := make(map[string]bool, len(merged))
+	for _, task := range merged {
+		mergedTitles[task.Title] = true
+
+		_, inMain := mainByTitle[task.Title]
+		origin := TaskOriginMain
+		switch {
+		case inMain && localTitles[task.Title]:
+			origin = TaskOriginLocalOverride
+		case localTitles[task.Title]:
+			origin = TaskOriginLocalAddition
+		}
+
+		previews = append(previews, TaskPreview{Task: task, Origin: origin})
+	}
+
+	for _, task := range local {
+		removed, inMain := mainByTitle[task.Title]
+		if IsRemovalTask(task) && inMain && !mergedTitles[task.Title] {
+			previews = append(previews, TaskPreview{Task: removed, Origin: TaskOriginLocalRemoval})
+			mergedTitles[task.Title] = true // report every removed task once
+		}
+	}
+
+	return previews
+}
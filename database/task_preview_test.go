@@ -0,0 +1,45 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotateTaskOrigins(t *testing.T) {
+	main := []Task{
+		{Priority: 1, Title: "kept", Category: "cat1", EscalationLevel: "allarme"},
+		{Priority: 2, Title: "overridden", Category: "cat1", EscalationLevel: "allarme"},
+		{Priority: 3, Title: "removed", Category: "cat1", EscalationLevel: "allarme"},
+	}
+	local := []Task{
+		{Priority: 5, Title: "overridden", Description: "local", Category: "cat1", EscalationLevel: "allarme"},
+		{Priority: 6, Title: "added", Category: "cat1", EscalationLevel: "emergenza"},
+		{Title: "removed", Category: "cat1", Role: "Role1"},
+		{Title: "not in main", Category: "cat1"},
+	}
+
+	merged, err := MergeTasks(main, local)
+	if err != nil {
+		t.Fatalf("MergeTasks() error = %v", err)
+	}
+
+	got := AnnotateTaskOrigins(main, local, merged)
+	want := []TaskPreview{
+		{Task: main[0], Origin: TaskOriginMain},
+		{Task: local[0], Origin: TaskOriginLocalOverride},
+		{Task: local[1], Origin: TaskOriginLocalAddition},
+		{Task: main[2], Origin: TaskOriginLocalRemoval},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AnnotateTaskOrigins() = %+v, want %+v", got, want)
+	}
+
+	// Without a local file every task comes from the main template
+	got = AnnotateTaskOrigins(main, nil, main)
+	for _, preview := range got {
+		if preview.Origin != TaskOriginMain {
+			t.Errorf("task %s origin = %s, want %s", preview.Title, preview.Origin, TaskOriginMain)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strconv"
+	"strings"
+)
+
+// Constants representing the severity of a validation issue.
+// Errors make the workbook unsuitable for upload, warnings point out suspicious but accepted content.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// taskBlockFields maps the offset of a column inside a 5 columns task block to the task field it holds
+var taskBlockFields = []string{"priority", "title", "description", "escalation_level", "incident_level"}
+
+// ValidationIssue represents a single problem found in a task workbook.
+// Row is the 1-based spreadsheet row and Column the spreadsheet column name, both empty for sheet level issues.
+type ValidationIssue struct {
+	Row      int    `json:"row,omitempty"`
+	Column   string `json:"column,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// SheetValidationReport represents the validation result of a single sheet (task category)
+type SheetValidationReport struct {
+	Sheet  string            `json:"sheet"`
+	Tasks  int               `json:"tasks"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// TaskValidationReport represents the validation result of a whole task workbook.
+// Valid is true when no error has been found, warnings don't invalidate the workbook.
+type TaskValidationReport struct {
+	Valid    bool                    `json:"valid"`
+	Tasks    int                     `json:"tasks"`
+	Errors   int                     `json:"errors"`
+	Warnings int                     `json:"warnings"`
+	Issues   []ValidationIssue       `json:"issues"`
+	Sheets   []SheetValidationReport `json:"sheets"`
+}
+
+// addIssue appends an issue to the sheet report, keeping the report counters in sync
+func (r *TaskValidationReport) addIssue(sheet *SheetValidationReport, issue ValidationIssue) {
+	if sheet == nil {
+		r.Issues = append(r.Issues, issue)
+	} else {
+		sheet.Issues = append(sheet.Issues, issue)
+	}
+
+	if issue.Severity == SeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+// ValidateXLSXTasks checks a task workbook with the same layout expected by ParseXLSXToTasks
+// and returns a per sheet, per row and per column report of errors and warnings.
+// It never touches the database.
+func ValidateXLSXTasks(f *excelize.File) TaskValidationReport {
+	return validateXLSXTasks(f, false)
+}
+
+// ValidateLocalXLSXTasks checks a central local task workbook like ValidateXLSXTasks,
+// additionally accepting title only rows, used by MergeTasks to remove a main task.
+func ValidateLocalXLSXTasks(f *excelize.File) TaskValidationReport {
+	return validateXLSXTasks(f, true)
+}
+
+// validateXLSXTasks implements ValidateXLSXTasks and ValidateLocalXLSXTasks,
+// allowRemovals accepts rows with only the title populated.
+func validateXLSXTasks(f *excelize.File, allowRemovals bool) TaskValidationReport {
+	report := TaskValidationReport{Issues: []ValidationIssue{}, Sheets: []SheetValidationReport{}}
+
+	sheetList := f.GetSheetList()
+	if len(sheetList) == 0 {
+		report.addIssue(nil, ValidationIssue{Severity: SeverityError, Message: "the file does not contain any sheets"})
+	}
+
+	for _, sheetName := range sheetList {
+		sheet := SheetValidationReport{Sheet: sheetName, Issues: []ValidationIssue{}}
+
+		rows, err := f.GetRows(sheetName)
+		if err != nil {
+			report.addIssue(&sheet, ValidationIssue{Severity: SeverityError, Message: fmt.Sprintf("failed to get rows: %v", err)})
+			report.Sheets = append(report.Sheets, sheet)
+			continue
+		}
+
+		if len(rows) < 3 {
+			report.addIssue(&sheet, ValidationIssue{Severity: SeverityError,
+				Message: "the sheet does not have the required structure (at least 3 rows needed)"})
+			report.Sheets = append(report.Sheets, sheet)
+			continue
+		}
+
+		headerRow := rows[0]
+		missingRoles := make(map[int]bool)
+		seenTitles := make(map[string]string) // title -> "escalation|incident" key and position of first occurrence
+
+		forEachTaskBlock(rows, func(i, j int, block []string) {
+			sheet.Tasks++
+			rowNumber := i + 1
+			issue := func(offset int, severity, message string) {
+				column, _ := excelize.ColumnNumberToName(j + offset + 1)
+				report.addIssue(&sheet, ValidationIssue{Row: rowNumber, Column: column, Field: taskBlockFields[offset],
+					Severity: severity, Message: message})
+			}
+
+			// Role comes from the header row, report a missing one once per block column
+			if j >= len(headerRow) || strings.TrimSpace(headerRow[j]) == "" {
+				if !missingRoles[j] {
+					missingRoles[j] = true
+					column, _ := excelize.ColumnNumberToName(j + 1)
+					report.addIssue(&sheet, ValidationIssue{Row: 1, Column: column, Field: "role", Severity: SeverityError,
+						Message: "role header is missing"})
+				}
+			}
+
+			// Title only rows remove a main task from a local file, nothing else to check
+			if allowRemovals && strings.TrimSpace(block[1]) != "" && strings.TrimSpace(block[0]) == "" &&
+				strings.TrimSpace(block[2]) == "" && strings.TrimSpace(block[3]) == "" && strings.TrimSpace(block[4]) == "" {
+				return
+			}
+
+			// Priority
+			priority := strings.TrimSpace(block[0])
+			if priority == "" {
+				issue(0, SeverityWarning, "priority is empty, it will default to 0")
+			} else if _, err := strconv.Atoi(priority); err != nil {
+				issue(0, SeverityError, fmt.Sprintf("priority %q is not a number", block[0]))
+			}
+
+			// Title
+			title := strings.TrimSpace(block[1])
+			if title == "" {
+				issue(1, SeverityError, "title is empty")
+			}
+
+			// Escalation and incident levels
+			escalationLevel := strings.ToLower(strings.TrimSpace(block[3]))
+			incidentLevel := strings.ToLower(strings.TrimSpace(block[4]))
+			levelModel := CurrentLevelModel()
+			if !levelModel.IsLevel(escalationLevel) {
+				issue(3, SeverityError, fmt.Sprintf("escalation level %q is not valid, expected one of %s",
+					block[3], strings.Join(levelModel.Levels(), ", ")))
+			}
+			if incidentLevel != "" {
+				if !levelModel.HasSubLevels(escalationLevel) {
+					if levelModel.IsAnySubLevel(incidentLevel) {
+						issue(4, SeverityWarning, fmt.Sprintf("incident level is ignored when escalation level is not %s",
+							strings.Join(levelModel.LevelsWithSubLevels(), " or ")))
+					} else {
+						issue(4, SeverityError, fmt.Sprintf("incident level %q is not valid", block[4]))
+					}
+				} else if !levelModel.IsSubLevel(escalationLevel, incidentLevel) {
+					issue(4, SeverityError, fmt.Sprintf("incident level %q is not valid, expected one of %s",
+						block[4], strings.Join(levelModel.SubLevels(escalationLevel), ", ")))
+				}
+			} else if levelModel.HasSubLevels(escalationLevel) {
+				issue(4, SeverityWarning, "incident level is empty, the task will be included at every incident level")
+			}
+
+			// Duplicated titles within the same category
+			if title != "" {
+				levelKey := escalationLevel + "|" + incidentLevel
+				if first, exists := seenTitles[title]; exists {
+					firstLevelKey, firstRow, _ := strings.Cut(first, "@")
+					if firstLevelKey == levelKey {
+						issue(1, SeverityError, fmt.Sprintf("title %q is duplicated, first found at row %s", title, firstRow))
+					} else {
+						issue(1, SeverityWarning, fmt.Sprintf("title %q is repeated at a different level, first found at row %s, only the highest level is kept when filtering", title, firstRow))
+					}
+				} else {
+					seenTitles[title] = levelKey + "@" + strconv.Itoa(rowNumber)
+				}
+			}
+		})
+
+		report.Tasks += sheet.Tasks
+		report.Sheets = append(report.Sheets, sheet)
+	}
+
+	report.Valid = report.Errors == 0
+
+	return report
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"github.com/xuri/excelize/v2"
+	"testing"
+)
+
+// newValidationWorkbook builds an in memory workbook with a single sheet holding the given rows
+func newValidationWorkbook(t *testing.T, sheet string, rows [][]interface{}) *excelize.File {
+	t.Helper()
+
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		t.Fatalf("failed to rename sheet: %v", err)
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatalf("failed to set row %d: %v", i+1, err)
+		}
+	}
+
+	return f
+}
+
+func TestValidateXLSXTasks(t *testing.T) {
+	type wantIssue struct {
+		row      int
+		column   string
+		field    string
+		severity string
+	}
+
+	tests := []struct {
+		name      string
+		rows      [][]interface{}
+		wantValid bool
+		wantTasks int
+		want      []wantIssue
+	}{
+		{
+			name: "Valid workbook",
+			rows: [][]interface{}{
+				{"Medico"},
+				{"Priority", "Title", "Description", "Escalation", "Incident"},
+				{"1", "Task 1", "Desc 1", "allarme", ""},
+				{"2", "Task 2", "Desc 2", "Incidente", "rossa"},
+			},
+			wantValid: true,
+			wantTasks: 2,
+			want:      []wantIssue{},
+		},
+		{
+			name: "Row and column level errors",
+			rows: [][]interface{}{
+				{"Medico"},
+				{"Priority", "Title", "Description", "Escalation", "Incident"},
+				{"abc", "", "Desc 1", "allarme", ""},
+				{"1", "Task 2", "Desc 2", "maxi", "nera"},
+			},
+			wantValid: false,
+			wantTasks: 2,
+			want: []wantIssue{
+				{row: 3, column: "A", field: "priority", severity: SeverityError},
+				{row: 3, column: "B", field: "title", severity: SeverityError},
+				{row: 4, column: "D", field: "escalation_level", severity: SeverityError},
+				{row: 4, column: "E", field: "incident_level", severity: SeverityError},
+			},
+		},
+		{
+			name: "Missing role and duplicated titles",
+			rows: [][]interface{}{
+				{"Medico", "", "", "", "", ""},
+				{"Priority", "Title", "Description", "Escalation", "Incident"},
+				{"1", "Task 1", "Desc", "allarme", "", "1", "Task X", "Desc", "allarme", ""},
+				{"1", "Task 1", "Desc", "allarme", "", "", "Task 1", "Desc", "emergenza", ""},
+			},
+			wantValid: false,
+			wantTasks: 4,
+			want: []wantIssue{
+				{row: 1, column: "F", field: "role", severity: SeverityError},
+				{row: 4, column: "B", field: "title", severity: SeverityError},
+				{row: 4, column: "F", field: "priority", severity: SeverityWarning},
+				{row: 4, column: "G", field: "title", severity: SeverityWarning},
+			},
+		},
+		{
+			name: "Sheet without required structure",
+			rows: [][]interface{}{
+				{"Medico"},
+			},
+			wantValid: false,
+			wantTasks: 0,
+			want: []wantIssue{
+				{severity: SeverityError},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newValidationWorkbook(t, "Category", tt.rows)
+
+			report := ValidateXLSXTasks(f)
+			if report.Valid != tt.wantValid {
+				t.Errorf("Valid = %v, want %v", report.Valid, tt.wantValid)
+			}
+			if report.Tasks != tt.wantTasks {
+				t.Errorf("Tasks = %d, want %d", report.Tasks, tt.wantTasks)
+			}
+			if len(report.Sheets) != 1 {
+				t.Fatalf("got %d sheets, want 1", len(report.Sheets))
+			}
+
+			issues := report.Sheets[0].Issues
+			if len(issues) != len(tt.want) {
+				t.Fatalf("got issues %+v, want %d issues", issues, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := issues[i]
+				if got.Row != want.row || got.Column != want.column || got.Field != want.field || got.Severity != want.severity {
+					t.Errorf("issue %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLocalXLSXTasks(t *testing.T) {
+	rows := [][]interface{}{
+		{"Medico"},
+		{"Priority", "Title", "Description", "Escalation", "Incident"},
+		{"", "Main task to remove"},
+		{"1", "Local task", "Desc", "emergenza", ""},
+	}
+
+	if report := ValidateLocalXLSXTasks(newValidationWorkbook(t, "Category", rows)); !report.Valid || report.Warnings != 0 {
+		t.Errorf("ValidateLocalXLSXTasks() = %+v, want valid without warnings", report)
+	}
+
+	// Title only rows are not accepted in the main tasks file
+	if report := ValidateXLSXTasks(newValidationWorkbook(t, "Category", rows)); report.Valid {
+		t.Errorf("ValidateXLSXTasks() = %+v, want invalid", report)
+	}
+}
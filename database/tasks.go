@@ -13,7 +13,6 @@ package database
 import (
 	"database/sql"
 	"dogeplus-backend/config"
-	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -49,7 +48,8 @@ type TaskRepositoryTransaction struct {
 }
 
 // WithTransaction runs the queries wrapped in a transaction.
-func (t *TaskRepository) WithTransaction(fn func(*TaskRepositoryTransaction) error) error {
+// The transaction is rolled back if fn returns an error or panics, committed otherwise.
+func (t *TaskRepository) WithTransaction(fn func(*TaskRepositoryTransaction) error) (err error) {
 	tx, err := t.BeginTrans()
 	if err != nil {
 		return err
@@ -68,10 +68,8 @@ func (t *TaskRepository) WithTransaction(fn func(*TaskRepositoryTransaction) err
 		}
 	}()
 
-	if err := fn(trx); err != nil {
-		return err
-	}
-	return nil
+	err = fn(trx)
+	return err
 }
 
 // GetCategories retrieves distinct categories from the "tasks" table in the database.
@@ -154,13 +152,14 @@ func (t *TaskRepository) GetByCategoriesAndEscalationLevels(categories []string,
 // category: the category to filter tasks.
 // startingEscalation: the initial escalation level.
 // finalEscalation: the target escalation level.
-// incidentLevel: the level of incident to filter if the final escalation is 'incidente'.
+// incidentLevel: the level of incident to filter if the final escalation has sub-levels, like 'incidente'.
 // Returns a slice of Task and an error if any occur during query execution.
 func (t *TaskRepository) GetGyCategoryAndEscalationLevel(category, startingEscalation, finalEscalation, incidentLevel string) ([]Task, error) {
 	var tasks []Task
 
 	// The escalation levels ranked in order
-	rankedLevels := GetEscalationLevels()
+	levelModel := CurrentLevelModel()
+	rankedLevels := levelModel.Levels()
 	levelMap := make(map[string]int)
 	for i, level := range rankedLevels {
 		levelMap[level] = i
@@ -175,8 +174,9 @@ func (t *TaskRepository) GetGyCategoryAndEscalationLevel(category, startingEscal
 	}
 
 	// Check if incidentLevel is required and provided
-	if strings.ToLower(finalEscalation) == "incidente" && incidentLevel == "" {
-		return nil, fmt.Errorf("incident level must be provided when final escalation is 'incidente'")
+	final := strings.ToLower(finalEscalation)
+	if levelModel.HasSubLevels(final) && incidentLevel == "" {
+		return nil, fmt.Errorf("incident level must be provided when final escalation is '%s'", final)
 	}
 
 	// Adjust the indices for correct slicing
@@ -205,31 +205,26 @@ func (t *TaskRepository) GetGyCategoryAndEscalationLevel(category, startingEscal
 	// Append the escalation level placeholders into the query
 	query += ` AND (LOWER(escalation_level) IN (` + strings.Join(escPlaceholders, ", ") + `)`
 
-	// Handle 'incidente' specific case if incidentLevel is provided
-	if strings.ToLower(finalEscalation) == "incidente" {
-		incidentLevels := map[string]int{
-			"bianca": 0,
-			"verde":  1,
-			"gialla": 2,
-			"rossa":  3,
-		}
-		incidentIdx, incidentOk := incidentLevels[strings.ToLower(incidentLevel)]
-		if !incidentOk {
+	// Handle levels with sub-levels, like 'incidente', if incidentLevel is provided
+	if levelModel.HasSubLevels(final) {
+		incidentIdx := levelModel.SubRank(final, strings.ToLower(incidentLevel))
+		if incidentIdx == 0 {
 			return nil, fmt.Errorf("invalid incident level: %s", incidentLevel)
 		}
 
-		incidentLevelPlaceholders := []string{}
-		for level, idx := range incidentLevels {
-			if idx <= incidentIdx {
-				incidentLevelPlaceholders = append(incidentLevelPlaceholders, "?")
-				args = append(args, level)
-			}
+		// Sub-levels are ranked from 1, the ones up to incidentLevel are included
+		subLevels := levelModel.SubLevels(final)[:incidentIdx]
+		incidentLevelPlaceholders := make([]string, len(subLevels))
+		args = append(args, final)
+		for i, level := range subLevels {
+			incidentLevelPlaceholders[i] = "?"
+			args = append(args, level)
 		}
 
 		// Enclose the entire condition for incidents within parentheses
-		query += ` OR (LOWER(escalation_level) = 'incidente' AND LOWER(incident_level) IN (` + strings.Join(incidentLevelPlaceholders, ", ") + `)))`
+		query += ` OR (LOWER(escalation_level) = ? AND LOWER(incident_level) IN (` + strings.Join(incidentLevelPlaceholders, ", ") + `)))`
 	} else {
-		// End the escalation level condition, if no incident level is provided or escalation level is not incidente
+		// End the escalation level condition, if no incident level is provided or escalation level has no sub-levels
 		query += `)`
 	}
 
@@ -322,65 +317,96 @@ func (trx *TaskRepositoryTransaction) BulkAdd(tasks []Task) error {
 // It removes duplicates by keeping tasks with higher escalation/incident levels for the same title.
 func FilterTasks(tasks []Task, category, escalationLevel, incidentLevel string) []Task {
 	var filteredTasks []Task
+	levelModel := CurrentLevelModel()
 
 	for _, task := range tasks {
 		// Check if the task's category matches the input category or "pro22" (case-insensitive).
 		if strings.EqualFold(task.Category, category) || strings.EqualFold(task.Category, "pro22") {
 			// Ensure the task's escalation level is less than or equal to the input escalation level.
-			if escalationLevels[task.EscalationLevel] <= escalationLevels[escalationLevel] {
-				// If the input escalation level is "incidente", filter based on the incident level.
-				if strings.EqualFold(escalationLevel, "incidente") {
+			if levelModel.Rank(task.EscalationLevel) <= levelModel.Rank(escalationLevel) {
+				// If the input escalation level has sub-levels, like "incidente", filter based on the incident level.
+				if level := strings.ToLower(escalationLevel); levelModel.HasSubLevels(level) {
 					// Include the task if its incident level is less than or equal to the input incident level.
-					if incidentLevels[task.IncidentLevel] <= incidentLevels[incidentLevel] {
+					if levelModel.SubRank(level, task.IncidentLevel) <= levelModel.SubRank(level, incidentLevel) {
 						filteredTasks = append(filteredTasks, task)
 					}
 				} else {
-					// Include the task if the escalation level criteria is met and it's not "incidente".
+					// Include the task if the escalation level criteria is met and it has no sub-levels.
 					filteredTasks = append(filteredTasks, task)
 				}
 			}
 		}
 	}
 
-	// Create a map to store tasks by title, keeping only the one with higher escalation/incident level
+	// Create a map to store tasks by title, keeping only the one with higher escalation/incident level.
+	// Titles order keeps the first seen order, so tasks with the same priority are always returned in the same order
 	tasksByTitle := make(map[string]Task)
+	var titlesOrder []string
 	for _, task := range filteredTasks {
 		if existingTask, exists := tasksByTitle[task.Title]; exists {
 			// Compare escalation levels
-			if escalationLevels[task.EscalationLevel] > escalationLevels[existingTask.EscalationLevel] {
+			if levelModel.Rank(task.EscalationLevel) > levelModel.Rank(existingTask.EscalationLevel) {
 				tasksByTitle[task.Title] = task
-			} else if escalationLevels[task.EscalationLevel] == escalationLevels[existingTask.EscalationLevel] {
-				// If same escalation level and it's "incidente", compare incident levels
-				if strings.EqualFold(task.EscalationLevel, "incidente") {
-					if incidentLevels[task.IncidentLevel] > incidentLevels[existingTask.IncidentLevel] {
+			} else if levelModel.Rank(task.EscalationLevel) == levelModel.Rank(existingTask.EscalationLevel) {
+				// If same escalation level with sub-levels, like "incidente", compare incident levels
+				if level := strings.ToLower(task.EscalationLevel); levelModel.HasSubLevels(level) {
+					if levelModel.SubRank(level, task.IncidentLevel) > levelModel.SubRank(level, existingTask.IncidentLevel) {
 						tasksByTitle[task.Title] = task
 					}
 				}
 			}
 		} else {
 			tasksByTitle[task.Title] = task
+			titlesOrder = append(titlesOrder, task.Title)
 		}
 	}
 
 	// Convert map back to slice
 	filteredTasks = make([]Task, 0, len(tasksByTitle))
-	for _, task := range tasksByTitle {
-		filteredTasks = append(filteredTasks, task)
+	for _, title := range titlesOrder {
+		filteredTasks = append(filteredTasks, tasksByTitle[title])
 	}
 
 	// Sort by Priority
-	sort.Slice(filteredTasks, func(i, j int) bool {
+	sort.SliceStable(filteredTasks, func(i, j int) bool {
 		return filteredTasks[i].Priority < filteredTasks[j].Priority
 	})
 
 	return filteredTasks
 }
 
+// FilterTasksForIncidentLevel filters the tasks of an escalation level with sub-levels, like "incidente", needed
+// to raise an event from the starting incident level to the final one. It returns the tasks of the given category,
+// or "pro22", of that level with an incident level above the starting one and up to the final one.
+// An empty starting incident level stands for an event without incident level, so every task up to the final one is returned.
+func FilterTasksForIncidentLevel(tasks []Task, category, escalationLevel, startingIncidentLevel, finalIncidentLevel string) []Task {
+	var filteredTasks []Task
+	levelModel := CurrentLevelModel()
+	level := strings.ToLower(escalationLevel)
+	startIdx := levelModel.SubRank(level, strings.ToLower(startingIncidentLevel))
+	endIdx := levelModel.SubRank(level, strings.ToLower(finalIncidentLevel))
+
+	for _, task := range tasks {
+		if !strings.EqualFold(task.Category, category) && !strings.EqualFold(task.Category, "pro22") {
+			continue
+		}
+		if strings.ToLower(task.EscalationLevel) != level {
+			continue
+		}
+		if taskIdx := levelModel.SubRank(level, strings.ToLower(task.IncidentLevel)); taskIdx > startIdx && taskIdx <= endIdx {
+			filteredTasks = append(filteredTasks, task)
+		}
+	}
+
+	return filteredTasks
+}
+
 // FilterTasksForEscalation filters tasks based on category, escalation levels, and incident level conditions.
 func FilterTasksForEscalation(tasks []Task, category, startingEscalation, finalEscalation, incidentLevel string) ([]Task, error) {
 	var filteredTasks []Task
 	// The escalation levels ranked in order
-	rankedLevels := GetEscalationLevels()
+	levelModel := CurrentLevelModel()
+	rankedLevels := levelModel.Levels()
 	levelMap := make(map[string]int)
 	for i, level := range rankedLevels {
 		levelMap[level] = i
@@ -390,16 +416,18 @@ func FilterTasksForEscalation(tasks []Task, category, startingEscalation, finalE
 	if !startOk || !endOk {
 		return nil, fmt.Errorf("invalid escalation levels: %s or %s", startingEscalation, finalEscalation)
 	}
+	final := strings.ToLower(finalEscalation)
 	// New condition to handle same starting and final escalation levels
 	if startIdx == endIdx {
-		// Allow processing if both are "incidente"
-		if !(strings.EqualFold(startingEscalation, "incidente") && strings.EqualFold(finalEscalation, "incidente")) {
+		// Allow processing if both are the same level with sub-levels, like "incidente".
+		// No task is returned without the starting incident level, use FilterTasksForIncidentLevel instead
+		if !levelModel.HasSubLevels(final) {
 			return nil, fmt.Errorf("starting and final escalation levels cannot be the same")
 		}
 	}
 	// Check if incidentLevel is required and provided
-	if strings.ToLower(finalEscalation) == "incidente" && incidentLevel == "" {
-		return nil, errors.New("incident level must be provided when final escalation is 'incidente'")
+	if levelModel.HasSubLevels(final) && incidentLevel == "" {
+		return nil, fmt.Errorf("incident level must be provided when final escalation is '%s'", final)
 	}
 	// Adjust the indices for correct slicing
 	startIdx++
@@ -412,18 +440,11 @@ func FilterTasksForEscalation(tasks []Task, category, startingEscalation, finalE
 	for _, level := range escLevels {
 		escLevelSet[strings.ToLower(level)] = true
 	}
-	// Handle 'incidente' specific case if incidentLevel is provided
-	incidentLevels := map[string]int{
-		"bianca": 0,
-		"verde":  1,
-		"gialla": 2,
-		"rossa":  3,
-	}
+	// Handle levels with sub-levels, like 'incidente', if incidentLevel is provided
 	var incidentIdx int
-	var incidentOk bool
-	if strings.ToLower(finalEscalation) == "incidente" {
-		incidentIdx, incidentOk = incidentLevels[strings.ToLower(incidentLevel)]
-		if !incidentOk {
+	if levelModel.HasSubLevels(final) {
+		incidentIdx = levelModel.SubRank(final, strings.ToLower(incidentLevel))
+		if incidentIdx == 0 {
 			return nil, fmt.Errorf("invalid incident level: %s", incidentLevel)
 		}
 	}
@@ -434,9 +455,9 @@ func FilterTasksForEscalation(tasks []Task, category, startingEscalation, finalE
 		taskCategory := strings.ToLower(task.Category)
 
 		if taskCategory == strings.ToLower(category) || taskCategory == strings.ToLower("pro22") {
-			if strings.ToLower(finalEscalation) == "incidente" && taskEscLevel == "incidente" {
-				taskIncidentLevelIdx, ok := incidentLevels[taskIncidentLevel]
-				if ok && taskIncidentLevelIdx <= incidentIdx {
+			if levelModel.HasSubLevels(final) && taskEscLevel == final {
+				taskIncidentLevelIdx := levelModel.SubRank(final, taskIncidentLevel)
+				if taskIncidentLevelIdx > 0 && taskIncidentLevelIdx <= incidentIdx {
 					filteredTasks = append(filteredTasks, task)
 				}
 			} else if escLevelSet[taskEscLevel] {
@@ -456,18 +477,9 @@ func FilterTasksForEscalation(tasks []Task, category, startingEscalation, finalE
 //  3. If task exists only in update slice append it to the original, follow same rules as 1 if multiple tasks
 //     with same title exist in update slice (for escalationLevel and incidentLevel).
 func MergeTasks(original, update []Task) ([]Task, error) {
-	// Helper function to check if a Task in the update slice has only Title populated.
-	isOnlyTitleAndCategoryPopulated := func(task Task) bool {
-		return task.Priority == 0 &&
-			task.Description == "" &&
-			task.Category == "" &&
-			task.Role == "" &&
-			task.EscalationLevel == "" &&
-			task.IncidentLevel == ""
-	}
-
 	// Helper function to compare tasks and return the one with higher escalation level
-	// If both have the same escalation level "incidente", return the one with higher incident level
+	// If both have the same escalation level with sub-levels, like "incidente", return the one with higher incident level
+	levelModel := CurrentLevelModel()
 	compareTaskLevels := func(task1, task2 Task) Task {
 		// Get escalation levels for comparison
 		esc1 := strings.ToLower(task1.EscalationLevel)
@@ -475,29 +487,31 @@ func MergeTasks(original, update []Task) ([]Task, error) {
 
 		// If escalation levels are different, return the task with higher level
 		if esc1 != esc2 {
-			if escalationLevels[esc1] > escalationLevels[esc2] {
+			if levelModel.Rank(esc1) > levelModel.Rank(esc2) {
 				return task1
 			}
 			return task2
 		}
 
-		// If both have escalation level "incidente", compare incident levels
-		if esc1 == "incidente" && esc2 == "incidente" {
+		// If both have the same escalation level with sub-levels, like "incidente", compare incident levels
+		if levelModel.HasSubLevels(esc1) {
 			inc1 := strings.ToLower(task1.IncidentLevel)
 			inc2 := strings.ToLower(task2.IncidentLevel)
 
-			if incidentLevels[inc1] > incidentLevels[inc2] {
+			if levelModel.SubRank(esc1, inc1) > levelModel.SubRank(esc1, inc2) {
 				return task1
 			}
 			return task2
 		}
 
-		// If escalation levels are the same but not "incidente", return either one (task1 in this case)
+		// If escalation levels are the same without sub-levels, return either one (task1 in this case)
 		return task1
 	}
 
-	// Rule 1: Process original slice to keep only tasks with highest escalation/incident level for each title
+	// Rule 1: Process original slice to keep only tasks with highest escalation/incident level for each title.
+	// Titles order keeps the first seen order, so the merge result is always returned in the same order
 	originalByTitle := make(map[string]Task)
+	var originalTitles []string
 	for _, task := range original {
 		title := task.Title
 		if existingTask, exists := originalByTitle[title]; exists {
@@ -505,62 +519,64 @@ func MergeTasks(original, update []Task) ([]Task, error) {
 			originalByTitle[title] = compareTaskLevels(existingTask, task)
 		} else {
 			originalByTitle[title] = task
+			originalTitles = append(originalTitles, title)
 		}
 	}
 
 	// Rule 3: Process update slice to keep only tasks with highest escalation/incident level for each title
 	updateByTitle := make(map[string]Task)
+	var updateTitles []string
 	for _, task := range update {
+		title := task.Title
+		existingTask, exists := updateByTitle[title]
+		if !exists {
+			updateTitles = append(updateTitles, title)
+		}
+
 		// Skip tasks that only have title populated (used for deletion)
-		if !isOnlyTitleAndCategoryPopulated(task) {
-			title := task.Title
-			if existingTask, exists := updateByTitle[title]; exists {
-				// Compare and keep the task with higher level
-				updateByTitle[title] = compareTaskLevels(existingTask, task)
-			} else {
-				updateByTitle[title] = task
-			}
+		if exists && !IsRemovalTask(task) {
+			// Compare and keep the task with higher level
+			updateByTitle[title] = compareTaskLevels(existingTask, task)
 		} else {
 			// For deletion tasks, always keep them
-			updateByTitle[task.Title] = task
+			updateByTitle[title] = task
 		}
 	}
 
-	// Convert the filtered original tasks back to a slice
-	var filteredOriginal []Task
-	for _, task := range originalByTitle {
-		filteredOriginal = append(filteredOriginal, task)
-	}
-
 	// Rule 2: Merge update tasks into original
-	// Create a map for O(1) lookups of filtered original tasks
-	originalTaskMap := make(map[string]int)
-	for i, task := range filteredOriginal {
-		originalTaskMap[task.Title] = i
+	var merged []Task
+	for _, title := range originalTitles {
+		updatedTask, exists := updateByTitle[title]
+		switch {
+		case !exists:
+			merged = append(merged, originalByTitle[title])
+		case IsRemovalTask(updatedTask):
+			// If only Title is populated in the updated Task, delete the task from original
+		default:
+			// If other fields are populated, update the task in the original slice
+			merged = append(merged, updatedTask)
+		}
 	}
 
-	// Process the filtered update tasks
-	for _, updatedTask := range updateByTitle {
-		key := updatedTask.Title
-		if idx, exists := originalTaskMap[key]; exists {
-			// If the task exists in the original slice
-			if isOnlyTitleAndCategoryPopulated(updatedTask) {
-				// If only Title and Category are populated in the updated Task, delete the task from original
-				filteredOriginal = append(filteredOriginal[:idx], filteredOriginal[idx+1:]...)
-				delete(originalTaskMap, key) // Also remove it from the map
-			} else {
-				// If other fields are populated, update the task in the original slice
-				filteredOriginal[idx] = updatedTask
-			}
-		} else {
-			// If the task does not exist in the original slice, append it to original
-			if !isOnlyTitleAndCategoryPopulated(updatedTask) {
-				filteredOriginal = append(filteredOriginal, updatedTask)
-			}
+	// If the task does not exist in the original slice, append it to original
+	for _, title := range updateTitles {
+		updatedTask := updateByTitle[title]
+		if _, exists := originalByTitle[title]; !exists && !IsRemovalTask(updatedTask) {
+			merged = append(merged, updatedTask)
 		}
 	}
 
-	return filteredOriginal, nil
+	return merged, nil
+}
+
+// IsRemovalTask reports whether a task from a local task file only has the title populated,
+// meaning the main task with the same title must be removed.
+// Category and role are not considered, they come from the sheet name and the header row, not from the task row.
+func IsRemovalTask(task Task) bool {
+	return task.Priority == 0 &&
+		task.Description == "" &&
+		task.EscalationLevel == "" &&
+		task.IncidentLevel == ""
 }
 
 // MergeTasksFixCategory merges two slices of Tasks by updating or removing existing tasks and adding new ones based on their Title and Category.
@@ -159,6 +159,23 @@ func TestMergeTasks(t *testing.T) {
 				{ID: 4, Title: "title2", Category: "category4", Priority: 4, Description: "Desc4", Role: "Role4", EscalationLevel: "incidente", IncidentLevel: "gialla"},
 			},
 		},
+		// Deletion scenario with a title only row parsed from a local task file, category and role come from the sheet
+		{
+			name: "Delete Task with Title Only Row from Local File",
+			original: []Task{
+				{ID: 1, Title: "title1", Category: "category1", Priority: 1, Description: "Desc1", Role: "Role1", EscalationLevel: "allarme", IncidentLevel: ""},
+				{ID: 2, Title: "title2", Category: "category1", Priority: 2, Description: "Desc2", Role: "Role1", EscalationLevel: "allarme", IncidentLevel: ""},
+				{ID: 3, Title: "title3", Category: "category1", Priority: 3, Description: "Desc3", Role: "Role1", EscalationLevel: "allarme", IncidentLevel: ""},
+			},
+			update: []Task{
+				{Title: "title1", Category: "category1", Role: "Role1"},
+				{Title: "title3", Category: "category1", Priority: 4, Description: "Local3", Role: "Role1", EscalationLevel: "allarme"},
+			},
+			want: []Task{
+				{ID: 2, Title: "title2", Category: "category1", Priority: 2, Description: "Desc2", Role: "Role1", EscalationLevel: "allarme", IncidentLevel: ""},
+				{Title: "title3", Category: "category1", Priority: 4, Description: "Local3", Role: "Role1", EscalationLevel: "allarme"},
+			},
+		},
 		// Deletion scenario with multiple tasks with the same title in the original slice
 		{
 			name: "Delete Task with Multiple Instances in Original",
@@ -439,6 +456,66 @@ func TestFilterTasksForEscalation(t *testing.T) {
 	}
 }
 
+func TestFilterTasksForIncidentLevel(t *testing.T) {
+	tasks := []Task{
+		{Category: "cat1", EscalationLevel: "emergenza", IncidentLevel: ""},
+		{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "bianca"},
+		{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "verde"},
+		{Category: "pro22", EscalationLevel: "incidente", IncidentLevel: "gialla"},
+		{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "rossa"},
+		{Category: "cat2", EscalationLevel: "incidente", IncidentLevel: "rossa"},
+	}
+
+	tests := []struct {
+		name             string
+		startingIncident string
+		finalIncident    string
+		want             []Task
+	}{
+		{
+			name:             "GiallaToRossa",
+			startingIncident: "gialla",
+			finalIncident:    "rossa",
+			want: []Task{
+				{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "rossa"},
+			},
+		},
+		{
+			name:             "BiancaToGialla",
+			startingIncident: "bianca",
+			finalIncident:    "gialla",
+			want: []Task{
+				{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "verde"},
+				{Category: "pro22", EscalationLevel: "incidente", IncidentLevel: "gialla"},
+			},
+		},
+		{
+			name:             "NoStartingIncidentLevel",
+			startingIncident: "",
+			finalIncident:    "verde",
+			want: []Task{
+				{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "bianca"},
+				{Category: "cat1", EscalationLevel: "incidente", IncidentLevel: "verde"},
+			},
+		},
+		{
+			name:             "Lower",
+			startingIncident: "rossa",
+			finalIncident:    "verde",
+			want:             nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterTasksForIncidentLevel(tasks, "cat1", "incidente", tt.startingIncident, tt.finalIncident)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterTasksForIncidentLevel() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFilterTasks(t *testing.T) {
 	tests := []struct {
 		name            string
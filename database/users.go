@@ -0,0 +1,391 @@
+// Package database provides functionality for interacting with the SQLite database.
+// It defines repositories for managing different types of data (tasks, active events, etc.),
+// includes functions for connecting to the database, creating tables, and performing CRUD operations,
+// and provides utilities for data aggregation, filtering, and merging.
+package database
+
+import (
+	"database/sql"
+	"dogeplus-backend/errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Constants representing the roles a user can have.
+// Operators work on event tasks, supervisors additionally manage escalations and assignments,
+// procedure-admins maintain task files, centrals and users.
+const (
+	RoleOperator       = "operator"
+	RoleSupervisor     = "supervisor"
+	RoleProcedureAdmin = "procedure-admin"
+)
+
+// roles is the set of valid user roles
+var roles = map[string]bool{
+	RoleOperator:       true,
+	RoleSupervisor:     true,
+	RoleProcedureAdmin: true,
+}
+
+// IsValidRole reports whether role is one of the known user roles.
+func IsValidRole(role string) bool {
+	return roles[role]
+}
+
+type UserNotFoundError struct {
+	Detail string
+}
+
+func (e UserNotFoundError) Error() string {
+	return fmt.Sprintf("user not found: %s", e.Detail)
+}
+
+type UserExistsError struct {
+	Detail string
+}
+
+func (e UserExistsError) Error() string {
+	return fmt.Sprintf("user already exists: %s", e.Detail)
+}
+
+type InvalidRoleError struct {
+	Detail string
+}
+
+func (e InvalidRoleError) Error() string {
+	return fmt.Sprintf("invalid role: %s", e.Detail)
+}
+
+type InvalidCredentialsError struct {
+	Detail string
+}
+
+func (e InvalidCredentialsError) Error() string {
+	return fmt.Sprintf("invalid credentials: %s", e.Detail)
+}
+
+// User represents a local user allowed to access the API.
+// Centrals lists the operations centrals the user belongs to. The password hash never leaves the repository.
+type User struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Centrals  []string  `json:"centrals"`
+}
+
+type UsersRepository struct {
+	db *sql.DB
+}
+
+func NewUsersRepository(db *sql.DB) *UsersRepository {
+	return &UsersRepository{db: db}
+}
+
+// hashPassword returns the bcrypt hash of a password, empty passwords are rejected
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", fmt.Errorf("password should not be empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash password")
+	}
+
+	return string(hash), nil
+}
+
+// GetAll retrieves all the users, active and inactive, ordered by username.
+func (u *UsersRepository) GetAll() ([]User, error) {
+	rows, err := u.db.Query(`SELECT username, role, active, created_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query users")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetAll users")
+	}()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.Username, &user.Role, &user.Active, &user.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan user row")
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	for i := range users {
+		if users[i].Centrals, err = u.GetCentrals(users[i].Username); err != nil {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
+
+// GetByUsername retrieves a user by its username.
+// It returns a UserNotFoundError if no user matches.
+func (u *UsersRepository) GetByUsername(username string) (User, error) {
+	var user User
+	err := u.db.QueryRow(`SELECT username, role, active, created_at FROM users WHERE username = ?`, username).
+		Scan(&user.Username, &user.Role, &user.Active, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return User{}, &UserNotFoundError{Detail: username}
+	}
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to get user")
+	}
+
+	if user.Centrals, err = u.GetCentrals(username); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// Count returns the number of users, active and inactive.
+func (u *UsersRepository) Count() (int, error) {
+	var count int
+	if err := u.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "failed to count users")
+	}
+
+	return count, nil
+}
+
+// Add creates a new active user with the given password and role, belonging to the given centrals.
+// The user and its centrals are added in a single transaction.
+// It returns an InvalidRoleError for unknown roles, a UserExistsError if the username is taken
+// and a CentralNotFoundError for unknown centrals.
+func (u *UsersRepository) Add(username, password, role string, centrals ...string) (user User, err error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return User{}, fmt.Errorf("username should not be empty")
+	}
+	if !IsValidRole(role) {
+		return User{}, &InvalidRoleError{Detail: role}
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = errors.Wrap(tx.Commit(), "failed to commit transaction")
+	}()
+
+	var exists int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&exists); err != nil {
+		return User{}, errors.Wrap(err, "failed to check user")
+	}
+	if exists > 0 {
+		return User{}, &UserExistsError{Detail: username}
+	}
+
+	user = User{Username: username, Role: role, Active: true, CreatedAt: time.Now()}
+	_, err = tx.Exec(`INSERT INTO users (username, password_hash, role, active, created_at) VALUES (?, ?, ?, ?, ?)`,
+		user.Username, hash, user.Role, user.Active, user.CreatedAt)
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to add user")
+	}
+
+	if user.Centrals, err = setUserCentrals(tx, username, centrals); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// Update modifies role and active flag of an existing user.
+// It returns an InvalidRoleError for unknown roles and a UserNotFoundError if no user matches.
+func (u *UsersRepository) Update(username, role string, active bool) (User, error) {
+	if !IsValidRole(role) {
+		return User{}, &InvalidRoleError{Detail: role}
+	}
+
+	result, err := u.db.Exec(`UPDATE users SET role = ?, active = ? WHERE username = ?`, role, active, username)
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to update user")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to get affected rows")
+	}
+	if affected == 0 {
+		return User{}, &UserNotFoundError{Detail: username}
+	}
+
+	return u.GetByUsername(username)
+}
+
+// SetPassword replaces the password of an existing user.
+// It returns a UserNotFoundError if no user matches.
+func (u *UsersRepository) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	result, err := u.db.Exec(`UPDATE users SET password_hash = ? WHERE username = ?`, hash, username)
+	if err != nil {
+		return errors.Wrap(err, "failed to update password")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+	if affected == 0 {
+		return &UserNotFoundError{Detail: username}
+	}
+
+	return nil
+}
+
+// Authenticate checks the password of a user and returns the user on success.
+// Unknown users, wrong passwords and inactive users all return an InvalidCredentialsError,
+// so callers can't tell which usernames exist.
+func (u *UsersRepository) Authenticate(username, password string) (User, error) {
+	var user User
+	var hash string
+	err := u.db.QueryRow(`SELECT username, password_hash, role, active, created_at FROM users WHERE username = ?`, username).
+		Scan(&user.Username, &hash, &user.Role, &user.Active, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Compare anyway so unknown usernames take as long as wrong passwords
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return User{}, &InvalidCredentialsError{Detail: username}
+	}
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to get user")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !user.Active {
+		return User{}, &InvalidCredentialsError{Detail: username}
+	}
+
+	if user.Centrals, err = u.GetCentrals(username); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// GetCentrals retrieves the ids of the centrals a user belongs to, ordered by id.
+func (u *UsersRepository) GetCentrals(username string) ([]string, error) {
+	rows, err := u.db.Query(`SELECT central_id FROM user_centrals WHERE username = ? ORDER BY central_id`, username)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query user centrals")
+	}
+	defer func() {
+		errors.HandleCloser(rows.Close(), "error closing rows in GetCentrals")
+	}()
+
+	centrals := []string{}
+	for rows.Next() {
+		var centralId string
+		if err := rows.Scan(&centralId); err != nil {
+			return nil, errors.Wrap(err, "failed to scan user central row")
+		}
+		centrals = append(centrals, centralId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error during row iteration")
+	}
+
+	return centrals, nil
+}
+
+// SetCentrals replaces the centrals a user belongs to.
+// It returns a UserNotFoundError if no user matches and a CentralNotFoundError for unknown centrals.
+func (u *UsersRepository) SetCentrals(username string, centrals []string) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = errors.Wrap(tx.Commit(), "failed to commit transaction")
+	}()
+
+	var exists int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&exists); err != nil {
+		return errors.Wrap(err, "failed to check user")
+	}
+	if exists == 0 {
+		return &UserNotFoundError{Detail: username}
+	}
+
+	if _, err = tx.Exec(`DELETE FROM user_centrals WHERE username = ?`, username); err != nil {
+		return errors.Wrap(err, "failed to clear user centrals")
+	}
+
+	_, err = setUserCentrals(tx, username, centrals)
+	return err
+}
+
+// setUserCentrals adds a user to the given centrals within tx, and returns its sorted centrals without duplicates.
+// It returns a CentralNotFoundError for unknown centrals.
+func setUserCentrals(tx *sql.Tx, username string, centrals []string) ([]string, error) {
+	added := []string{}
+	for _, centralId := range centrals {
+		var known int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM centrals WHERE id = ?`, centralId).Scan(&known); err != nil {
+			return nil, errors.Wrap(err, "failed to check central")
+		}
+		if known == 0 {
+			return nil, &CentralNotFoundError{Detail: centralId}
+		}
+
+		result, err := tx.Exec(`INSERT OR IGNORE INTO user_centrals (username, central_id) VALUES (?, ?)`, username, centralId)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to add user central")
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get affected rows")
+		}
+		if affected > 0 {
+			added = append(added, centralId)
+		}
+	}
+
+	sort.Strings(added)
+	return added, nil
+}
+
+// BelongsToCentral reports whether a user is a member of a central.
+func (u *UsersRepository) BelongsToCentral(username, centralId string) (bool, error) {
+	var count int
+	err := u.db.QueryRow(`SELECT COUNT(*) FROM user_centrals WHERE username = ? AND central_id = ?`, username, centralId).
+		Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check user central")
+	}
+
+	return count > 0, nil
+}
+
+// dummyPasswordHash is a valid bcrypt hash compared against when the user doesn't exist
+const dummyPasswordHash = "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z3TL5hVPyJHQd3vF5y5lZJ4u"
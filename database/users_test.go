@@ -0,0 +1,137 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestUsersRepository_Add tests user creation, duplicated usernames and invalid roles
+func TestUsersRepository_Add(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	user, err := repo.Add("mario", "secret", RoleSupervisor)
+	require.NoError(t, err)
+	assert.Equal(t, "mario", user.Username)
+	assert.Equal(t, RoleSupervisor, user.Role)
+	assert.True(t, user.Active)
+
+	_, err = repo.Add("mario", "other", RoleOperator)
+	assert.IsType(t, &UserExistsError{}, err)
+
+	_, err = repo.Add("luigi", "secret", "admin")
+	assert.IsType(t, &InvalidRoleError{}, err)
+
+	_, err = repo.Add("luigi", "", RoleOperator)
+	assert.Error(t, err)
+
+	count, err := repo.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// The password is stored hashed
+	var hash string
+	require.NoError(t, db.QueryRow(`SELECT password_hash FROM users WHERE username = 'mario'`).Scan(&hash))
+	assert.NotEqual(t, "secret", hash)
+}
+
+// TestUsersRepository_Authenticate tests that only active users with the right password are authenticated
+func TestUsersRepository_Authenticate(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	_, err := repo.Add("mario", "secret", RoleOperator)
+	require.NoError(t, err)
+
+	user, err := repo.Authenticate("mario", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, RoleOperator, user.Role)
+
+	_, err = repo.Authenticate("mario", "wrong")
+	assert.IsType(t, &InvalidCredentialsError{}, err)
+
+	_, err = repo.Authenticate("luigi", "secret")
+	assert.IsType(t, &InvalidCredentialsError{}, err)
+
+	// Password change
+	require.NoError(t, repo.SetPassword("mario", "changed"))
+	_, err = repo.Authenticate("mario", "secret")
+	assert.IsType(t, &InvalidCredentialsError{}, err)
+	_, err = repo.Authenticate("mario", "changed")
+	require.NoError(t, err)
+
+	// Inactive users can't log in
+	updated, err := repo.Update("mario", RoleSupervisor, false)
+	require.NoError(t, err)
+	assert.Equal(t, RoleSupervisor, updated.Role)
+	assert.False(t, updated.Active)
+	_, err = repo.Authenticate("mario", "changed")
+	assert.IsType(t, &InvalidCredentialsError{}, err)
+
+	_, err = repo.Update("luigi", RoleOperator, true)
+	assert.IsType(t, &UserNotFoundError{}, err)
+	assert.IsType(t, &UserNotFoundError{}, repo.SetPassword("luigi", "secret"))
+}
+
+// TestUsersRepository_Centrals tests the management of the centrals a user belongs to
+func TestUsersRepository_Centrals(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	// SRA and SRL are seeded by the centrals migration
+	_, err := repo.Add("mario", "secret", RoleOperator)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SetCentrals("mario", []string{"SRL", "SRA", "SRA"}))
+	user, err := repo.GetByUsername("mario")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SRA", "SRL"}, user.Centrals)
+
+	belongs, err := repo.BelongsToCentral("mario", "SRA")
+	require.NoError(t, err)
+	assert.True(t, belongs)
+
+	// Replacing the centrals drops the old memberships
+	require.NoError(t, repo.SetCentrals("mario", []string{"SRL"}))
+	belongs, err = repo.BelongsToCentral("mario", "SRA")
+	require.NoError(t, err)
+	assert.False(t, belongs)
+
+	// Unknown centrals leave the memberships untouched
+	assert.IsType(t, &CentralNotFoundError{}, repo.SetCentrals("mario", []string{"SRA", "XXX"}))
+	centrals, err := repo.GetCentrals("mario")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SRL"}, centrals)
+
+	assert.IsType(t, &UserNotFoundError{}, repo.SetCentrals("luigi", []string{"SRA"}))
+}
+
+// TestUsersRepository_AddWithCentrals tests that a user is added with its centrals, or not at all
+func TestUsersRepository_AddWithCentrals(t *testing.T) {
+	db := setupSchemaTestDB(t)
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	user, err := repo.Add("mario", "secret", RoleOperator, "SRL", "SRA", "SRA")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SRA", "SRL"}, user.Centrals)
+	stored, err := repo.GetByUsername("mario")
+	require.NoError(t, err)
+	assert.Equal(t, user.Centrals, stored.Centrals)
+
+	// An unknown central rolls the user back
+	_, err = repo.Add("luigi", "secret", RoleOperator, "SRA", "XXX")
+	assert.IsType(t, &CentralNotFoundError{}, err)
+	_, err = repo.GetByUsername("luigi")
+	assert.IsType(t, &UserNotFoundError{}, err)
+	belongs, err := repo.BelongsToCentral("luigi", "SRA")
+	require.NoError(t, err)
+	assert.False(t, belongs)
+}
@@ -25,15 +25,15 @@ type eventRequest struct {
 }
 
 type updateEventRequest struct {
-	UUID       uuid.UUID `json:"uuid"`
-	Status     string    `json:"status"`
-	ModifiedBy string    `json:"modified_by"`
-	IpAddress  string    `json:"ip_address"`
+	UUID      uuid.UUID `json:"uuid"`
+	Status    string    `json:"status"`
+	IpAddress string    `json:"ip_address"`
 }
 
 // CreateNewEvent is a handler function that creates a new event based on the provided categories, event number, and central ID.
 // It expects a JSON request body containing the categories, event number, and central ID.
 // If the body parsing fails, it returns a "400 Bad Request" error.
+// If the central is unknown or not active, it returns a "400 Bad Request" error.
 // If the categories field is empty, it returns a "400 Bad Request" error.
 // It retrieves tasks from the repository based on the provided categories.
 // If retrieving tasks fails, it returns a "500 Internal Server Error" error.
@@ -56,59 +56,23 @@ func CreateNewEvent(repos *database.Repositories, confg config.Config) func(ctx
 		//	return fiber.NewError(fiber.StatusBadRequest, "Invalid request body: Categories field should not be empty")
 		//}
 
-		// Get tasks from body list
-		taskList, err := repos.Tasks.GetByCategories(body.Categories)
+		// Only configured and active centrals can open events
+		central, err := repos.Centrals.GetActive(body.CentralId)
 		if err != nil {
-			// Error while retrieving tasks
-			log.Errorf("Error retrieving tasks: %s\n", err)
-			return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tasks")
+			return centralErrorResponse(ctx, err)
 		}
 
-		// Filter tasks based on selection
-		filteredTasks := database.FilterTasks(taskList, body.Categories, body.EscalationLevel, body.IncidentLevel)
+		// Build the task list, dropping the main tasks removed by the local task file
+		previews, err := eventTaskPreviews(repos, confg, central, body)
+		if err != nil {
+			return err
+		}
 
-		// Get local tasks based on CentralId from body
 		var tasksToUse []database.Task
-		isMergedTasks := false
-		// Load the correct local task file
-		f, err := config.LoadExcelFile(confg, body.CentralId)
-		if err == nil {
-
-			// Parse the file
-			localTasks, err := database.ParseXLSXToTasks(f)
-			if err != nil {
-				log.Errorf("Error parsing local task file: %s\n", err)
-				return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse local task file")
-			}
-
-			// Filter the local file based on request body parameters
-			filteredLocalTasks := database.FilterTasks(localTasks, body.Categories, body.EscalationLevel, body.IncidentLevel)
-
-			// Merge task lists
-			tasksToUse, err = database.MergeTasks(filteredTasks, filteredLocalTasks)
-			if err != nil {
-				// Error while merging tasks
-				log.Errorf("Error merging tasks: %s\n", err)
-				return fiber.NewError(fiber.StatusInternalServerError, "Failed to merge tasks")
+		for _, preview := range previews {
+			if preview.Origin != database.TaskOriginLocalRemoval {
+				tasksToUse = append(tasksToUse, preview.Task)
 			}
-
-			// Set isMergedTasks to true to signal that the data need to be sorted
-			isMergedTasks = true
-		} else {
-			tasksToUse = filteredTasks
-		}
-
-		// Sort merged tasks by priority if merging occurred
-		if isMergedTasks {
-			slices.SortStableFunc(tasksToUse, func(a, b database.Task) int {
-				if a.Priority < b.Priority {
-					return -1
-				}
-				if a.Priority > b.Priority {
-					return 1
-				}
-				return 0
-			})
 		}
 
 		// Create new event from taskList
@@ -119,8 +83,105 @@ func CreateNewEvent(repos *database.Repositories, confg config.Config) func(ctx
 			return fiber.NewError(fiber.StatusInternalServerError, "Failed to create event")
 		}
 
-		return ctx.JSON(fiber.Map{"Result": "Events Created"})
+		revision := bumpEventRevision(repos, body.CentralId, body.EventNumber)
+
+		return ctx.JSON(fiber.Map{"Result": "Events Created", "revision": revision})
+	}
+}
+
+// PreviewNewEvent is a handler function that returns the tasks CreateNewEvent would generate, without creating the event.
+// It expects the same JSON request body as CreateNewEvent.
+// Every task is annotated with its origin: main task, local override, local addition or local removal.
+// Removed tasks are listed too, the "tasks" field counts only the tasks that would be created.
+// If the central is unknown or not active, it returns a "400 Bad Request" error.
+func PreviewNewEvent(repos *database.Repositories, confg config.Config) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body eventRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		central, err := repos.Centrals.GetActive(body.CentralId)
+		if err != nil {
+			return centralErrorResponse(ctx, err)
+		}
+
+		previews, err := eventTaskPreviews(repos, confg, central, body)
+		if err != nil {
+			return err
+		}
+
+		tasks := 0
+		for _, preview := range previews {
+			if preview.Origin != database.TaskOriginLocalRemoval {
+				tasks++
+			}
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Event preview",
+			"length": len(previews),
+			"tasks":  tasks,
+			"data":   previews,
+		})
+	}
+}
+
+// eventTaskPreviews builds the tasks of a new event, annotated with their origin and sorted by priority.
+// Main PRO22 and category tasks are filtered for the requested levels, then merged with the filtered tasks
+// of the central local task file, if any.
+// Errors are returned as fiber errors ready to be returned by the handler.
+func eventTaskPreviews(repos *database.Repositories, confg config.Config, central database.Central, body eventRequest) ([]database.TaskPreview, error) {
+	// Get tasks from body list
+	taskList, err := repos.Tasks.GetByCategories(body.Categories)
+	if err != nil {
+		// Error while retrieving tasks
+		log.Errorf("Error retrieving tasks: %s\n", err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tasks")
+	}
+
+	// Filter tasks based on selection
+	filteredTasks := database.FilterTasks(taskList, body.Categories, body.EscalationLevel, body.IncidentLevel)
+
+	// Load the correct local task file, without one only the main tasks are used
+	var filteredLocalTasks []database.Task
+	mergedTasks := filteredTasks
+	f, err := loadCentralTaskFile(confg, central)
+	if err == nil {
+		// Parse the file
+		localTasks, err := database.ParseXLSXToTasks(f)
+		if err != nil {
+			log.Errorf("Error parsing local task file: %s\n", err)
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to parse local task file")
+		}
+
+		// Filter the local file based on request body parameters
+		filteredLocalTasks = database.FilterTasks(localTasks, body.Categories, body.EscalationLevel, body.IncidentLevel)
+
+		// Merge task lists
+		mergedTasks, err = database.MergeTasks(filteredTasks, filteredLocalTasks)
+		if err != nil {
+			// Error while merging tasks
+			log.Errorf("Error merging tasks: %s\n", err)
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to merge tasks")
+		}
 	}
+
+	previews := database.AnnotateTaskOrigins(filteredTasks, filteredLocalTasks, mergedTasks)
+
+	// Sort merged tasks by priority
+	slices.SortStableFunc(previews, func(a, b database.TaskPreview) int {
+		if a.Priority < b.Priority {
+			return -1
+		}
+		if a.Priority > b.Priority {
+			return 1
+		}
+		return 0
+	})
+
+	return previews, nil
 }
 
 // GetSingleEvent is a handler function that retrieves a single event from the database based on the provided central ID.
@@ -276,16 +337,11 @@ func UpdateEventTask(repos *database.Repositories, cm *broadcast.ConnectionManag
 			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Status field should not be empty")
 		}
 
-		//check in Modified by is nil
-		if body.ModifiedBy == "" {
-			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: ModifiedBy field should not be empty")
-		}
-
 		// Retrieve client's ip, overwrite eventual passed in value
 		body.IpAddress = ctx.IP()
 
 		// Actually update the event in db
-		updatedTask, err := repos.ActiveEvents.UpdateStatus(body.UUID, body.Status, body.ModifiedBy, body.IpAddress)
+		updatedTask, err := repos.ActiveEvents.UpdateStatus(body.UUID, body.Status, currentUser(ctx).Username, body.IpAddress)
 		if err != nil {
 			log.Errorf("Error updating event task: %s\n", err)
 			return fiber.NewError(fiber.StatusInternalServerError, "Failed to update event task")
@@ -293,8 +349,9 @@ func UpdateEventTask(repos *database.Repositories, cm *broadcast.ConnectionManag
 
 		// Build map for both response and broadcast
 		updatedTaskMap := fiber.Map{
-			"Result": "Event Task Updated",
-			"Events": updatedTask,
+			"Result":   "Event Task Updated",
+			"Events":   updatedTask,
+			"revision": bumpEventRevision(repos, updatedTask.CentralID, updatedTask.EventNumber),
 		}
 
 		// Send broadcast response via connection manager in JSON format
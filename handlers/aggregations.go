@@ -5,13 +5,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"dogeplus-backend/broadcast"
 	"dogeplus-backend/config"
 	"dogeplus-backend/database"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
-	"slices"
+	"strings"
 	"time"
 )
 
@@ -73,6 +76,31 @@ func GetTaskCompletionInfoForKey(cm *broadcast.ConnectionManager) func(c *fiber.
 	}
 }
 
+// PostReconcileAggregations forces a rebuild of the in memory task completion and escalation level aggregations from the db.
+// It returns the drifts that were corrected.
+func PostReconcileAggregations(reconciler *database.Reconciler) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		report, err := reconciler.Reconcile()
+		if err != nil {
+			log.Errorf("Error reconciling aggregations: %s\n", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to reconcile aggregations",
+				"detail": err.Error(),
+			})
+		}
+
+		result := "Aggregations in sync"
+		if report.Drifted() {
+			result = "Aggregations reconciled"
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": result,
+			"data":   report,
+		})
+	}
+}
+
 //endregion
 
 // -------------------------
@@ -81,12 +109,13 @@ func GetTaskCompletionInfoForKey(cm *broadcast.ConnectionManager) func(c *fiber.
 
 //region EscalationLevels
 
-// EscalateRequest Request payload structure
+// EscalateRequest Request payload structure, the reason is recorded in the escalation history
 type EscalateRequest struct {
 	EventNumber   int            `json:"eventNumber"`
 	NewLevel      database.Level `json:"newLevel"`
 	Direction     string         `json:"direction"`
 	IncidentLevel string         `json:"incidentLevel"`
+	Reason        string         `json:"reason"`
 }
 
 // PostNewOverview handles the posting of new overview records to the database.
@@ -102,6 +131,11 @@ func PostNewOverview(repos *database.Repositories, cm *broadcast.ConnectionManag
 			})
 		}
 
+		// Only configured and active centrals can open events
+		if _, err := repos.Centrals.GetActive(request.CentralId); err != nil {
+			return centralErrorResponse(c, err)
+		}
+
 		// Add the overview
 		err := repos.Overview.Add(&request)
 		if err != nil {
@@ -115,8 +149,9 @@ func PostNewOverview(repos *database.Repositories, cm *broadcast.ConnectionManag
 
 		// Build response map for both HTTP response and broadcast
 		responseMap := fiber.Map{
-			"message": "Overview added successfully",
-			"data":    request,
+			"message":  "Overview added successfully",
+			"data":     request,
+			"revision": bumpEventRevision(repos, request.CentralId, request.EventNumber),
 		}
 
 		// Send broadcast response via connection manager in JSON format
@@ -148,8 +183,9 @@ func GetAllEscalationLevels(c *fiber.Ctx) error {
 }
 
 // PostEscalate handles HTTP POST requests to escalate an event's level.
-// It reads the request body to get the eventNumber and newLevel, escalates the event level,
-// and updates the overview. It returns a JSON response indicating success or any error.
+// It reads the request body to get the eventNumber and newLevel and escalates the event through the escalation service,
+// which updates the overview and adds the new level tasks in a single transaction.
+// It returns a JSON response indicating success or any error.
 func PostEscalate(repos *database.Repositories, confg config.Config, cm *broadcast.ConnectionManager) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		// Parse request body
@@ -160,351 +196,243 @@ func PostEscalate(repos *database.Repositories, confg config.Config, cm *broadca
 			})
 		}
 
-		// Get escalation map instance
-		escalationLevels := database.GetEscalationLevelsInstance(nil)
-
-		// Get actual escalation levels
-		actualLevels := escalationLevels.GetLevels()
-		oldLevel := actualLevels[request.EventNumber]
-
-		// Call the Escalate method
-		err := escalationLevels.Escalate(request.EventNumber, request.NewLevel)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-
-		// Sync overview with new escalation level
-		err = repos.Overview.UpdateLevelByEventNumber(request.EventNumber, request.NewLevel, request.IncidentLevel)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-
-		// Get actual event overview snapshot
-		actualOverview, err := repos.Overview.GetOverviewById(request.EventNumber)
+		// Only configured and active centrals can escalate events
+		escalation, err := escalationRequest(c, repos, confg, request, true)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return escalationErrorResponse(c, err)
 		}
 
-		// Get new level tasks
-		newTasks, err := repos.Tasks.GetByCategories(actualOverview.Type)
+		result, err := repos.Escalation.Escalate(escalation)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return escalationErrorResponse(c, err)
 		}
-		newFilteredTasks, err := database.FilterTasksForEscalation(newTasks, actualOverview.Type, string(oldLevel), string(request.NewLevel), request.IncidentLevel)
-
-		// Get local Tasks based on selection
-		var tasksToUse []database.Task
-		isMergedTasks := false
-		// Load the correct local task file
-		f, err := config.LoadExcelFile(confg, actualOverview.CentralId)
-		if err == nil {
-
-			// Parse the file
-			localTasks, err := database.ParseXLSXToTasks(f)
-			if err != nil {
-				log.Errorf("Error parsing local task file: %s\n", err)
-				return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse local task file")
-			}
 
-			// Filter the local file based on request body parameters
-			filteredLocalTasks, err := database.FilterTasksForEscalation(localTasks, actualOverview.Type, string(oldLevel), string(request.NewLevel), request.IncidentLevel)
-			if err != nil {
-				log.Errorf("Error filtering local tasks for escalation: %s\n", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to filter local tasks for escalation",
-				})
-			}
+		broadcastEscalation(repos, cm, result)
 
-			// Merge task lists
-			tasksToUse, err = database.MergeTasks(filteredLocalTasks, newFilteredTasks)
-			if err != nil {
-				// Error while merging tasks
-				log.Errorf("Error merging tasks: %s\n", err)
-				return fiber.NewError(fiber.StatusInternalServerError, "Failed to merge tasks")
-			}
-			// Set isMergedTasks to true to signal that the data need to be sorted
-			isMergedTasks = true
-		} else {
-			tasksToUse = newFilteredTasks
-		}
+		// Return success response
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Event level escalated successfully",
+			"data":    result,
+		})
+	}
+}
 
-		// Sort merged tasks by priority if merging occurred
-		if isMergedTasks {
-			slices.SortStableFunc(tasksToUse, func(a, b database.Task) int {
-				if a.Priority < b.Priority {
-					return -1
-				}
-				if a.Priority > b.Priority {
-					return 1
-				}
-				return 0
+// PostDeEscalate handles de-escalation of an event level based on the provided request data.
+// It is the reverse operation of PostEscalate, the "notdone" tasks of the levels left are removed
+// and every other task is kept as is.
+func PostDeEscalate(repos *database.Repositories, confg config.Config, cm *broadcast.ConnectionManager) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		// Parse request body
+		var request EscalateRequest
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to parse request",
 			})
 		}
 
-		// Filter out tasks that already exist in the active_events table
-		// 1. If a task exists with status != "notdone", it is removed from the list
-		// 2. If a task exists with status = "notdone", the database record is updated and the task is removed from the list
-		filteredTasks, err := repos.ActiveEvents.FilterAndUpdateExistingTasks(tasksToUse, actualOverview.EventNumber, actualOverview.CentralId)
+		// De-escalation only needs the central to exist, an inactive central can still wind down its events
+		escalation, err := escalationRequest(c, repos, confg, request, false)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-
-		// Add new tasks to active events (only those that don't already exist)
-		if len(filteredTasks) > 0 {
-			err = repos.ActiveEvents.CreateFromTaskList(filteredTasks, actualOverview.EventNumber, actualOverview.CentralId)
-			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": err.Error(),
-				})
-			}
-
-			// Keep in memory completion metrics cache in sync (only for newly added tasks)
-			taskCompletionInstance := database.GetTaskCompletionMapInstance(nil, cm)
-			taskCompletionInstance.AddMultipleNotDoneTasks(request.EventNumber, len(filteredTasks))
-		}
-
-		// Build response map for both HTTP response and broadcast
-		overviewBroadcastMsg := fiber.Map{
-			"message": "Overview added successfully",
-			"data": fiber.Map{
-				"event_number":   actualOverview.EventNumber,
-				"central_id":     actualOverview.CentralId,
-				"type":           actualOverview.Type,
-				"level":          request.NewLevel,
-				"incident_level": request.IncidentLevel,
-				"timestamp":      time.Now(),
-			},
+			return escalationErrorResponse(c, err)
 		}
 
-		// Convert the broadcast message to JSON
-		broadcastResponseJson, err := json.Marshal(overviewBroadcastMsg)
+		result, err := repos.Escalation.Deescalate(escalation)
 		if err != nil {
-			log.Errorf("Failed to marshal overview to JSON: %v\n", err)
-		} else {
-			// Broadcast to the "event_updates" topic
-			cm.BroadcastToTopic("event_updates", broadcastResponseJson)
+			return escalationErrorResponse(c, err)
 		}
 
+		broadcastEscalation(repos, cm, result)
+
 		// Return success response
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "Event level escalated successfully",
+			"message": "Event level de-escalated successfully",
+			"data":    result,
 		})
 	}
 }
 
-// PostDeEscalate handles de-escalation of an event level based on the provided request data and updates the escalation map.
-// It is the reverse operation of PostEscalate, undoing all the actions performed during escalation.
-func PostDeEscalate(repos *database.Repositories, confg config.Config, cm *broadcast.ConnectionManager) func(c *fiber.Ctx) error {
+// IncidentLevelRequest Request payload structure to change the incident level of an event within its level
+type IncidentLevelRequest struct {
+	EventNumber   int    `json:"eventNumber"`
+	IncidentLevel string `json:"incidentLevel"`
+	Reason        string `json:"reason"`
+}
+
+// PostIncidentLevel handles HTTP POST requests to move an event between the incident levels of its current level,
+// like from gialla to rossa within incidente, in both directions.
+// Raising the incident level adds the tasks of the higher colours, lowering it removes the "notdone" tasks of the colours left
+// and, like any de-escalation, requires the supervisor role.
+func PostIncidentLevel(repos *database.Repositories, confg config.Config, cm *broadcast.ConnectionManager) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		// Parse request body
-		var request EscalateRequest
+		var request IncidentLevelRequest
 		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Failed to parse request",
 			})
 		}
+		request.IncidentLevel = strings.ToLower(strings.TrimSpace(request.IncidentLevel))
+		if request.IncidentLevel == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: incidentLevel field should not be empty")
+		}
 
-		// Get escalation map instance
-		escalationLevels := database.GetEscalationLevelsInstance(nil)
-
-		// Get actual escalation levels
-		actualLevels := escalationLevels.GetLevels()
-		oldLevel := actualLevels[request.EventNumber]
-
-		// Call the Deescalate method
-		err := escalationLevels.Deescalate(request.EventNumber, request.NewLevel)
+		overview, err := repos.Overview.GetOverviewById(request.EventNumber)
+		if errors.Is(err, sql.ErrNoRows) {
+			return escalationErrorResponse(c, &database.NoEventsFoundError{Detail: fmt.Sprintf("No overview found for event number %d", request.EventNumber)})
+		}
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return escalationErrorResponse(c, err)
 		}
 
-		// Sync overview with new escalation level
-		err = repos.Overview.UpdateLevelByEventNumber(request.EventNumber, request.NewLevel, request.IncidentLevel)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+		// Unknown incident levels have no rank, reject them before telling raising from lowering
+		levelModel := database.CurrentLevelModel()
+		if !levelModel.HasSubLevels(overview.Level) {
+			return escalationErrorResponse(c, &database.InvalidEscalationError{Detail: fmt.Sprintf("%s has no incident levels", overview.Level)})
+		}
+		if !levelModel.IsSubLevel(overview.Level, request.IncidentLevel) {
+			return escalationErrorResponse(c, &database.InvalidEscalationError{Detail: fmt.Sprintf("incident level %q is not valid for %s", request.IncidentLevel, overview.Level)})
 		}
 
-		// Get actual event overview snapshot
-		actualOverview, err := repos.Overview.GetOverviewById(request.EventNumber)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
+		// Lowering the incident level is a de-escalation, it only needs the central to exist
+		lowering := levelModel.SubRank(overview.Level, request.IncidentLevel) < levelModel.SubRank(overview.Level, overview.IncidentLevel)
+		if lowering && currentUser(c).Role != database.RoleSupervisor {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":  "Forbidden",
+				"detail": "lowering the incident level requires the role: " + database.RoleSupervisor,
 			})
 		}
 
-		// Get all tasks for the event type
-		allTasks, err := repos.Tasks.GetByCategories(actualOverview.Type)
+		escalation, err := escalationRequest(c, repos, confg, EscalateRequest{
+			EventNumber:   request.EventNumber,
+			NewLevel:      database.Level(overview.Level),
+			IncidentLevel: request.IncidentLevel,
+			Reason:        request.Reason,
+		}, !lowering)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return escalationErrorResponse(c, err)
 		}
 
-		// Filter tasks that were added during escalation (from newLevel to oldLevel)
-		// These are the tasks we want to remove during de-escalation
-		tasksToRemove, err := database.FilterTasksForEscalation(allTasks, actualOverview.Type, string(request.NewLevel), string(oldLevel), request.IncidentLevel)
+		result, err := repos.Escalation.ChangeIncidentLevel(escalation)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return escalationErrorResponse(c, err)
 		}
 
-		// Get local Tasks based on selection
-		var localTasksToRemove []database.Task
-		// Load the correct local task file
-		f, err := config.LoadExcelFile(confg, actualOverview.CentralId)
-		if err == nil {
-			// Parse the file
-			localTasks, err := database.ParseXLSXToTasks(f)
-			if err != nil {
-				log.Errorf("Error parsing local task file: %s\n", err)
-				return fiber.NewError(fiber.StatusInternalServerError, "Failed to parse local task file")
-			}
+		broadcastEscalation(repos, cm, result)
 
-			// Filter the local file based on request body parameters (reverse direction)
-			filteredLocalTasks, err := database.FilterTasksForEscalation(localTasks, actualOverview.Type, string(request.NewLevel), string(oldLevel), request.IncidentLevel)
-			if err != nil {
-				log.Errorf("Error filtering local tasks for de-escalation: %s\n", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to filter local tasks for de-escalation",
-				})
-			}
+		// Return success response
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Event incident level changed successfully",
+			"data":    result,
+		})
+	}
+}
 
-			localTasksToRemove = filteredLocalTasks
-		}
+// escalationRequest builds the escalation of the event in request, with the tasks of the local task file of its central.
+// The authenticated user and its IP address are recorded as the operator changing the level.
+// Escalations require an active central, de-escalations only an existing one.
+func escalationRequest(c *fiber.Ctx, repos *database.Repositories, confg config.Config, request EscalateRequest, requireActive bool) (database.EscalationRequest, error) {
+	escalation := database.EscalationRequest{
+		EventNumber:   request.EventNumber,
+		NewLevel:      request.NewLevel,
+		IncidentLevel: request.IncidentLevel,
+		Operator:      currentUser(c).Username,
+		IpAddress:     c.IP(),
+		Reason:        strings.TrimSpace(request.Reason),
+	}
 
-		// Combine tasks to remove
-		var allTasksToRemove []database.Task
-		allTasksToRemove = append(allTasksToRemove, tasksToRemove...)
-		allTasksToRemove = append(allTasksToRemove, localTasksToRemove...)
+	// Get actual event overview snapshot
+	actualOverview, err := repos.Overview.GetOverviewById(request.EventNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return escalation, &database.NoEventsFoundError{Detail: fmt.Sprintf("No overview found for event number %d", request.EventNumber)}
+	}
+	if err != nil {
+		return escalation, err
+	}
 
-		// Create a map of task titles to remove for quick lookup
-		taskTitlesToRemove := make(map[string]bool)
-		for _, task := range allTasksToRemove {
-			taskTitlesToRemove[task.Title] = true
-		}
+	var central database.Central
+	if requireActive {
+		central, err = repos.Centrals.GetActive(actualOverview.CentralId)
+	} else {
+		central, err = repos.Centrals.GetByID(actualOverview.CentralId)
+	}
+	if err != nil {
+		return escalation, err
+	}
 
-		// Get active events for this event number and central ID
-		activeEvents, err := repos.ActiveEvents.GetByCentralAndNumber(request.EventNumber, actualOverview.CentralId)
+	// Load the correct local task file, centrals without one use the main tasks only
+	f, err := loadCentralTaskFile(confg, central)
+	if err == nil {
+		localTasks, err := database.ParseXLSXToTasks(f)
 		if err != nil {
-			if _, ok := err.(*database.NoEventsFoundError); !ok {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": err.Error(),
-				})
-			}
+			log.Errorf("Error parsing local task file: %s\n", err)
+			return escalation, fiber.NewError(fiber.StatusInternalServerError, "Failed to parse local task file")
 		}
+		escalation.LocalTasks = localTasks
+	}
 
-		// Count tasks that will be removed
-		tasksToBeRemoved := 0
-		for _, event := range activeEvents {
-			if taskTitlesToRemove[event.Title] && event.Status == database.TaskNotdone {
-				tasksToBeRemoved++
-			}
-		}
-
-		// If there are tasks to remove, update the task completion map
-		if tasksToBeRemoved > 0 {
-			// Get singleton instance of TaskCompletionMap
-			taskCompletionInstance := database.GetTaskCompletionMapInstance(nil, cm)
-
-			// Get current task info and update it
-			// We need to do this before deleting the event to preserve the completion count
-			aggregatedEvents, err := repos.ActiveEvents.GetAggregatedEventStatus()
-			if err == nil {
-				for _, event := range aggregatedEvents {
-					if event.EventNumber == request.EventNumber {
-						// Calculate new total after removing tasks
-						newTotal := event.Total - tasksToBeRemoved
-						if newTotal < 0 {
-							newTotal = 0
-						}
-
-						// Add the event with updated counts
-						// This will overwrite the existing event in the map
-						taskCompletionInstance.AddNewEvent(request.EventNumber, newTotal)
-						break
-					}
-				}
-			}
-
-			// Delete all tasks for this event
-			err = repos.ActiveEvents.DeleteEvent(request.EventNumber, actualOverview.CentralId)
-			if err != nil {
-				log.Errorf("Error deleting event tasks: %s\n", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to delete event tasks",
-				})
-			}
+	return escalation, nil
+}
 
-			// Re-add tasks that should remain after de-escalation
-			var tasksToKeep []database.Task
-			for _, event := range activeEvents {
-				if !taskTitlesToRemove[event.Title] || event.Status != database.TaskNotdone {
-					// Convert ActiveEvents back to Task
-					task := database.Task{
-						Priority:        event.Priority,
-						Title:           event.Title,
-						Description:     event.Description,
-						Role:            event.Role,
-						Category:        actualOverview.Type,
-						EscalationLevel: event.EscalationLevel,
-					}
-					tasksToKeep = append(tasksToKeep, task)
-				}
-			}
+// escalationErrorResponse maps escalation errors to the HTTP response to return
+func escalationErrorResponse(c *fiber.Ctx, err error) error {
+	switch err.(type) {
+	case *fiber.Error:
+		return err
+	case *database.CentralNotFoundError, *database.CentralInactiveError:
+		return centralErrorResponse(c, err)
+	case *database.InvalidEscalationError:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid escalation",
+			"detail": err.Error(),
+		})
+	case *database.NoEventsFoundError:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "Event not found",
+			"detail": err.Error(),
+		})
+	default:
+		log.Errorf("Error changing event level: %s\n", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "Failed to change event level",
+			"detail": err.Error(),
+		})
+	}
+}
 
-			// Re-add the tasks that should remain
-			if len(tasksToKeep) > 0 {
-				err = repos.ActiveEvents.CreateFromTaskList(tasksToKeep, request.EventNumber, actualOverview.CentralId)
-				if err != nil {
-					log.Errorf("Error re-adding tasks: %s\n", err)
-					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-						"error": "Failed to re-add tasks",
-					})
-				}
-			}
-		}
+// broadcastEscalation bumps the revision of the escalated event and broadcasts its new level to the "event_updates" topic,
+// and the escalation history entry to the event central topic
+func broadcastEscalation(repos *database.Repositories, cm *broadcast.ConnectionManager, result database.EscalationResult) {
+	overview := result.Overview
+
+	// Build broadcast message
+	overviewBroadcastMsg := fiber.Map{
+		"message": "Overview added successfully",
+		"data": fiber.Map{
+			"event_number":   overview.EventNumber,
+			"central_id":     overview.CentralId,
+			"type":           overview.Type,
+			"level":          overview.Level,
+			"incident_level": overview.IncidentLevel,
+			"timestamp":      time.Now(),
+		},
+		"revision": bumpEventRevision(repos, overview.CentralId, overview.EventNumber),
+	}
 
-		// Build response map for both HTTP response and broadcast
-		overviewBroadcastMsg := fiber.Map{
-			"message": "Overview added successfully",
-			"data": fiber.Map{
-				"event_number":   actualOverview.EventNumber,
-				"central_id":     actualOverview.CentralId,
-				"type":           actualOverview.Type,
-				"level":          request.NewLevel,
-				"incident_level": request.IncidentLevel,
-				"timestamp":      time.Now(),
-			},
-		}
+	// Convert the broadcast message to JSON
+	broadcastResponseJson, err := json.Marshal(overviewBroadcastMsg)
+	if err != nil {
+		log.Errorf("Failed to marshal overview to JSON: %v\n", err)
+		return
+	}
 
-		// Convert the broadcast message to JSON
-		broadcastResponseJson, err := json.Marshal(overviewBroadcastMsg)
-		if err != nil {
-			log.Errorf("Failed to marshal overview to JSON: %v\n", err)
-		} else {
-			// Broadcast to the "event_updates" topic
-			cm.BroadcastToTopic("event_updates", broadcastResponseJson)
-		}
+	// Broadcast to the "event_updates" topic
+	cm.BroadcastToTopic("event_updates", broadcastResponseJson)
 
-		// Return success response
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": "Event level de-escalated successfully",
-		})
-	}
+	broadcastToCentral(cm, overview.CentralId, fiber.Map{
+		"type":    "escalation_history_added",
+		"message": "Event level changed",
+		"data":    result.History,
+	})
 }
 
 // GetAllEscalationDetails retrieves all escalation details from the database and returns them in the HTTP response.
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPostIncidentLevel_Validation tests that invalid incident levels are rejected before checking the role,
+// and that only supervisors can lower the incident level
+func TestPostIncidentLevel_Validation(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	require.NoError(t, os.WriteFile(dbFile, nil, 0600))
+	confg := config.Config{Variable: map[string]interface{}{string(config.DbFile): dbFile}}
+	db, err := database.GetInstance(confg)
+	require.NoError(t, err)
+
+	_, err = database.NewEscalationLevelsDefinitionRepository(db).LoadModel()
+	require.NoError(t, err)
+	repos := &database.Repositories{Overview: database.NewOverviewRepository(db)}
+	require.NoError(t, repos.Overview.Add(&database.Overview{CentralId: "SRA", EventNumber: 5, Location: "Milano", Type: "fire",
+		Level: string(database.Incidente), IncidentLevel: "gialla"}))
+	require.NoError(t, repos.Overview.Add(&database.Overview{CentralId: "SRA", EventNumber: 6, Location: "Milano", Type: "fire",
+		Level: string(database.Allarme)}))
+
+	app := fiber.New()
+
+	// Simulate RequireAuth with the role from a header
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.Locals(userLocalKey, database.User{Username: "mario", Role: ctx.Get("X-Test-Role"), Active: true})
+		return ctx.Next()
+	})
+	app.Post("/incident-level", PostIncidentLevel(repos, confg, nil))
+
+	tests := []struct {
+		name   string
+		body   string
+		role   string
+		status int
+	}{
+		{"UnknownColourByOperator", `{"eventNumber": 5, "incidentLevel": "viola"}`, database.RoleOperator, http.StatusBadRequest},
+		{"UnknownColourBySupervisor", `{"eventNumber": 5, "incidentLevel": "viola"}`, database.RoleSupervisor, http.StatusBadRequest},
+		{"LevelWithoutColours", `{"eventNumber": 6, "incidentLevel": "verde"}`, database.RoleOperator, http.StatusBadRequest},
+		{"LoweringByOperator", `{"eventNumber": 5, "incidentLevel": "verde"}`, database.RoleOperator, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/incident-level", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Test-Role", tt.role)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
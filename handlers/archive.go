@@ -0,0 +1,154 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/database"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"strconv"
+)
+
+type closeEventRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CloseEvent is a handler function that closes an active event and moves it to the archive.
+// It reads the central ID and event number from the URL and expects a JSON body with the closure reason, the closing user is the authenticated one.
+// If the parameters or the body are invalid, it returns a "400 Bad Request" error.
+// If the event does not exist, it returns a "404 Not Found" error.
+// On success the closure is broadcast to the "event_updates" topic and to the central specific topic.
+func CloseEvent(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		// Read event number from url param
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		var body closeEventRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.Reason == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Reason field should not be empty")
+		}
+
+		closedEvent, err := repos.Archive.CloseEvent(eventNumber, centralId, currentUser(ctx).Username, body.Reason)
+		if err != nil {
+			switch err.(type) {
+			case *database.NoEventsFoundError:
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Event not found",
+				})
+			default:
+				log.Errorf("Error closing event: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to close event",
+					"detail": err.Error(),
+				})
+			}
+		}
+
+		// Build response map for both HTTP response and broadcast
+		responseMap := fiber.Map{
+			"type":     "event_closed",
+			"message":  "Event closed successfully",
+			"data":     closedEvent,
+			"revision": bumpEventRevision(repos, centralId, eventNumber),
+		}
+
+		// Send broadcast response via connection manager in JSON format
+		// If error skip broadcast phase
+		responseJson, err := json.Marshal(responseMap)
+		if err != nil {
+			log.Errorf("Failed to marshal closed event to JSON: %v\n", err)
+		} else {
+			cm.BroadcastToTopic("event_updates", responseJson)
+			cm.BroadcastToTopic("central_"+centralId, responseJson)
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(responseMap)
+	}
+}
+
+// GetArchivedEvents retrieves the overviews of all the closed events of a central.
+func GetArchivedEvents(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		overviews, err := repos.Archive.GetOverviewsByCentralId(centralId)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get archived events",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved archived events",
+			"length": len(overviews),
+			"data":   overviews,
+		})
+	}
+}
+
+// GetArchivedEvent retrieves the overview and the archived tasks of a closed event.
+// If no archived task exists for the central ID and event number, it returns a "404 Not Found" error.
+func GetArchivedEvent(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		overviews, err := repos.Archive.GetOverviewsByCentralIdAndEventNumber(centralId, eventNumber)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get archived overview",
+				"detail": err.Error(),
+			})
+		}
+
+		tasks, err := repos.Archive.GetTasksByCentralAndNumber(eventNumber, centralId)
+		if err != nil {
+			if _, ok := err.(*database.NoEventsFoundError); !ok {
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to get archived tasks",
+					"detail": err.Error(),
+				})
+			}
+		}
+
+		if len(overviews) == 0 && len(tasks) == 0 {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Archived event not found",
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":   "Archived event found",
+			"overview": overviews,
+			"tasks":    tasks,
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/database"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+type assignTaskRequest struct {
+	UUID       uuid.UUID `json:"uuid"`
+	AssignedTo string    `json:"assigned_to"`
+}
+
+type claimTaskRequest struct {
+	UUID uuid.UUID `json:"uuid"`
+}
+
+// assignmentErrorResponse maps task assignment errors to the HTTP response to return
+func assignmentErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch err.(type) {
+	case *database.TaskNotFoundError:
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "Task not found",
+			"detail": err.Error(),
+		})
+	case *database.TaskAlreadyAssignedError:
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "Task already assigned to another operator",
+			"detail": err.Error(),
+		})
+	default:
+		log.Errorf("Error assigning event task: %s\n", err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "Failed to assign event task",
+			"detail": err.Error(),
+		})
+	}
+}
+
+// broadcastAssignment sends the updated task and the new event revision to the subscribers of the task central topic
+// and returns the response map
+func broadcastAssignment(repos *database.Repositories, cm *broadcast.ConnectionManager, task database.ActiveEvents) fiber.Map {
+	responseMap := fiber.Map{
+		"type":     "task_assigned",
+		"message":  "Event task assignment updated",
+		"data":     task,
+		"revision": bumpEventRevision(repos, task.CentralID, task.EventNumber),
+	}
+
+	// Send broadcast response via connection manager in JSON format
+	// If error skip broadcast phase
+	responseJson, err := json.Marshal(responseMap)
+	if err != nil {
+		log.Errorf("Failed to marshal assigned task to JSON: %v\n", err)
+	} else {
+		cm.BroadcastToTopic("central_"+task.CentralID, responseJson)
+	}
+
+	return responseMap
+}
+
+// AssignEventTask assigns an active event task to a named operator.
+// It expects a JSON body with the task UUID, the user to assign it to, the assignment is recorded as made by the authenticated user.
+// An empty assigned_to removes the current assignment.
+// If the task does not exist, it returns a "404 Not Found" error.
+func AssignEventTask(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body assignTaskRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.UUID == uuid.Nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: UUID field should not be empty")
+		}
+
+		// Tasks can only be assigned to existing, active users
+		if body.AssignedTo != "" {
+			assignee, err := repos.Users.GetByUsername(body.AssignedTo)
+			if _, ok := err.(*database.UserNotFoundError); ok || (err == nil && !assignee.Active) {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid request: AssignedTo should be an active user")
+			} else if err != nil {
+				return userErrorResponse(ctx, err, "Failed to get assignee")
+			}
+		}
+
+		task, err := repos.ActiveEvents.Assign(body.UUID, body.AssignedTo, currentUser(ctx).Username, ctx.IP())
+		if err != nil {
+			return assignmentErrorResponse(ctx, err)
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(broadcastAssignment(repos, cm, task))
+	}
+}
+
+// ClaimEventTask assigns an unassigned active event task to the authenticated user.
+// It expects a JSON body with the task UUID, the task is claimed by the authenticated user.
+// If the task does not exist, it returns a "404 Not Found" error.
+// If the task is already assigned to another operator, it returns a "409 Conflict" error.
+func ClaimEventTask(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body claimTaskRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.UUID == uuid.Nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: UUID field should not be empty")
+		}
+
+		task, err := repos.ActiveEvents.Claim(body.UUID, currentUser(ctx).Username, ctx.IP())
+		if err != nil {
+			return assignmentErrorResponse(ctx, err)
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(broadcastAssignment(repos, cm, task))
+	}
+}
+
+// GetOperatorOpenTasks retrieves the tasks assigned to the operator in the URL that are not done yet, across all active events.
+func GetOperatorOpenTasks(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		operator := ctx.Params("operator")
+		if operator == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Operator field should not be empty")
+		}
+
+		tasks, err := repos.ActiveEvents.GetOpenTasksByAssignee(operator)
+		if err != nil {
+			log.Errorf("Error getting operator open tasks: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get operator open tasks",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved operator open tasks",
+			"length": len(tasks),
+			"data":   tasks,
+		})
+	}
+}
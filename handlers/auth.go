@@ -0,0 +1,346 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/auth"
+	"dogeplus-backend/database"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"strings"
+)
+
+// userLocalKey is the fiber.Ctx locals key holding the authenticated database.User
+const userLocalKey = "user"
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errUserInactive = errors.New("user is not active")
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type userRequest struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Role     string    `json:"role"`
+	Active   *bool     `json:"active"`
+	Centrals *[]string `json:"centrals"`
+}
+
+// unauthorizedResponse returns a "401 Unauthorized" response with the given detail
+func unauthorizedResponse(ctx *fiber.Ctx, detail string) error {
+	return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error":  "Unauthorized",
+		"detail": detail,
+	})
+}
+
+// bearerToken extracts the token from the "Authorization: Bearer <token>" header
+func bearerToken(ctx *fiber.Ctx) string {
+	scheme, token, found := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// authenticate verifies a session token and loads its user.
+// The user is read from the database on every request, so deactivations and role changes apply immediately.
+func authenticate(repos *database.Repositories, signer *auth.TokenSigner, token string) (database.User, error) {
+	if token == "" {
+		return database.User{}, errMissingToken
+	}
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err := repos.Users.GetByUsername(claims.Username)
+	if err != nil {
+		return database.User{}, err
+	}
+	if !user.Active {
+		return database.User{}, errUserInactive
+	}
+
+	return user, nil
+}
+
+// isAuthenticationFailure reports whether an authenticate error is caused by the request credentials
+func isAuthenticationFailure(err error) bool {
+	var notFound *database.UserNotFoundError
+	return errors.Is(err, errMissingToken) || errors.Is(err, errUserInactive) || errors.As(err, &notFound) ||
+		errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrExpiredToken)
+}
+
+// RequireAuth is a middleware that rejects requests without a valid session token with "401 Unauthorized".
+// The authenticated user is stored in the request locals, see currentUser.
+func RequireAuth(repos *database.Repositories, signer *auth.TokenSigner) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		user, err := authenticate(repos, signer, bearerToken(ctx))
+		if err != nil {
+			if !isAuthenticationFailure(err) {
+				log.Errorf("Error authenticating request: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to authenticate request",
+					"detail": err.Error(),
+				})
+			}
+			return unauthorizedResponse(ctx, err.Error())
+		}
+
+		ctx.Locals(userLocalKey, user)
+		return ctx.Next()
+	}
+}
+
+// RequireRoles is a middleware that only lets through users with one of the given roles,
+// others get a "403 Forbidden" response. It must be registered after RequireAuth.
+func RequireRoles(roles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		user := currentUser(ctx)
+		for _, role := range roles {
+			if user.Role == role {
+				return ctx.Next()
+			}
+		}
+
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":  "Forbidden",
+			"detail": "this action requires one of the roles: " + strings.Join(roles, ", "),
+		})
+	}
+}
+
+// currentUser returns the user authenticated by RequireAuth, a zero User if the request is not authenticated
+func currentUser(ctx *fiber.Ctx) database.User {
+	user, _ := ctx.Locals(userLocalKey).(database.User)
+	return user
+}
+
+// userErrorResponse maps user errors to the HTTP response to return
+func userErrorResponse(ctx *fiber.Ctx, err error, message string) error {
+	switch err.(type) {
+	case *database.UserNotFoundError:
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "User not found",
+			"detail": err.Error(),
+		})
+	case *database.UserExistsError:
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "User already exists",
+			"detail": err.Error(),
+		})
+	case *database.InvalidRoleError:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid role",
+			"detail": err.Error(),
+		})
+	case *database.CentralNotFoundError:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Unknown central",
+			"detail": err.Error(),
+		})
+	}
+
+	log.Errorf("%s: %s\n", message, err)
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":  message,
+		"detail": err.Error(),
+	})
+}
+
+// Login checks the user credentials and returns a signed session token.
+// Unknown users, wrong passwords and inactive users get the same "401 Unauthorized" response.
+func Login(repos *database.Repositories, signer *auth.TokenSigner) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body loginRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.Username == "" || body.Password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Username and Password fields should not be empty")
+		}
+
+		user, err := repos.Users.Authenticate(body.Username, body.Password)
+		if err != nil {
+			if _, ok := err.(*database.InvalidCredentialsError); ok {
+				log.Warnf("Failed login for %s from %s\n", body.Username, ctx.IP())
+				return unauthorizedResponse(ctx, "invalid username or password")
+			}
+			return userErrorResponse(ctx, err, "Failed to authenticate user")
+		}
+
+		token, expiresAt, err := signer.Sign(user.Username, user.Role)
+		if err != nil {
+			return userErrorResponse(ctx, err, "Failed to sign token")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":     "Logged in",
+			"token":      token,
+			"expires_at": expiresAt,
+			"data":       user,
+		})
+	}
+}
+
+// GetCurrentUser returns the authenticated user.
+func GetCurrentUser(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"result": "User found",
+		"data":   currentUser(ctx),
+	})
+}
+
+// PutOwnPassword changes the password of the authenticated user, the current password must be provided.
+func PutOwnPassword(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body changePasswordRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.NewPassword == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: NewPassword field should not be empty")
+		}
+
+		user := currentUser(ctx)
+		if _, err := repos.Users.Authenticate(user.Username, body.CurrentPassword); err != nil {
+			if _, ok := err.(*database.InvalidCredentialsError); ok {
+				return unauthorizedResponse(ctx, "current password is wrong")
+			}
+			return userErrorResponse(ctx, err, "Failed to authenticate user")
+		}
+
+		if err := repos.Users.SetPassword(user.Username, body.NewPassword); err != nil {
+			return userErrorResponse(ctx, err, "Failed to change password")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Password changed",
+		})
+	}
+}
+
+// GetAllUsers retrieves all the users.
+func GetAllUsers(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		users, err := repos.Users.GetAll()
+		if err != nil {
+			return userErrorResponse(ctx, err, "Failed to get users")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved users",
+			"length": len(users),
+			"data":   users,
+		})
+	}
+}
+
+// PostUser creates a new user from a JSON body with username, password, role and the optional centrals it belongs to.
+// If the username is taken, it returns a "409 Conflict" error.
+func PostUser(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body userRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if strings.TrimSpace(body.Username) == "" || body.Password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Username and Password fields should not be empty")
+		}
+
+		var centrals []string
+		if body.Centrals != nil {
+			centrals = *body.Centrals
+		}
+
+		// The user is only created if all its centrals exist
+		user, err := repos.Users.Add(body.Username, body.Password, body.Role, centrals...)
+		if err != nil {
+			return userErrorResponse(ctx, err, "Failed to add user")
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"result": "User created",
+			"data":   user,
+		})
+	}
+}
+
+// PutUser updates role and active flag of a user, and its password and centrals when provided.
+// If the user does not exist, it returns a "404 Not Found" error.
+func PutUser(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body userRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		username := ctx.Params("username")
+		existing, err := repos.Users.GetByUsername(username)
+		if err != nil {
+			return userErrorResponse(ctx, err, "Failed to get user")
+		}
+
+		role := existing.Role
+		if body.Role != "" {
+			role = body.Role
+		}
+		active := existing.Active
+		if body.Active != nil {
+			active = *body.Active
+		}
+
+		// Don't let an admin lock themselves out
+		if username == currentUser(ctx).Username && (!active || role != existing.Role) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: you can't change your own role or deactivate yourself")
+		}
+
+		user, err := repos.Users.Update(username, role, active)
+		if err != nil {
+			return userErrorResponse(ctx, err, "Failed to update user")
+		}
+
+		if body.Password != "" {
+			if err := repos.Users.SetPassword(username, body.Password); err != nil {
+				return userErrorResponse(ctx, err, "Failed to change password")
+			}
+		}
+
+		if body.Centrals != nil {
+			if err := repos.Users.SetCentrals(username, *body.Centrals); err != nil {
+				return userErrorResponse(ctx, err, "Failed to set user centrals")
+			}
+			if user, err = repos.Users.GetByUsername(username); err != nil {
+				return userErrorResponse(ctx, err, "Failed to get user")
+			}
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "User updated",
+			"data":   user,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRequireRoles tests that only users with one of the required roles get through
+func TestRequireRoles(t *testing.T) {
+	app := fiber.New()
+
+	// Simulate RequireAuth with the role from a header
+	app.Use(func(ctx *fiber.Ctx) error {
+		if role := ctx.Get("X-Test-Role"); role != "" {
+			ctx.Locals(userLocalKey, database.User{Username: "mario", Role: role, Active: true})
+		}
+		return ctx.Next()
+	})
+	app.Post("/deescalate", RequireRoles(database.RoleSupervisor), func(ctx *fiber.Ctx) error {
+		return ctx.SendString(currentUser(ctx).Username)
+	})
+
+	tests := []struct {
+		role   string
+		status int
+	}{
+		{database.RoleSupervisor, http.StatusOK},
+		{database.RoleOperator, http.StatusForbidden},
+		{database.RoleProcedureAdmin, http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/deescalate", nil)
+		req.Header.Set("X-Test-Role", tt.role)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, tt.status, resp.StatusCode, tt.role)
+	}
+}
+
+// TestBearerToken tests the extraction of the token from the Authorization header
+func TestBearerToken(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		return ctx.SendString(bearerToken(ctx))
+	})
+
+	tests := map[string]string{
+		"Bearer abc.def": "abc.def",
+		"bearer abc.def": "abc.def",
+		"Basic abc":      "",
+		"abc.def":        "",
+		"":               "",
+	}
+
+	for header, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, header)
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		assert.Equal(t, expected, string(body[:n]), header)
+	}
+}
+
+// TestCentralFromTopic tests the extraction of the central id from per central topics
+func TestCentralFromTopic(t *testing.T) {
+	centralId, ok := centralFromTopic("central_SRA")
+	assert.True(t, ok)
+	assert.Equal(t, "SRA", centralId)
+
+	for _, topic := range []string{"central_", "event_updates", "task_completion_update", "SRA"} {
+		_, ok := centralFromTopic(topic)
+		assert.False(t, ok, topic)
+	}
+}
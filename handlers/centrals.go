@@ -0,0 +1,232 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/xuri/excelize/v2"
+	"path/filepath"
+	"strings"
+)
+
+type centralRequest struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	TaskFile string `json:"task_file"`
+	Active   *bool  `json:"active"`
+}
+
+// toCentral validates the request and converts it to a database.Central.
+// Active defaults to true when omitted.
+func (r centralRequest) toCentral() (database.Central, error) {
+	if strings.TrimSpace(r.ID) == "" {
+		return database.Central{}, fmt.Errorf("Invalid request: ID field should not be empty")
+	}
+
+	if strings.TrimSpace(r.Name) == "" {
+		return database.Central{}, fmt.Errorf("Invalid request: Name field should not be empty")
+	}
+
+	if r.TaskFile != "" && filepath.Base(r.TaskFile) != r.TaskFile {
+		return database.Central{}, fmt.Errorf("Invalid request: TaskFile should not contain path separators")
+	}
+
+	active := true
+	if r.Active != nil {
+		active = *r.Active
+	}
+
+	return database.Central{ID: r.ID, Name: r.Name, TaskFile: r.TaskFile, Active: active}, nil
+}
+
+// centralErrorResponse maps central lookup errors to the HTTP response to return
+func centralErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch err.(type) {
+	case *database.CentralNotFoundError:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Unknown central",
+			"detail": err.Error(),
+		})
+	case *database.CentralInactiveError:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Central is not active",
+			"detail": err.Error(),
+		})
+	default:
+		log.Errorf("Error getting central: %s\n", err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "Failed to get central",
+			"detail": err.Error(),
+		})
+	}
+}
+
+// loadCentralTaskFile opens the local task file configured for the central.
+// It returns an error if the central has no local task file or the file can't be loaded,
+// callers fall back to the main tasks only.
+func loadCentralTaskFile(confg config.Config, central database.Central) (*excelize.File, error) {
+	if central.TaskFile == "" {
+		return nil, fmt.Errorf("central %s has no local task file", central.ID)
+	}
+
+	return config.LoadExcelFile(confg, central.TaskFile)
+}
+
+// localTaskFilename returns the local task file name of the central, defaulting to "<central id>.xlsx"
+func localTaskFilename(central database.Central) string {
+	if central.TaskFile != "" {
+		return central.TaskFile
+	}
+
+	return central.ID + ".xlsx"
+}
+
+// GetAllCentrals retrieves all the configured centrals, active and inactive.
+func GetAllCentrals(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centrals, err := repos.Centrals.GetAll()
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get centrals",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved centrals",
+			"length": len(centrals),
+			"data":   centrals,
+		})
+	}
+}
+
+// GetCentral retrieves a single central by the id in the URL.
+// If the central does not exist, it returns a "404 Not Found" error.
+func GetCentral(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		central, err := repos.Centrals.GetByID(ctx.Params("id"))
+		if err != nil {
+			if _, ok := err.(*database.CentralNotFoundError); ok {
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Central not found",
+				})
+			}
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get central",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Central found",
+			"data":   central,
+		})
+	}
+}
+
+// PostCentral creates a new central.
+// It expects a JSON body with id, name, the optional local task file name and the optional active flag (default true).
+// If the body is invalid, it returns a "400 Bad Request" error.
+func PostCentral(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body centralRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		central, err := body.toCentral()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if err := repos.Centrals.Add(central); err != nil {
+			log.Errorf("Error adding central: %s\n", err)
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Failed to add central",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"result": "Central created",
+			"data":   central,
+		})
+	}
+}
+
+// PutCentral updates name, local task file and active flag of the central identified by the id in the URL.
+// If the central does not exist, it returns a "404 Not Found" error.
+func PutCentral(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		var body centralRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		// Id from url always wins over the body one
+		body.ID = ctx.Params("id")
+
+		central, err := body.toCentral()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if err := repos.Centrals.Update(central); err != nil {
+			if _, ok := err.(*database.CentralNotFoundError); ok {
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Central not found",
+				})
+			}
+			log.Errorf("Error updating central: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to update central",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Central updated",
+			"data":   central,
+		})
+	}
+}
+
+// DeleteCentral removes the central identified by the id in the URL.
+// If the central does not exist, it returns a "404 Not Found" error.
+// If the central still has active events, it returns a "409 Conflict" error, the central should be deactivated instead.
+func DeleteCentral(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		err := repos.Centrals.Delete(ctx.Params("id"))
+		if err != nil {
+			switch err.(type) {
+			case *database.CentralNotFoundError:
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Central not found",
+				})
+			case *database.CentralInUseError:
+				return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error":  "Central has active events, deactivate it instead",
+					"detail": err.Error(),
+				})
+			default:
+				log.Errorf("Error deleting central: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to delete central",
+					"detail": err.Error(),
+				})
+			}
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Central deleted",
+		})
+	}
+}
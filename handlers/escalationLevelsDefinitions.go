@@ -3,13 +3,17 @@ package handlers
 import (
 	"dogeplus-backend/database"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/google/uuid"
+	"strings"
 )
 
 type EscalationLevelsDefinitionsRequest struct {
 	ID          uuid.UUID `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Rank        int       `json:"rank"`
+	SubLevels   []string  `json:"sub_levels"`
 }
 
 func GetAllEscalationLevelsDefinitions(repos *database.Repositories) func(c *fiber.Ctx) error {
@@ -30,3 +34,69 @@ func GetAllEscalationLevelsDefinitions(repos *database.Repositories) func(c *fib
 		})
 	}
 }
+
+// GetLevelModel returns the escalation ladder in use, with the sub-levels allowed under each level
+func GetLevelModel() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		levels := database.CurrentLevelModel().Definitions()
+
+		return c.JSON(fiber.Map{
+			"result": "Retrieved level model",
+			"length": len(levels),
+			"data":   levels,
+		})
+	}
+}
+
+// PostEscalationLevelDefinition adds an escalation level definition and reloads the level model.
+// A rank greater than 0 adds the level to the escalation ladder, after the levels with a lower or equal rank.
+func PostEscalationLevelDefinition(repos *database.Repositories) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body EscalationLevelsDefinitionsRequest
+		if err := c.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		name := strings.ToLower(strings.TrimSpace(body.Name))
+		if name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Name field should not be empty")
+		}
+		if body.Rank < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Rank field should not be negative")
+		}
+
+		subLevels := make([]string, 0, len(body.SubLevels))
+		for _, subLevel := range body.SubLevels {
+			subLevel = strings.ToLower(strings.TrimSpace(subLevel))
+			if subLevel == "" {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid request: SubLevels should not contain empty names")
+			}
+			subLevels = append(subLevels, subLevel)
+		}
+
+		if body.ID == uuid.Nil {
+			body.ID = uuid.New()
+		}
+		definition := database.EscalationLevelsDefinition{
+			UUID:        body.ID,
+			Name:        name,
+			Description: body.Description,
+			Rank:        body.Rank,
+			SubLevels:   subLevels,
+		}
+
+		if err := repos.EscalationLevelsDefinition.Add(definition); err != nil {
+			log.Errorf("Error adding escalation level: %s\n", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Failed to add escalation level",
+				"detail": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"result": "Escalation level created",
+			"data":   definition,
+		})
+	}
+}
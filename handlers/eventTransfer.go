@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/database"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+)
+
+type mergeEventRequest struct {
+	TargetEventNumber int `json:"target_event_number"`
+}
+
+type transferEventRequest struct {
+	TargetCentralId   string      `json:"target_central_id"`
+	TargetEventNumber int         `json:"target_event_number"`
+	TaskUUIDs         []uuid.UUID `json:"task_uuids"`
+}
+
+// MergeEvent merges an event into another one, when two calls turn out to be the same incident.
+// It reads the central ID and event number of the merged event from the URL and expects a JSON body with the target event number.
+// Tasks with the same title keep the most advanced status, the merged event is closed.
+// If the parameters or the body are invalid, it returns a "400 Bad Request" error.
+// If either event does not exist, it returns a "404 Not Found" error.
+// On success the merge is broadcast to the "event_updates" topic and to the topics of both centrals.
+func MergeEvent(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId, eventNumber, err := eventParams(ctx)
+		if err != nil {
+			return err
+		}
+
+		var body mergeEventRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if body.TargetEventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: target_event_number should be a non zero integer")
+		}
+
+		result, err := repos.Transfer.Merge(database.MergeRequest{
+			CentralId:         centralId,
+			EventNumber:       eventNumber,
+			TargetEventNumber: body.TargetEventNumber,
+			Operator:          currentUser(ctx).Username,
+			IpAddress:         ctx.IP(),
+		})
+		if err != nil {
+			return transferErrorResponse(ctx, err)
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(broadcastTransfer(repos, cm, "event_merged", "Event merged successfully", result))
+	}
+}
+
+// TransferEvent transfers an event, or some of its tasks, to another central.
+// It reads the central ID and event number of the source event from the URL and expects a JSON body with the target central.
+// Without task UUIDs the whole event is moved keeping its number, otherwise the tasks are moved to the target event number,
+// an open event of the target central or a new one created from the source event overview.
+// If the parameters or the body are invalid, or the target central is unknown or inactive, it returns a "400 Bad Request" error.
+// If the source event does not exist, it returns a "404 Not Found" error.
+// On success the transfer is broadcast to the "event_updates" topic and to the topics of both centrals.
+func TransferEvent(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId, eventNumber, err := eventParams(ctx)
+		if err != nil {
+			return err
+		}
+
+		var body transferEventRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		body.TargetCentralId = strings.TrimSpace(body.TargetCentralId)
+		if body.TargetCentralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: target_central_id should not be empty")
+		}
+
+		// Only configured and active centrals can take over events
+		if _, err := repos.Centrals.GetActive(body.TargetCentralId); err != nil {
+			return transferErrorResponse(ctx, err)
+		}
+
+		result, err := repos.Transfer.Transfer(database.TransferRequest{
+			CentralId:         centralId,
+			EventNumber:       eventNumber,
+			TargetCentralId:   body.TargetCentralId,
+			TargetEventNumber: body.TargetEventNumber,
+			TaskUUIDs:         body.TaskUUIDs,
+			Operator:          currentUser(ctx).Username,
+			IpAddress:         ctx.IP(),
+		})
+		if err != nil {
+			return transferErrorResponse(ctx, err)
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(broadcastTransfer(repos, cm, "event_transferred", "Event transferred successfully", result))
+	}
+}
+
+// eventParams reads the central ID and the event number from the URL
+func eventParams(ctx *fiber.Ctx) (string, int, error) {
+	centralId := ctx.Params("central_id")
+	if centralId == "" {
+		return "", 0, fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+	}
+
+	eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+	if err != nil || eventNumber == 0 {
+		return "", 0, fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+	}
+
+	return centralId, eventNumber, nil
+}
+
+// transferErrorResponse maps merge and transfer errors to the HTTP response to return
+func transferErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch err.(type) {
+	case *database.CentralNotFoundError, *database.CentralInactiveError:
+		return centralErrorResponse(ctx, err)
+	case *database.InvalidTransferError:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid transfer",
+			"detail": err.Error(),
+		})
+	case *database.NoEventsFoundError:
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "Event not found",
+			"detail": err.Error(),
+		})
+	default:
+		log.Errorf("Error transferring event: %s\n", err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "Failed to transfer event",
+			"detail": err.Error(),
+		})
+	}
+}
+
+// broadcastTransfer bumps the revisions of the source and target events and broadcasts the change to the "event_updates" topic
+// and to the topics of both centrals. It returns the broadcast message, used as response too.
+func broadcastTransfer(repos *database.Repositories, cm *broadcast.ConnectionManager, messageType string, message string, result database.TransferResult) fiber.Map {
+	response := fiber.Map{
+		"type":            messageType,
+		"message":         message,
+		"data":            result,
+		"source_revision": bumpEventRevision(repos, result.Source.CentralId, result.Source.EventNumber),
+		"target_revision": bumpEventRevision(repos, result.Target.CentralId, result.Target.EventNumber),
+	}
+
+	// Send broadcast response via connection manager in JSON format
+	// If error skip broadcast phase
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		log.Errorf("Failed to marshal %s message to JSON: %v\n", messageType, err)
+		return response
+	}
+
+	cm.BroadcastToTopic("event_updates", responseJson)
+	cm.BroadcastToTopic("central_"+result.Source.CentralId, responseJson)
+	if result.Target.CentralId != result.Source.CentralId {
+		cm.BroadcastToTopic("central_"+result.Target.CentralId, responseJson)
+	}
+
+	return response
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"os"
+	"runtime"
+	"time"
+)
+
+// readinessTimeout bounds the database ping of the readiness check
+const readinessTimeout = 2 * time.Second
+
+// startedAt is the process start time reported by the diagnostics endpoint
+var startedAt = time.Now()
+
+// Healthz reports the process is alive. It doesn't check any dependency, see Readyz for that.
+func Healthz(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// readinessChecks runs the readiness checks, returning the outcome of each one and whether all passed
+func readinessChecks(repos *database.Repositories, configFile config.Config) (fiber.Map, bool) {
+	checks := fiber.Map{}
+	ready := true
+	fail := func(name string, err error) {
+		checks[name] = err.Error()
+		ready = false
+	}
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	if err := repos.Health.Ping(pingCtx); err != nil {
+		fail("database", err)
+	} else {
+		checks["database"] = "ok"
+
+		if pending, err := repos.Health.PendingMigrations(); err != nil {
+			fail("migrations", err)
+		} else if len(pending) > 0 {
+			fail("migrations", fmt.Errorf("%d pending migrations", len(pending)))
+		} else {
+			checks["migrations"] = "ok"
+		}
+	}
+
+	if err := config.CheckTaskRoot(configFile); err != nil {
+		fail("task_root", err)
+	} else {
+		checks["task_root"] = "ok"
+	}
+
+	return checks, ready
+}
+
+// Readyz reports whether the application can serve requests: the database answers,
+// every migration is applied and TASKROOT is readable.
+// If any check fails, it returns a "503 Service Unavailable" response with the outcome of each check.
+func Readyz(repos *database.Repositories, configFile config.Config) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		checks, ready := readinessChecks(repos, configFile)
+		if !ready {
+			return ctx.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status": "not ready",
+				"checks": checks,
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "ready",
+			"checks": checks,
+		})
+	}
+}
+
+// dbFileSizes returns the size in bytes of the database file and of its write-ahead log and shared memory files, if any
+func dbFileSizes(configFile config.Config) (fiber.Map, error) {
+	dbFile := config.GetEnvWithFallback(configFile, config.DbFile)
+
+	info, err := os.Stat(dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := fiber.Map{"db": info.Size()}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if info, err := os.Stat(dbFile + suffix); err == nil {
+			sizes["db"+suffix] = info.Size()
+		}
+	}
+
+	return sizes, nil
+}
+
+// GetDiagnostics reports the internal state of the application:
+// readiness checks, connected websocket clients and their topics, in memory aggregations and database file size.
+func GetDiagnostics(repos *database.Repositories, configFile config.Config, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		checks, ready := readinessChecks(repos, configFile)
+
+		clients := cm.ClientsInfo()
+		connected := 0
+		topics := map[string]int{}
+		for _, client := range clients {
+			if client.Connected {
+				connected++
+			}
+			for _, topic := range client.Topics {
+				topics[topic]++
+			}
+		}
+
+		dbInfo := fiber.Map{"stats": repos.Health.Stats()}
+		if version, err := repos.Health.SchemaVersion(); err != nil {
+			dbInfo["schema_version_error"] = err.Error()
+		} else {
+			dbInfo["schema_version"] = version
+		}
+		if sizes, err := dbFileSizes(configFile); err != nil {
+			dbInfo["file_size_error"] = err.Error()
+		} else {
+			dbInfo["file_size"] = sizes
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Diagnostics collected",
+			"data": fiber.Map{
+				"ready":      ready,
+				"checks":     checks,
+				"started_at": startedAt,
+				"uptime":     time.Since(startedAt).Round(time.Second).String(),
+				"goroutines": runtime.NumGoroutine(),
+				"websocket": fiber.Map{
+					"clients":   len(clients),
+					"connected": connected,
+					"topics":    topics,
+					"details":   clients,
+				},
+				"aggregations": fiber.Map{
+					"task_completion_events":   repos.TaskCompletionAggregation.Len(),
+					"escalation_levels_events": repos.EscalationLevelsAggregation.Len(),
+				},
+				"database": dbInfo,
+			},
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"strconv"
+)
+
+// GetTaskHistory retrieves the timeline of every change made to a single task.
+// It reads the task UUID from the URL and returns a "400 Bad Request" error if it is not a valid UUID.
+// If the task has no history, it returns a "404 Not Found" error.
+func GetTaskHistory(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		taskUUID, err := uuid.Parse(ctx.Params("uuid"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: uuid should be a valid UUID")
+		}
+
+		entries, err := repos.History.GetByTaskUUID(taskUUID)
+		if err != nil {
+			log.Errorf("Error getting task history: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get task history",
+				"detail": err.Error(),
+			})
+		}
+
+		if len(entries) == 0 {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No history found for task",
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved task history",
+			"length": len(entries),
+			"data":   entries,
+		})
+	}
+}
+
+// GetEventHistory retrieves the timeline of every change made to the tasks of an event.
+// It reads the central ID and event number from the URL. The history outlives the event,
+// so closed events can still be reviewed.
+// If the event has no history, it returns a "404 Not Found" error.
+func GetEventHistory(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		entries, err := repos.History.GetByCentralAndNumber(eventNumber, centralId)
+		if err != nil {
+			log.Errorf("Error getting event history: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get event history",
+				"detail": err.Error(),
+			})
+		}
+
+		if len(entries) == 0 {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No history found for event",
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved event history",
+			"length": len(entries),
+			"data":   entries,
+		})
+	}
+}
+
+// GetEscalationHistory retrieves every level change of an event, with the operator, the reason
+// and the number of tasks added or removed. It reads the central ID and event number from the URL,
+// the history outlives the event so closed events can still be reviewed.
+// If the event level never changed, it returns an empty list.
+func GetEscalationHistory(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		entries, err := repos.EscalationHistory.GetByCentralAndNumber(eventNumber, centralId)
+		if err != nil {
+			log.Errorf("Error getting escalation history: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get escalation history",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved escalation history",
+			"length": len(entries),
+			"data":   entries,
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"os"
+)
+
+// GetLocalTaskRevisions retrieves the previous revisions of a central local task file, newest first.
+func GetLocalTaskRevisions(repos *database.Repositories, configFile config.Config) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		central, err := repos.Centrals.GetByID(ctx.Params("central_id"))
+		if err != nil {
+			return centralErrorResponse(ctx, err)
+		}
+
+		revisions, err := config.ListLocalTaskRevisions(configFile, localTaskFilename(central))
+		if err != nil {
+			log.Errorf("Error listing local task revisions: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get local task revisions",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved local task revisions",
+			"length": len(revisions),
+			"data":   revisions,
+		})
+	}
+}
+
+// PostLocalTaskRestore restores a previous revision of a central local task file.
+// The revision is validated again before being restored, and the current file is kept as a new revision.
+// If the revision does not exist, it returns a "404 Not Found" error.
+func PostLocalTaskRestore(repos *database.Repositories, configFile config.Config) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		central, err := repos.Centrals.GetByID(ctx.Params("central_id"))
+		if err != nil {
+			return centralErrorResponse(ctx, err)
+		}
+
+		filename := localTaskFilename(central)
+		revision := ctx.Params("revision")
+
+		data, err := config.ReadLocalTaskRevision(configFile, filename, revision)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error":  "Revision not found",
+					"detail": err.Error(),
+				})
+			}
+			log.Errorf("Error reading local task revision: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to read local task revision",
+				"detail": err.Error(),
+			})
+		}
+
+		// Rules may have changed since the revision was uploaded
+		if report, err := validateLocalTasksFile(data); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Invalid tasks file",
+				"detail": err.Error(),
+				"data":   report,
+			})
+		}
+
+		previous, err := config.SaveLocalTaskFile(configFile, filename, data)
+		if err != nil {
+			log.Errorf("Error restoring local task revision: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to restore local task revision",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":   fmt.Sprintf("Revision %s restored for central %s", revision, central.ID),
+			"previous": previous,
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"dogeplus-backend/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute is the route label of requests not matching any route, keeping the label cardinality bounded
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records the count and latency of every request, labelled by method and route pattern.
+// Route patterns (e.g. "/api/v1/active-events/:central_id") are used instead of paths to keep the label cardinality bounded.
+func MetricsMiddleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		err := ctx.Next()
+
+		// Errors returned by the handlers are turned into responses by the error handler only later on
+		status := ctx.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if fiberErr, ok := err.(*fiber.Error); ok {
+				status = fiberErr.Code
+			}
+		}
+
+		route := ctx.Route().Path
+		if status == fiber.StatusNotFound && (route == "/" || route == "/*") && ctx.Path() != "/" {
+			route = unmatchedRoute
+		}
+
+		// Fiber reuses the request buffers, label values outlive the request and must be copied
+		method := utils.CopyString(ctx.Method())
+		route = utils.CopyString(route)
+
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// GetMetrics exposes the application metrics in the Prometheus text format.
+func GetMetrics() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
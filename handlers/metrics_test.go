@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"dogeplus-backend/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMetricsMiddleware tests that requests are counted by route pattern and final status
+func TestMetricsMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Use(MetricsMiddleware())
+	app.Get("/events/:central_id", func(ctx *fiber.Ctx) error {
+		if ctx.Params("central_id") == "bad" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request")
+		}
+		return ctx.SendString("ok")
+	})
+
+	count := func(route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", route, status))
+	}
+	okBefore := count("/events/:central_id", "200")
+	badBefore := count("/events/:central_id", "400")
+	unmatchedBefore := count(unmatchedRoute, "404")
+
+	for _, path := range []string{"/events/SRA", "/events/SRL", "/events/bad", "/unknown/path"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	assert.Equal(t, okBefore+2, count("/events/:central_id", "200"))
+	assert.Equal(t, badBefore+1, count("/events/:central_id", "400"))
+	assert.Equal(t, unmatchedBefore+1, count(unmatchedRoute, "404"))
+}
@@ -0,0 +1,279 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxAttachmentSize is the maximum size of a task attachment, attachments are meant for photos and small documents
+const maxAttachmentSize = 5 * 1024 * 1024
+
+type addNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// isAllowedAttachmentType reports whether a detected content type can be attached to a task (images and PDFs)
+func isAllowedAttachmentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || contentType == "application/pdf"
+}
+
+// taskNotFoundResponse maps task lookup errors to the HTTP response to return
+func taskNotFoundResponse(ctx *fiber.Ctx, err error, message string) error {
+	if _, ok := err.(*database.TaskNotFoundError); ok {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "Task not found",
+			"detail": err.Error(),
+		})
+	}
+
+	log.Errorf("%s: %s\n", message, err)
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":  message,
+		"detail": err.Error(),
+	})
+}
+
+// broadcastToCentral sends a message to the subscribers of the central topic, errors only skip the broadcast
+func broadcastToCentral(cm *broadcast.ConnectionManager, centralId string, message fiber.Map) {
+	messageJson, err := json.Marshal(message)
+	if err != nil {
+		log.Errorf("Failed to marshal %s message to JSON: %v\n", message["type"], err)
+		return
+	}
+
+	cm.BroadcastToTopic("central_"+centralId, messageJson)
+}
+
+// PostTaskNote adds a timestamped free text note to an active event task and broadcasts it on the task central topic.
+// It expects a JSON body with the note text, the author is the authenticated user.
+// If the task does not exist, it returns a "404 Not Found" error.
+func PostTaskNote(repos *database.Repositories, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		taskUUID, err := uuid.Parse(ctx.Params("uuid"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: uuid should be a valid UUID")
+		}
+
+		var body addNoteRequest
+		if err := ctx.BodyParser(&body); err != nil {
+			log.Errorf("Error parsing body: %s\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+
+		if strings.TrimSpace(body.Body) == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: Body field should not be empty")
+		}
+
+		note, err := repos.Notes.AddNote(taskUUID, currentUser(ctx).Username, body.Body)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to add note")
+		}
+
+		responseMap := fiber.Map{
+			"type":    "task_note_added",
+			"message": "Note added",
+			"data":    note,
+		}
+		broadcastToCentral(cm, note.CentralID, responseMap)
+
+		return ctx.Status(fiber.StatusCreated).JSON(responseMap)
+	}
+}
+
+// PostTaskAttachment attaches a file to an active event task and broadcasts it on the task central topic.
+// It expects the "file" multipart form field, the uploader is the authenticated user. Only images and PDFs up to 5 MB are accepted,
+// the content is stored under the configured attachments directory.
+// If the task does not exist, it returns a "404 Not Found" error.
+func PostTaskAttachment(repos *database.Repositories, configFile config.Config, cm *broadcast.ConnectionManager) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		taskUUID, err := uuid.Parse(ctx.Params("uuid"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: uuid should be a valid UUID")
+		}
+
+		uploadedBy := currentUser(ctx).Username
+
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Could not access file: %v", err))
+		}
+
+		if fileHeader.Size > maxAttachmentSize {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Invalid request: attachments should not exceed %d bytes", maxAttachmentSize))
+		}
+
+		task, err := repos.Notes.GetTask(taskUUID)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to get task")
+		}
+
+		// Read file into memory
+		file, err := fileHeader.Open()
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Could not open file: %v", err))
+		}
+		defer file.Close()
+
+		fileBytes, err := io.ReadAll(file)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to read file: %v", err))
+		}
+
+		// Trust the content, not the client supplied content type
+		contentType := http.DetectContentType(fileBytes)
+		if !isAllowedAttachmentType(contentType) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid file type: only images and PDF files are accepted")
+		}
+
+		// Stored name is generated, the original filename is only kept as metadata
+		storedName := filepath.Join(task.CentralID, strconv.Itoa(task.EventNumber),
+			uuid.New().String()+strings.ToLower(filepath.Ext(fileHeader.Filename)))
+		if err := config.SaveAttachmentFile(configFile, storedName, fileBytes); err != nil {
+			log.Errorf("Error saving attachment: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).SendString("Failed to save file")
+		}
+
+		attachment, err := repos.Notes.AddAttachment(database.TaskAttachment{
+			TaskUUID:    task.UUID,
+			EventNumber: task.EventNumber,
+			CentralID:   task.CentralID,
+			UploadedBy:  uploadedBy,
+			Filename:    filepath.Base(fileHeader.Filename),
+			StoredName:  storedName,
+			ContentType: contentType,
+			Size:        int64(len(fileBytes)),
+		})
+		if err != nil {
+			if removeErr := config.RemoveAttachmentFile(configFile, storedName); removeErr != nil {
+				log.Errorf("Error removing orphan attachment: %s\n", removeErr)
+			}
+			log.Errorf("Error adding attachment: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to add attachment",
+				"detail": err.Error(),
+			})
+		}
+
+		responseMap := fiber.Map{
+			"type":    "task_attachment_added",
+			"message": "Attachment added",
+			"data":    attachment,
+		}
+		broadcastToCentral(cm, attachment.CentralID, responseMap)
+
+		return ctx.Status(fiber.StatusCreated).JSON(responseMap)
+	}
+}
+
+// GetTaskNotes retrieves the notes and attachments of a single task, oldest first.
+func GetTaskNotes(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		taskUUID, err := uuid.Parse(ctx.Params("uuid"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: uuid should be a valid UUID")
+		}
+
+		notes, err := repos.Notes.GetNotesByTask(taskUUID)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to get task notes")
+		}
+
+		attachments, err := repos.Notes.GetAttachmentsByTask(taskUUID)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to get task attachments")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":      "Retrieved task notes",
+			"notes":       notes,
+			"attachments": attachments,
+		})
+	}
+}
+
+// GetEventNotes retrieves the notes and attachments of all the tasks of an event, oldest first.
+// It reads the central ID and event number from the URL.
+func GetEventNotes(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		notes, err := repos.Notes.GetNotesByEvent(eventNumber, centralId)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to get event notes")
+		}
+
+		attachments, err := repos.Notes.GetAttachmentsByEvent(eventNumber, centralId)
+		if err != nil {
+			return taskNotFoundResponse(ctx, err, "Failed to get event attachments")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":      "Retrieved event notes",
+			"notes":       notes,
+			"attachments": attachments,
+		})
+	}
+}
+
+// GetAttachmentFile downloads the content of an attachment with its original filename.
+// If the attachment does not exist, it returns a "404 Not Found" error.
+func GetAttachmentFile(repos *database.Repositories, configFile config.Config) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: id should be a positive integer")
+		}
+
+		attachment, err := repos.Notes.GetAttachment(id)
+		if err != nil {
+			if _, ok := err.(*database.AttachmentNotFoundError); ok {
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error":  "Attachment not found",
+					"detail": err.Error(),
+				})
+			}
+			log.Errorf("Error getting attachment: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get attachment",
+				"detail": err.Error(),
+			})
+		}
+
+		path, err := config.AttachmentFilePath(configFile, attachment.StoredName)
+		if err != nil {
+			log.Errorf("Error resolving attachment path: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get attachment",
+				"detail": err.Error(),
+			})
+		}
+
+		ctx.Attachment(attachment.Filename)
+		ctx.Set(fiber.HeaderContentType, attachment.ContentType)
+		return ctx.SendFile(path)
+	}
+}
@@ -1,21 +1,85 @@
 package handlers
 
 import (
+	"dogeplus-backend/auth"
 	"dogeplus-backend/broadcast"
+	"dogeplus-backend/database"
 	"dogeplus-backend/ws"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
-// WsUpgrader is a middleware that upgrades HTTP connections to WebSocket connections.
+// centralTopicPrefix is the prefix of the per central topics, followed by the central id
+const centralTopicPrefix = "central_"
+
+// errTopicNotAllowed is returned by authorizeTopic when the user may not subscribe to the topic
+var errTopicNotAllowed = errors.New("not allowed")
+
+// centralFromTopic returns the central id of a per central topic
+func centralFromTopic(topic string) (string, bool) {
+	centralId, found := strings.CutPrefix(topic, centralTopicPrefix)
+	return centralId, found && centralId != ""
+}
+
+// authorizeTopic checks that a user may subscribe to a topic.
+// Operators may only join the topics of the centrals they belong to, other roles and topics are not restricted.
+// The user is reloaded so membership and role changes apply to open connections too.
+func authorizeTopic(repos *database.Repositories, username, topic string) error {
+	user, err := repos.Users.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+	if !user.Active {
+		return errUserInactive
+	}
+
+	centralId, isCentralTopic := centralFromTopic(topic)
+	if user.Role != database.RoleOperator || !isCentralTopic {
+		return nil
+	}
+
+	belongs, err := repos.Users.BelongsToCentral(username, centralId)
+	if err != nil {
+		return err
+	}
+	if !belongs {
+		return fmt.Errorf("%w: user %s does not belong to central %s", errTopicNotAllowed, username, centralId)
+	}
+
+	return nil
+}
+
+// WsUpgrader is a middleware that authenticates and upgrades HTTP connections to WebSocket connections.
+// Browsers can't set headers on WebSocket requests, so the session token is read from the "token" query parameter,
+// falling back to the Authorization header. Requests without a valid token get a "401 Unauthorized" response.
 // It generates a unique ID for each WebSocket connection by combining the IP address with a UUID.
 // This ensures that each browser tab gets a unique identifier, even if they're in the same browser.
-func WsUpgrader(manager *broadcast.ConnectionManager) fiber.Handler {
+func WsUpgrader(repos *database.Repositories, signer *auth.TokenSigner, manager *broadcast.ConnectionManager) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
+		token := ctx.Query("token")
+		if token == "" {
+			token = bearerToken(ctx)
+		}
+
+		user, err := authenticate(repos, signer, token)
+		if err != nil {
+			if !isAuthenticationFailure(err) {
+				log.Errorf("Error authenticating websocket upgrade: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to authenticate request",
+					"detail": err.Error(),
+				})
+			}
+			return unauthorizedResponse(ctx, err.Error())
+		}
+
 		// Always generate a new unique ID for each connection
 		// This ensures each tab gets a unique identifier, even in the same browser
 		tabUUID := uuid.New().String()
@@ -35,6 +99,7 @@ func WsUpgrader(manager *broadcast.ConnectionManager) fiber.Handler {
 		// Check if is an update request, set the local with unique id and pass to next handler
 		if websocket.IsWebSocketUpgrade(ctx) {
 			ctx.Locals("client_id", clientID)
+			ctx.Locals(userLocalKey, user)
 			return ctx.Next()
 		}
 
@@ -43,11 +108,15 @@ func WsUpgrader(manager *broadcast.ConnectionManager) fiber.Handler {
 }
 
 // WsHandler handles WebSocket connections.
-// It creates a new Service or updates an existing one, adds it to the ConnectionManager,
-// and handles WebSocket messages including heartbeats.
-func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
+// It creates a new Service bound to the authenticated user or updates an existing one, adds it to the ConnectionManager,
+// and handles WebSocket messages including heartbeats. Topic subscriptions are checked with authorizeTopic,
+// a refused subscription is reported in the subscribe_ack message.
+// Reconnecting clients send a resume message per topic to get the messages broadcast while they were away.
+// Replies go through the client send queue with cm.SendTo, so they are ordered after the messages already queued.
+func WsHandler(repos *database.Repositories, cm *broadcast.ConnectionManager) fiber.Handler {
 	return websocket.New(func(c *websocket.Conn) {
 		clientID := c.Locals("client_id").(string)
+		user := c.Locals(userLocalKey).(database.User)
 
 		// Check if client already exists (reconnection case)
 		var wsBroadcaster *ws.Service
@@ -58,13 +127,14 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 			if exists {
 				// Try to cast to Service
 				service, ok := broadcaster.(*ws.Service)
-				if ok {
+				if ok && service.Username == user.Username {
 					wsBroadcaster = service
 					wsBroadcaster.UpdateConnection(c)
 					log.Infof("Client %s reconnected", clientID)
 				} else {
-					// Unexpected type, create new
+					// Unexpected type or another user, create new
 					wsBroadcaster = ws.NewService(clientID)
+					wsBroadcaster.Username = user.Username
 					wsBroadcaster.UpdateConnection(c)
 					cm.AddClient(clientID, wsBroadcaster)
 					defer cm.RemoveClient(clientID)
@@ -73,6 +143,7 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 			} else {
 				// Client exists but couldn't be retrieved, create new
 				wsBroadcaster = ws.NewService(clientID)
+				wsBroadcaster.Username = user.Username
 				wsBroadcaster.UpdateConnection(c)
 				cm.AddClient(clientID, wsBroadcaster)
 				defer cm.RemoveClient(clientID)
@@ -81,6 +152,7 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 		} else {
 			// New client
 			wsBroadcaster = ws.NewService(clientID)
+			wsBroadcaster.Username = user.Username
 			wsBroadcaster.UpdateConnection(c)
 			cm.AddClient(clientID, wsBroadcaster)
 			defer cm.RemoveClient(clientID)
@@ -91,11 +163,13 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 		initialMsg := map[string]interface{}{
 			"type":      "connected",
 			"client_id": clientID,
+			"username":  user.Username,
 			"timestamp": time.Now().Unix(),
+			"epoch":     cm.Epoch(),
 		}
 
 		initialMsgJSON, _ := json.Marshal(initialMsg)
-		_ = wsBroadcaster.Send(initialMsgJSON)
+		_ = cm.SendTo(clientID, initialMsgJSON)
 
 		var (
 			messageType int
@@ -119,7 +193,7 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 			// Handle ping/pong for heartbeat
 			if messageType == websocket.TextMessage {
 				if string(messageData) == "ping" {
-					_ = wsBroadcaster.Send([]byte("pong"))
+					_ = cm.SendTo(clientID, []byte("pong"))
 				} else {
 					// Try to parse as JSON to see if it's a structured message
 					var msg map[string]interface{}
@@ -134,11 +208,14 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 									"timestamp": time.Now().Unix(),
 								}
 								heartbeatJSON, _ := json.Marshal(heartbeatResponse)
-								_ = wsBroadcaster.Send(heartbeatJSON)
+								_ = cm.SendTo(clientID, heartbeatJSON)
 							case "subscribe":
 								// Handle topic subscription
 								if topic, ok := msg["topic"].(string); ok && topic != "" {
-									err := wsBroadcaster.Subscribe(topic)
+									err := authorizeTopic(repos, user.Username, topic)
+									if err == nil {
+										err = wsBroadcaster.Subscribe(topic)
+									}
 									response := map[string]interface{}{
 										"type":    "subscribe_ack",
 										"topic":   topic,
@@ -148,8 +225,45 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 										response["error"] = err.Error()
 									}
 									responseJSON, _ := json.Marshal(response)
-									_ = wsBroadcaster.Send(responseJSON)
-									log.Infof("Client %s subscribed to topic: %s", clientID, topic)
+									_ = cm.SendTo(clientID, responseJSON)
+									if err != nil {
+										log.Warnf("Client %s (%s) refused subscription to topic %s: %v", clientID, user.Username, topic, err)
+									} else {
+										log.Infof("Client %s subscribed to topic: %s", clientID, topic)
+									}
+								}
+							case "resume":
+								// Handle stream resumption, subscribes to the topic and replays the missed messages
+								if topic, ok := msg["topic"].(string); ok && topic != "" {
+									lastSeq, _ := msg["last_seq"].(float64)
+									epoch, _ := msg["epoch"].(float64)
+
+									var result broadcast.ResumeResult
+									err := authorizeTopic(repos, user.Username, topic)
+									if err == nil && lastSeq < 0 {
+										err = fmt.Errorf("invalid last_seq %v", lastSeq)
+									}
+									if err == nil {
+										result, err = cm.Resume(clientID, topic, uint64(lastSeq), int64(epoch))
+									}
+									response := map[string]interface{}{
+										"type":     "resume_ack",
+										"topic":    topic,
+										"success":  err == nil,
+										"replayed": result.Replayed,
+										"last_seq": result.LastSeq,
+										"refetch":  result.Refetch,
+									}
+									if err != nil {
+										response["error"] = err.Error()
+									}
+									responseJSON, _ := json.Marshal(response)
+									_ = cm.SendTo(clientID, responseJSON)
+									if err != nil {
+										log.Warnf("Client %s (%s) failed to resume topic %s: %v", clientID, user.Username, topic, err)
+									} else {
+										log.Infof("Client %s resumed topic %s from seq %d: %d replayed, refetch %t", clientID, topic, uint64(lastSeq), result.Replayed, result.Refetch)
+									}
 								}
 							case "unsubscribe":
 								// Handle topic unsubscription
@@ -164,7 +278,7 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 										response["error"] = err.Error()
 									}
 									responseJSON, _ := json.Marshal(response)
-									_ = wsBroadcaster.Send(responseJSON)
+									_ = cm.SendTo(clientID, responseJSON)
 									log.Infof("Client %s unsubscribed from topic: %s", clientID, topic)
 								}
 							case "get_topics":
@@ -175,7 +289,7 @@ func WsHandler(cm *broadcast.ConnectionManager) fiber.Handler {
 									"topics": topics,
 								}
 								responseJSON, _ := json.Marshal(response)
-								_ = wsBroadcaster.Send(responseJSON)
+								_ = cm.SendTo(clientID, responseJSON)
 							}
 						}
 					}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"strconv"
+)
+
+// bumpEventRevision increments the revision of an event after a committed change and returns it,
+// to be sent along with the change broadcast. Failures are logged and reported as revision 0,
+// clients then refetch the snapshot.
+func bumpEventRevision(repos *database.Repositories, centralId string, eventNumber int) int64 {
+	revision, err := repos.Revisions.Bump(centralId, eventNumber)
+	if err != nil {
+		log.Errorf("Error bumping revision of event %s/%d: %s\n", centralId, eventNumber, err)
+		return 0
+	}
+
+	return revision
+}
+
+// GetEventSnapshot returns the whole state of an open event: overview, tasks grouped by role and category,
+// completion, current escalation level and revision.
+// Websocket updates of the event carry a revision too, those not newer than the snapshot revision are already included.
+// If the event does not exist, it returns a "404 Not Found" error.
+func GetEventSnapshot(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		centralId := ctx.Params("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: CentralId field should not be empty")
+		}
+
+		eventNumber, err := strconv.Atoi(ctx.Params("event_nr"))
+		if err != nil || eventNumber == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: eventNumber should be a non zero integer")
+		}
+
+		snapshot, err := repos.Revisions.GetSnapshot(centralId, eventNumber)
+		if err != nil {
+			if _, ok := err.(*database.NoEventsFoundError); ok {
+				return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error":  "Event not found",
+					"detail": err.Error(),
+				})
+			}
+			log.Errorf("Error getting event snapshot: %s\n", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":  "Failed to get event snapshot",
+				"detail": err.Error(),
+			})
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved event snapshot",
+			"data":   snapshot,
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bufio"
+	"dogeplus-backend/auth"
+	"dogeplus-backend/broadcast"
+	"dogeplus-backend/database"
+	"dogeplus-backend/sse"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// streamTopics parses the comma separated topics query parameter, dropping blanks and duplicates
+func streamTopics(query string) []string {
+	topics := []string{}
+	seen := make(map[string]bool)
+	for _, topic := range strings.Split(query, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic == "" || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+
+	return topics
+}
+
+// StreamHandler serves the topics listed in the "topics" query parameter as a server-sent event stream,
+// for read only clients that can't open a websocket. Like WsUpgrader, the session token is read from the
+// "token" query parameter, falling back to the Authorization header, and every topic is checked with authorizeTopic.
+// The stream is registered with the ConnectionManager as a sse.Service, so it gets topic broadcasts and heartbeats
+// like websocket clients, and lasts until the client goes away or the server shuts down.
+func StreamHandler(repos *database.Repositories, signer *auth.TokenSigner, cm *broadcast.ConnectionManager) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		token := ctx.Query("token")
+		if token == "" {
+			token = bearerToken(ctx)
+		}
+
+		user, err := authenticate(repos, signer, token)
+		if err != nil {
+			if !isAuthenticationFailure(err) {
+				log.Errorf("Error authenticating event stream: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to authenticate request",
+					"detail": err.Error(),
+				})
+			}
+			return unauthorizedResponse(ctx, err.Error())
+		}
+
+		topics := streamTopics(ctx.Query("topics"))
+		if len(topics) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: topics should list at least one topic")
+		}
+
+		for _, topic := range topics {
+			if err := authorizeTopic(repos, user.Username, topic); err != nil {
+				if errors.Is(err, errTopicNotAllowed) || errors.Is(err, errUserInactive) {
+					return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+						"error":  "Forbidden",
+						"detail": err.Error(),
+					})
+				}
+				log.Errorf("Error authorizing event stream topic %s: %s\n", topic, err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to authorize topic",
+					"detail": err.Error(),
+				})
+			}
+		}
+
+		clientID := ctx.IP() + "-" + uuid.New().String()
+
+		ctx.Set(fiber.HeaderContentType, "text/event-stream")
+		ctx.Set(fiber.HeaderCacheControl, "no-cache")
+		ctx.Set(fiber.HeaderConnection, "keep-alive")
+		// Keep reverse proxies from buffering the events
+		ctx.Set("X-Accel-Buffering", "no")
+
+		// The writer runs after the handler returns, on its own goroutine, ctx must not be used there
+		ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			stream := sse.NewService(clientID, w)
+			stream.Username = user.Username
+			for _, topic := range topics {
+				_ = stream.Subscribe(topic)
+			}
+
+			cm.AddClient(clientID, stream)
+			defer cm.RemoveClient(clientID)
+			log.Infof("Client %s (%s) opened event stream for topics %v", clientID, user.Username, topics)
+
+			// Send initial connection confirmation, same as the websocket one
+			initialMsg := map[string]interface{}{
+				"type":      "connected",
+				"client_id": clientID,
+				"username":  user.Username,
+				"timestamp": time.Now().Unix(),
+				"epoch":     cm.Epoch(),
+				"topics":    topics,
+			}
+			initialMsgJSON, _ := json.Marshal(initialMsg)
+			if err := cm.SendTo(clientID, initialMsgJSON); err != nil {
+				log.Infof("Error sending to event stream client %s: %v", clientID, err)
+				return
+			}
+
+			<-stream.Done()
+			log.Infof("Client %s event stream closed", clientID)
+		})
+
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+// Package handlers provides HTTP request handlers for the DogePlus Backend API.
+// It contains functions that process incoming HTTP requests, interact with the database
+// repositories, and return appropriate HTTP responses. The handlers are organized by
+// functionality, with separate files for different aspects of the application.
+package handlers
+
+import (
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"strconv"
+)
+
+// templateVersionErrorResponse maps template version errors to the HTTP response to return
+func templateVersionErrorResponse(ctx *fiber.Ctx, err error, message string) error {
+	if _, ok := err.(*database.TemplateVersionNotFoundError); ok {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":  "Template version not found",
+			"detail": err.Error(),
+		})
+	}
+
+	log.Errorf("%s: %s\n", message, err)
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":  message,
+		"detail": err.Error(),
+	})
+}
+
+// GetTemplateVersions retrieves all the main task template versions, newest first.
+func GetTemplateVersions(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		versions, err := repos.Tasks.GetTemplateVersions()
+		if err != nil {
+			return templateVersionErrorResponse(ctx, err, "Failed to get template versions")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Retrieved template versions",
+			"length": len(versions),
+			"data":   versions,
+		})
+	}
+}
+
+// GetTemplateVersion retrieves a template version and its tasks.
+// If the version does not exist, it returns a "404 Not Found" error.
+func GetTemplateVersion(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		version, err := strconv.Atoi(ctx.Params("version"))
+		if err != nil || version <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: version should be a positive integer")
+		}
+
+		templateVersion, err := repos.Tasks.GetTemplateVersion(version)
+		if err != nil {
+			return templateVersionErrorResponse(ctx, err, "Failed to get template version")
+		}
+
+		tasks, err := repos.Tasks.GetTemplateVersionTasks(version)
+		if err != nil {
+			return templateVersionErrorResponse(ctx, err, "Failed to get template version tasks")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result":  "Template version found",
+			"version": templateVersion,
+			"length":  len(tasks),
+			"data":    tasks,
+		})
+	}
+}
+
+// GetTemplateDiff compares two template versions task by task.
+// It expects the "from" and "to" versions as query parameters.
+// If any of the versions does not exist, it returns a "404 Not Found" error.
+func GetTemplateDiff(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		from, err := strconv.Atoi(ctx.Query("from"))
+		if err != nil || from <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: from should be a positive integer")
+		}
+
+		to, err := strconv.Atoi(ctx.Query("to"))
+		if err != nil || to <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: to should be a positive integer")
+		}
+
+		diff, err := repos.Tasks.DiffTemplateVersions(from, to)
+		if err != nil {
+			return templateVersionErrorResponse(ctx, err, "Failed to diff template versions")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Template versions compared",
+			"data":   diff,
+		})
+	}
+}
+
+// PostTemplateRollback restores the tasks of an earlier template version as a new version.
+// The rollback is recorded as made by the authenticated user.
+// If the version does not exist, it returns a "404 Not Found" error.
+func PostTemplateRollback(repos *database.Repositories) func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		version, err := strconv.Atoi(ctx.Params("version"))
+		if err != nil || version <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: version should be a positive integer")
+		}
+
+		restored, err := repos.Tasks.RollbackTemplate(version, currentUser(ctx).Username)
+		if err != nil {
+			return templateVersionErrorResponse(ctx, err, "Failed to rollback template version")
+		}
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Template version restored",
+			"data":   restored,
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"dogeplus-backend/database"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/xuri/excelize/v2"
 	"io"
 	"path/filepath"
@@ -19,6 +20,7 @@ type EscalationRequest struct {
 }
 
 // UploadMainTasksFile handles the upload of an .xlsx file containing main tasks, resets the tasks table, and adds new tasks.
+// Every upload is stored as a new task template version, recording the authenticated user as the uploader.
 func UploadMainTasksFile(repos *database.Repositories) func(ctx *fiber.Ctx) error {
 	return func(ctx *fiber.Ctx) error {
 		// Access the file:
@@ -57,7 +59,10 @@ func UploadMainTasksFile(repos *database.Repositories) func(ctx *fiber.Ctx) erro
 			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to parse xlsx file to tasks: %v", err))
 		}
 
+		uploadedBy := currentUser(ctx).Username
+
 		// Use a transaction to ensure atomicity
+		var templateVersion database.TaskTemplateVersion
 		if err := repos.Tasks.WithTransaction(func(tx *database.TaskRepositoryTransaction) error {
 			// Drop the existing tasks table
 			if err := tx.DropTasksTable(); err != nil {
@@ -69,39 +74,156 @@ func UploadMainTasksFile(repos *database.Repositories) func(ctx *fiber.Ctx) erro
 				return fmt.Errorf("failed to add tasks to database: %v", err)
 			}
 
+			// Keep the upload as a new template version
+			var err error
+			templateVersion, err = tx.SaveTemplateVersion(tasks, uploadedBy, fileHeader.Filename, database.TemplateChecksum(fileBytes), 0)
+			if err != nil {
+				return fmt.Errorf("failed to save template version: %v", err)
+			}
+
 			return nil
 		}); err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
 
 		// Respond to the request
-		return ctx.SendString("File processed and tasks table reset successfully.")
+		return ctx.SendString(fmt.Sprintf("File processed and tasks table reset successfully. Template version %d.", templateVersion.Version))
 	}
 }
 
-// UploadLocalTasksFile handles the upload of a local tasks file via multipart form and saves it to a specified directory.
-func UploadLocalTasksFile(configFile config.Config) func(ctx *fiber.Ctx) error {
+// UploadLocalTasksFile handles the upload of a central local tasks file via multipart form.
+// It expects the "file" and "central_id" form fields. The file is parsed and validated before being saved,
+// an invalid file is rejected with the row level validation report.
+// The file is saved as the central task file, the previous one is kept as a timestamped revision.
+func UploadLocalTasksFile(repos *database.Repositories, configFile config.Config) func(ctx *fiber.Ctx) error {
 	return func(ctx *fiber.Ctx) error {
 		// Retrieve file from the multipart form
-		file, err := ctx.FormFile("file")
+		fileHeader, err := ctx.FormFile("file")
 		if err != nil {
 			return ctx.Status(fiber.StatusBadRequest).SendString("Failed to retrieve file")
 		}
 
-		// Get the filename and the extension
-		filename := file.Filename
+		// Ensure the uploaded file is an xlsx file
+		if filepath.Ext(fileHeader.Filename) != ".xlsx" {
+			return ctx.Status(fiber.StatusBadRequest).SendString("Invalid file type: only .xlsx files are accepted")
+		}
+
+		centralId := ctx.FormValue("central_id")
+		if centralId == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request: central_id field should not be empty")
+		}
+
+		central, err := repos.Centrals.GetByID(centralId)
+		if err != nil {
+			return centralErrorResponse(ctx, err)
+		}
+
+		// Read file into memory
+		file, err := fileHeader.Open()
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Could not open file: %v", err))
+		}
+		defer file.Close()
+
+		fileBytes, err := io.ReadAll(file)
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to read file: %v", err))
+		}
+
+		// Reject invalid files with the validation report
+		if report, err := validateLocalTasksFile(fileBytes); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Invalid tasks file",
+				"detail": err.Error(),
+				"data":   report,
+			})
+		}
 
-		// Define the path to save the file
-		fileRepo := config.GetEnvWithFallback(configFile, config.TaskRoot)
-		savePath := filepath.Join(fileRepo, filename) // Saves the file in the current directory
+		// Centrals without a configured task file get the default one, named after the central
+		if central.TaskFile == "" {
+			central.TaskFile = localTaskFilename(central)
+			if err := repos.Centrals.Update(central); err != nil {
+				log.Errorf("Error updating central task file: %s\n", err)
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":  "Failed to update central task file",
+					"detail": err.Error(),
+				})
+			}
+		}
 
-		// Save the file to the defined path
-		if err := ctx.SaveFile(file, savePath); err != nil {
+		revision, err := config.SaveLocalTaskFile(configFile, central.TaskFile, fileBytes)
+		if err != nil {
+			log.Errorf("Error saving local task file: %s\n", err)
 			return ctx.Status(fiber.StatusInternalServerError).SendString("Failed to save file")
 		}
 
 		// Return success response
-		return ctx.SendString(fmt.Sprintf("File %s uploaded successfully", filename))
+		message := fmt.Sprintf("File %s uploaded successfully for central %s", central.TaskFile, central.ID)
+		if revision != "" {
+			message += fmt.Sprintf(", previous file kept as revision %s", revision)
+		}
+		return ctx.SendString(message)
+	}
+}
+
+// validateLocalTasksFile parses and validates a local tasks file.
+// It returns an error if the file can't be parsed or the validation report contains errors.
+func validateLocalTasksFile(data []byte) (database.TaskValidationReport, error) {
+	excelFile, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return database.TaskValidationReport{}, fmt.Errorf("failed to open xlsx file: %v", err)
+	}
+	defer excelFile.Close()
+
+	report := database.ValidateLocalXLSXTasks(excelFile)
+
+	if _, err := database.ParseXLSXToTasks(excelFile); err != nil {
+		return report, fmt.Errorf("failed to parse xlsx file to tasks: %v", err)
+	}
+
+	if !report.Valid {
+		return report, fmt.Errorf("%d errors found", report.Errors)
+	}
+
+	return report, nil
+}
+
+// ValidateTasksFile handles the dry-run validation of an .xlsx tasks file.
+// It parses the workbook and returns a per sheet, per row and per column report of errors and warnings,
+// nothing is written to the database.
+func ValidateTasksFile() func(ctx *fiber.Ctx) error {
+	return func(ctx *fiber.Ctx) error {
+		// Access the file:
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Could not access file: %v", err))
+		}
+
+		// Ensure the uploaded file is an xlsx file
+		if filepath.Ext(fileHeader.Filename) != ".xlsx" {
+			return ctx.Status(fiber.StatusBadRequest).SendString("Invalid file type: only .xlsx files are accepted")
+		}
+
+		// Open the file
+		file, err := fileHeader.Open()
+		if err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Could not open file: %v", err))
+		}
+		defer file.Close()
+
+		// Open the file with excelize
+		excelFile, err := excelize.OpenReader(file)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to open xlsx file: %v", err))
+		}
+		defer excelFile.Close()
+
+		report := database.ValidateXLSXTasks(excelFile)
+
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"result": "Validation completed",
+			"data":   report,
+		})
 	}
 }
 
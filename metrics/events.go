@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TaskCount is the number of active event tasks of a central in a status
+type TaskCount struct {
+	CentralID string
+	Status    string
+	Count     int
+}
+
+// EscalationCount is the number of open events of a central at an escalation level
+type EscalationCount struct {
+	CentralID string
+	Level     string
+	Count     int
+}
+
+// EventSnapshot is the business state reported at every scrape
+type EventSnapshot struct {
+	OpenEvents       map[string]int // central id -> open events
+	Tasks            []TaskCount
+	EscalationLevels []EscalationCount
+}
+
+// eventCollector reports an EventSnapshot taken at scrape time
+type eventCollector struct {
+	snapshot         func() (EventSnapshot, error)
+	up               *prometheus.Desc
+	openEvents       *prometheus.Desc
+	tasks            *prometheus.Desc
+	escalationLevels *prometheus.Desc
+}
+
+// RegisterEventCollector registers the collector of the open events per central, the tasks by status
+// and the events per escalation level. The snapshot function is called at every scrape,
+// when it fails only dogeplus_event_metrics_up is reported, set to 0.
+// It must be registered once at startup.
+func RegisterEventCollector(snapshot func() (EventSnapshot, error)) error {
+	return Registry.Register(&eventCollector{
+		snapshot: snapshot,
+		up: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "event_metrics_up"),
+			"Whether the event metrics could be read from the database.", nil, nil),
+		openEvents: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "open_events"),
+			"Open events by central.", []string{"central"}, nil),
+		tasks: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "event_tasks"),
+			"Active event tasks by central and status.", []string{"central", "status"}, nil),
+		escalationLevels: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "events_by_escalation_level"),
+			"Open events by central and escalation level.", []string{"central", "level"}, nil),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *eventCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.openEvents
+	ch <- c.tasks
+	ch <- c.escalationLevels
+}
+
+// Collect implements prometheus.Collector
+func (c *eventCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot, err := c.snapshot()
+	if err != nil {
+		log.Errorf("Error collecting event metrics: %v", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	for centralId, count := range snapshot.OpenEvents {
+		ch <- prometheus.MustNewConstMetric(c.openEvents, prometheus.GaugeValue, float64(count), centralId)
+	}
+	for _, task := range snapshot.Tasks {
+		ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(task.Count), task.CentralID, task.Status)
+	}
+	for _, level := range snapshot.EscalationLevels {
+		ch <- prometheus.MustNewConstMetric(c.escalationLevels, prometheus.GaugeValue, float64(level.Count), level.CentralID, level.Level)
+	}
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+// newTestEventCollector registers an eventCollector on a fresh registry, so tests don't depend on the global one
+func newTestEventCollector(t *testing.T, snapshot func() (EventSnapshot, error)) *prometheus.Registry {
+	t.Helper()
+
+	previous := Registry
+	Registry = prometheus.NewRegistry()
+	t.Cleanup(func() { Registry = previous })
+
+	if err := RegisterEventCollector(snapshot); err != nil {
+		t.Fatalf("failed to register event collector: %v", err)
+	}
+
+	return Registry
+}
+
+func TestEventCollector(t *testing.T) {
+	registry := newTestEventCollector(t, func() (EventSnapshot, error) {
+		return EventSnapshot{
+			OpenEvents:       map[string]int{"SRA": 2},
+			Tasks:            []TaskCount{{CentralID: "SRA", Status: "done", Count: 1}, {CentralID: "SRA", Status: "notdone", Count: 3}},
+			EscalationLevels: []EscalationCount{{CentralID: "SRA", Level: "incidente", Count: 2}},
+		}, nil
+	})
+
+	expected := `
+# HELP dogeplus_event_metrics_up Whether the event metrics could be read from the database.
+# TYPE dogeplus_event_metrics_up gauge
+dogeplus_event_metrics_up 1
+# HELP dogeplus_event_tasks Active event tasks by central and status.
+# TYPE dogeplus_event_tasks gauge
+dogeplus_event_tasks{central="SRA",status="done"} 1
+dogeplus_event_tasks{central="SRA",status="notdone"} 3
+# HELP dogeplus_events_by_escalation_level Open events by central and escalation level.
+# TYPE dogeplus_events_by_escalation_level gauge
+dogeplus_events_by_escalation_level{central="SRA",level="incidente"} 2
+# HELP dogeplus_open_events Open events by central.
+# TYPE dogeplus_open_events gauge
+dogeplus_open_events{central="SRA"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventCollector_SnapshotError(t *testing.T) {
+	registry := newTestEventCollector(t, func() (EventSnapshot, error) {
+		return EventSnapshot{}, errors.New("database is locked")
+	})
+
+	expected := `
+# HELP dogeplus_event_metrics_up Whether the event metrics could be read from the database.
+# TYPE dogeplus_event_metrics_up gauge
+dogeplus_event_metrics_up 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
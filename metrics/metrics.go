@@ -0,0 +1,106 @@
+// Package metrics provides the Prometheus metrics exposed on /metrics.
+// It holds the registry and the collectors updated by the HTTP middleware and the broadcast package,
+// plus the collectors reading the business state (events, tasks and escalation levels) at scrape time.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric name
+const namespace = "dogeplus"
+
+// BroadcastAllTopic is the topic label used for messages sent to every client regardless of their topics
+const BroadcastAllTopic = "_all"
+
+// Registry holds every application metric, together with the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// HTTPRequests counts the handled HTTP requests by method, route pattern and status code
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Handled HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes the HTTP request latencies by method and route pattern
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// BroadcastMessages counts the messages broadcast by topic
+	BroadcastMessages = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broadcast_messages_total",
+		Help:      "Messages broadcast by topic.",
+	}, []string{"topic"})
+
+	// BroadcastDeliveries counts the messages delivered to a client by topic, the broadcast fan-out
+	BroadcastDeliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broadcast_deliveries_total",
+		Help:      "Broadcast messages delivered to a client by topic.",
+	}, []string{"topic"})
+
+	// BroadcastSendErrors counts the failed deliveries to a client by topic
+	BroadcastSendErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broadcast_send_errors_total",
+		Help:      "Broadcast messages that failed to be delivered to a client by topic.",
+	}, []string{"topic"})
+
+	// SendQueueDropped counts the messages dropped from full client send queues
+	SendQueueDropped = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_queue_dropped_total",
+		Help:      "Messages dropped from full client send queues.",
+	})
+
+	// SlowClientDisconnects counts the clients disconnected because their send queue was full
+	SlowClientDisconnects = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slow_client_disconnects_total",
+		Help:      "Clients disconnected because their send queue was full.",
+	})
+
+	// HeartbeatsSent counts the heartbeats delivered to websocket clients
+	HeartbeatsSent = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_heartbeats_sent_total",
+		Help:      "Heartbeats delivered to websocket clients.",
+	})
+
+	// HeartbeatSendErrors counts the heartbeats that failed to be delivered
+	HeartbeatSendErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_heartbeat_send_errors_total",
+		Help:      "Heartbeats that failed to be delivered to websocket clients.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterWebsocketConnections registers the gauge reporting the connected websocket clients.
+// The count is read at scrape time, it must be registered once at startup.
+func RegisterWebsocketConnections(connected func() int) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Connected websocket clients.",
+	}, func() float64 {
+		return float64(connected())
+	}))
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"dogeplus-backend/handlers"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -11,12 +12,15 @@ import (
 func NewFiberApp() *fiber.App {
 	app := fiber.New(fiber.Config{
 		AppName:           "DogePlus Backend",
-		BodyLimit:         1 * 1024 * 1024,
+		BodyLimit:         6 * 1024 * 1024, // room for task attachments, up to 5 MB each
 		ReadTimeout:       2 * time.Second,
 		WriteTimeout:      2 * time.Second,
 		EnablePrintRoutes: true,
 	})
 
+	// Record request count and latency per route
+	app.Use(handlers.MetricsMiddleware())
+
 	// Enable logging
 	app.Use(logger.New())
 
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"dogeplus-backend/auth"
 	"dogeplus-backend/broadcast"
 	"dogeplus-backend/config"
 	"dogeplus-backend/database"
@@ -14,9 +15,20 @@ import (
 // - config: a config.Config struct with env variables
 // - repos: a pointer to a database.Repositories instance representing the collection of repositories
 // - cm: a pointer to a broadcast.ConnectionManager instance representing the connection manager
-func SetupRoutes(app *fiber.App, config config.Config, repos *database.Repositories, cm *broadcast.ConnectionManager) {
+// - signer: a pointer to an auth.TokenSigner used to issue and verify session tokens
+// - reconciler: a pointer to the database.Reconciler of the in memory aggregations
+//
+// Every api route but login requires an authenticated user, role restricted routes are wrapped in handlers.RequireRoles.
+func SetupRoutes(app *fiber.App, config config.Config, repos *database.Repositories, cm *broadcast.ConnectionManager, signer *auth.TokenSigner, reconciler *database.Reconciler) {
 	app.Get("/", handlers.HomeHandler)
 
+	// Liveness and readiness probes, left unauthenticated for the orchestrator
+	app.Get("/healthz", handlers.Healthz)
+	app.Get("/readyz", handlers.Readyz(repos, config))
+
+	// Prometheus metrics, left unauthenticated for the scraper
+	app.Get("/metrics", handlers.GetMetrics())
+
 	// Serve the SolidJS app from a specific directory
 	app.Static("/app", "./frontend/dist")
 
@@ -30,41 +42,122 @@ func SetupRoutes(app *fiber.App, config config.Config, repos *database.Repositor
 	})
 	v1.Get("/", handlers.VersionLandingHandler("version 1"))
 
+	// Authentication and role middlewares
+	authenticated := handlers.RequireAuth(repos, signer)
+	supervisor := handlers.RequireRoles(database.RoleSupervisor)
+	procedureAdmin := handlers.RequireRoles(database.RoleProcedureAdmin)
+
+	// Authentication routes
+	authentication := v1.Group("/auth")
+	authentication.Post("/login", handlers.Login(repos, signer))
+	authentication.Get("/me", authenticated, handlers.GetCurrentUser)
+	authentication.Put("/password", authenticated, handlers.PutOwnPassword(repos))
+
+	// Users management routes
+	users := v1.Group("/users", authenticated, procedureAdmin)
+	users.Get("/", handlers.GetAllUsers(repos))
+	users.Post("/", handlers.PostUser(repos))
+	users.Put("/:username", handlers.PutUser(repos))
+
+	// Diagnostics routes
+	v1.Get("/diagnostics", authenticated, procedureAdmin, handlers.GetDiagnostics(repos, config, cm))
+
 	// Tasks routes
-	tasks := v1.Group("/tasks")
+	tasks := v1.Group("/tasks", authenticated)
 	tasks.Get("/", handlers.GetTasks(config, repos))
 	tasks.Post("/", handlers.GetTasksForEscalation(repos))
-	tasks.Post("/upload/main", handlers.UploadMainTasksFile(repos))
-	tasks.Post("/upload/local", handlers.UploadLocalTasksFile(config))
+	tasks.Get("/templates", handlers.GetTemplateVersions(repos))
+	tasks.Get("/templates/diff", handlers.GetTemplateDiff(repos))
+	tasks.Get("/templates/:version", handlers.GetTemplateVersion(repos))
+	tasks.Post("/templates/:version/rollback", procedureAdmin, handlers.PostTemplateRollback(repos))
+
+	// Task files maintenance routes
+	upload := tasks.Group("/upload", procedureAdmin)
+	upload.Post("/main", handlers.UploadMainTasksFile(repos))
+	upload.Post("/local", handlers.UploadLocalTasksFile(repos, config))
+	upload.Post("/validate", handlers.ValidateTasksFile())
+	localTasks := tasks.Group("/local", procedureAdmin)
+	localTasks.Get("/:central_id/revisions", handlers.GetLocalTaskRevisions(repos, config))
+	localTasks.Post("/:central_id/revisions/:revision/restore", handlers.PostLocalTaskRestore(repos, config))
 
 	// ActiveEvents routes
-	activeEvents := v1.Group("/active-events")
+	activeEvents := v1.Group("/active-events", authenticated)
 	activeEvents.Post("/", handlers.CreateNewEvent(repos, config))
+	activeEvents.Post("/preview", handlers.PreviewNewEvent(repos, config))
 	activeEvents.Post("/overview", handlers.PostNewOverview(repos, cm))
 	activeEvents.Put("/", handlers.UpdateEventTask(repos, cm))
 	activeEvents.Get("/:central_id", handlers.GetSingleEvent(repos))
 	activeEvents.Get("/:central_id/:event_nr", handlers.GetSpecificEvent(repos))
+	activeEvents.Post("/:central_id/:event_nr/close", supervisor, handlers.CloseEvent(repos, cm))
+	activeEvents.Post("/:central_id/:event_nr/merge", supervisor, handlers.MergeEvent(repos, cm))
+	activeEvents.Post("/:central_id/:event_nr/transfer", supervisor, handlers.TransferEvent(repos, cm))
 	//activeEvents.Get("/aggregated_status", )
 
+	// Event snapshot routes
+	events := v1.Group("/events", authenticated)
+	events.Get("/:central_id/:event_nr/snapshot", handlers.GetEventSnapshot(repos))
+
+	// Active event task assignment routes
+	assignments := v1.Group("/assignments", authenticated)
+	assignments.Put("/", supervisor, handlers.AssignEventTask(repos, cm))
+	assignments.Put("/claim", handlers.ClaimEventTask(repos, cm))
+	assignments.Get("/:operator", handlers.GetOperatorOpenTasks(repos))
+
+	// Active event task notes and attachments routes
+	notes := v1.Group("/notes", authenticated)
+	notes.Get("/task/:uuid", handlers.GetTaskNotes(repos))
+	notes.Post("/task/:uuid", handlers.PostTaskNote(repos, cm))
+	notes.Post("/task/:uuid/attachments", handlers.PostTaskAttachment(repos, config, cm))
+	notes.Get("/event/:central_id/:event_nr", handlers.GetEventNotes(repos))
+	notes.Get("/attachments/:id", handlers.GetAttachmentFile(repos, config))
+
+	// Archived (closed) events routes
+	archive := v1.Group("/archive", authenticated)
+	archive.Get("/:central_id", handlers.GetArchivedEvents(repos))
+	archive.Get("/:central_id/:event_nr", handlers.GetArchivedEvent(repos))
+
+	// Task status history routes
+	history := v1.Group("/history", authenticated)
+	history.Get("/task/:uuid", handlers.GetTaskHistory(repos))
+	history.Get("/event/:central_id/:event_nr", handlers.GetEventHistory(repos))
+	history.Get("/escalations/:central_id/:event_nr", handlers.GetEscalationHistory(repos))
+
 	// Event aggregation routes
-	completionAggregation := v1.Group("/completion_aggregation")
+	completionAggregation := v1.Group("/completion_aggregation", authenticated)
 	completionAggregation.Get("/", handlers.GetAllTaskCompletionInfo(cm))
 	completionAggregation.Get("/:event_number", handlers.GetTaskCompletionInfoForKey(cm))
 
+	// Aggregations maintenance routes
+	v1.Post("/aggregations/reconcile", authenticated, procedureAdmin, handlers.PostReconcileAggregations(reconciler))
+
 	// Event Escalation routes
-	aggregationEscalation := v1.Group("/escalation_aggregation")
+	aggregationEscalation := v1.Group("/escalation_aggregation", authenticated)
 	aggregationEscalation.Get("/", handlers.GetAllEscalationLevels)
 	aggregationEscalation.Get("/details", handlers.GetAllEscalationDetails(repos))
 	aggregationEscalation.Get("/details/:central_id", handlers.GetEscalationDetailsByCentralId(repos))
 	aggregationEscalation.Get("/details/:central_id/:event_number", handlers.GetEscalationDetailsByCentralIdAndEventNumber(repos))
 	aggregationEscalation.Post("/escalate", handlers.PostEscalate(repos, config, cm))
-	aggregationEscalation.Post("/deescalate", handlers.PostDeEscalate(repos, config, cm))
+	aggregationEscalation.Post("/deescalate", supervisor, handlers.PostDeEscalate(repos, config, cm))
+	aggregationEscalation.Post("/incident_level", handlers.PostIncidentLevel(repos, config, cm))
+
+	// Operations centrals routes
+	centrals := v1.Group("/centrals", authenticated)
+	centrals.Get("/", handlers.GetAllCentrals(repos))
+	centrals.Get("/:id", handlers.GetCentral(repos))
+	centrals.Post("/", procedureAdmin, handlers.PostCentral(repos))
+	centrals.Put("/:id", procedureAdmin, handlers.PutCentral(repos))
+	centrals.Delete("/:id", procedureAdmin, handlers.DeleteCentral(repos))
 
 	// Escalation Levels Definitions
-	escalationLevels := v1.Group("/escalation_levels")
+	escalationLevels := v1.Group("/escalation_levels", authenticated)
 	escalationLevels.Get("/", handlers.GetAllEscalationLevelsDefinitions(repos))
+	escalationLevels.Get("/model", handlers.GetLevelModel())
+	escalationLevels.Post("/", procedureAdmin, handlers.PostEscalationLevelDefinition(repos))
+
+	// Server-sent events stream, authenticated by the handler as EventSource can't set headers
+	v1.Get("/stream", handlers.StreamHandler(repos, signer, cm))
 
 	// Ws Routes
 	websocket := v1.Group("/ws")
-	websocket.Get("/", handlers.WsUpgrader(cm), handlers.WsHandler(cm))
+	websocket.Get("/", handlers.WsUpgrader(repos, signer, cm), handlers.WsHandler(repos, cm))
 }
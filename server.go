@@ -4,26 +4,50 @@
 package main
 
 import (
+	"context"
+	"dogeplus-backend/auth"
 	"dogeplus-backend/broadcast"
 	serverConfig "dogeplus-backend/config"
 	"dogeplus-backend/database"
+	"dogeplus-backend/metrics"
 	"dogeplus-backend/router"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 // main initializes and starts the DogePlus Backend application.
 // It sets up all necessary components in the following order:
 // 1. Configuration loading
-// 2. Database connection
-// 3. Repository initialization
+// 2. Database connection and schema migration
+// 3. Repository initialization, admin user bootstrap and token signer
 // 4. Real-time broadcast manager
 // 5. Web server with routes and middleware
-// 6. Server startup on the configured port
+// 6. Server startup on the configured port and background aggregations reconciler
+// 7. Graceful shutdown on SIGINT or SIGTERM, see shutdown
 func main() {
 	// Load configuration from environment variables and config files
 	config := serverConfig.LoadConfig()
 
+	// In dry-run mode only report pending schema migrations and exit
+	if serverConfig.GetEnvWithFallback(config, serverConfig.MigrateDryRun) == "true" {
+		pending, err := database.DryRunMigrations(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(pending) == 0 {
+			log.Info("Db schema up to date, no pending migrations")
+		}
+		for _, migration := range pending {
+			log.Infof("Pending migration %d_%s", migration.Version, migration.Name)
+		}
+		return
+	}
+
 	// Initialize database connection using the loaded configuration
 	db, err := database.GetInstance(config)
 	if err != nil {
@@ -33,8 +57,30 @@ func main() {
 	// Create repository instances for database operations
 	repos := database.NewRepositories(db)
 
+	// Create the first admin user on an empty users table
+	if err := bootstrapAdmin(config, repos); err != nil {
+		log.Fatal(err)
+	}
+
+	// Create the session token signer
+	signer, err := auth.NewTokenSignerFromConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize the connection manager for real-time event broadcasting
-	connectionManager := broadcast.NewConnectionManager()
+	connectionManager := broadcast.NewConnectionManagerWithQueues(sendQueueConfig(config))
+
+	// Expose websocket connections and business state on /metrics
+	if err := metrics.RegisterWebsocketConnections(connectionManager.ConnectedClients); err != nil {
+		log.Fatal(err)
+	}
+	if err := metrics.RegisterEventCollector(repos.EventMetricsSnapshot); err != nil {
+		log.Fatal(err)
+	}
+
+	// Periodically correct the in memory aggregations drifting from the db
+	reconciler := database.NewReconciler(repos, connectionManager)
 
 	// Create a new Fiber application instance for HTTP handling
 	app := router.NewFiberApp()
@@ -43,8 +89,101 @@ func main() {
 	app.Use(cors.New())
 
 	// Configure all API routes with their respective handlers
-	router.SetupRoutes(app, config, repos, connectionManager)
+	router.SetupRoutes(app, config, repos, connectionManager, signer, reconciler)
 
 	// Start the HTTP server on the configured port
-	app.Listen(":" + serverConfig.GetEnvWithFallback(config, serverConfig.Port))
+	go func() {
+		if err := app.Listen(":" + serverConfig.GetEnvWithFallback(config, serverConfig.Port)); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Wait for a termination signal, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	// The reconciler stops with the termination signal
+	if interval := reconcileInterval(config); interval > 0 {
+		go reconciler.Run(ctx, interval)
+	} else {
+		log.Warn("Aggregations reconciler disabled")
+	}
+
+	<-ctx.Done()
+	// Restore the default signal handling, a second signal kills the process right away
+	stop()
+
+	log.Info("Shutting down")
+	shutdown(app, connectionManager, db, shutdownTimeout(config))
+}
+
+// bootstrapAdmin creates a procedure-admin user from AUTH_ADMIN_USER and AUTH_ADMIN_PASSWORD when no user exists yet.
+// Without users nobody can log in, so a warning is logged if the variables are not set.
+func bootstrapAdmin(config serverConfig.Config, repos *database.Repositories) error {
+	count, err := repos.Users.Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	username := serverConfig.GetEnvWithFallback(config, serverConfig.AuthAdminUser)
+	password := serverConfig.GetEnvWithFallback(config, serverConfig.AuthAdminPassword)
+	if username == "" || password == "" {
+		log.Warnf("No users defined, set %s and %s to create the first admin", serverConfig.AuthAdminUser, serverConfig.AuthAdminPassword)
+		return nil
+	}
+
+	if _, err := repos.Users.Add(username, password, database.RoleProcedureAdmin); err != nil {
+		return err
+	}
+	log.Infof("Created admin user %s", username)
+
+	return nil
+}
+
+// defaultReconcileInterval is the aggregations reconciliation period used when RECONCILE_INTERVAL is not set
+const defaultReconcileInterval = time.Minute
+
+// reconcileInterval returns the aggregations reconciliation period from RECONCILE_INTERVAL, 0 disables the reconciler
+func reconcileInterval(config serverConfig.Config) time.Duration {
+	value := serverConfig.GetEnvWithFallback(config, serverConfig.ReconcileInterval)
+	if value == "" {
+		return defaultReconcileInterval
+	}
+	if value == "0" {
+		return 0
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Warnf("Invalid %s %q, using %s", serverConfig.ReconcileInterval, value, defaultReconcileInterval)
+		return defaultReconcileInterval
+	}
+
+	return interval
+}
+
+// sendQueueConfig returns the client send queue configuration from SEND_QUEUE_SIZE and SEND_QUEUE_OVERFLOW
+func sendQueueConfig(config serverConfig.Config) broadcast.QueueConfig {
+	queueConfig := broadcast.DefaultQueueConfig()
+
+	if value := serverConfig.GetEnvWithFallback(config, serverConfig.SendQueueSize); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			log.Warnf("Invalid %s %q, using %d", serverConfig.SendQueueSize, value, queueConfig.Size)
+		} else {
+			queueConfig.Size = size
+		}
+	}
+
+	value := serverConfig.GetEnvWithFallback(config, serverConfig.SendQueueOverflow)
+	overflow, err := broadcast.ParseOverflowPolicy(value)
+	if err != nil {
+		log.Warnf("Invalid %s: %v, using %s", serverConfig.SendQueueOverflow, err, queueConfig.Overflow)
+	} else {
+		queueConfig.Overflow = overflow
+	}
+
+	return queueConfig
 }
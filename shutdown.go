@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"dogeplus-backend/broadcast"
+	serverConfig "dogeplus-backend/config"
+	"dogeplus-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"time"
+)
+
+// defaultShutdownTimeout is the graceful shutdown deadline used when SHUTDOWN_TIMEOUT is not set
+const defaultShutdownTimeout = 15 * time.Second
+
+// shutdownTimeout returns the graceful shutdown deadline from SHUTDOWN_TIMEOUT
+func shutdownTimeout(config serverConfig.Config) time.Duration {
+	value := serverConfig.GetEnvWithFallback(config, serverConfig.ShutdownTimeout)
+	if value == "" {
+		return defaultShutdownTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Warnf("Invalid %s %q, using %s", serverConfig.ShutdownTimeout, value, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+
+	return timeout
+}
+
+// shutdown stops the application within the given deadline:
+// 1. Stop accepting new requests
+// 2. Notify every websocket and event stream client with a server_shutdown message, ending the event streams
+// 3. Wait for the client queues to send the notice, the websocket connections are hijacked so the drain doesn't wait for them
+// 4. Drain the in-flight requests, forcing the remaining connections closed at the deadline
+// 5. Shut down the connection manager, disconnecting the websocket clients
+// 6. Checkpoint and close the database
+func shutdown(app *fiber.App, cm *broadcast.ConnectionManager, db *sql.DB, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The server closes its listeners right away, then waits for the open connections
+	drained := make(chan error, 1)
+	go func() {
+		drained <- app.ShutdownWithContext(ctx)
+	}()
+
+	cm.NotifyShutdown()
+	if err := cm.Flush(ctx); err != nil {
+		log.Warnf("Error sending the shutdown notice: %v", err)
+	}
+
+	if err := <-drained; err != nil {
+		log.Warnf("Error draining http server: %v", err)
+	} else {
+		log.Info("Http server stopped")
+	}
+
+	cm.Shutdown()
+	log.Info("Websocket clients disconnected")
+
+	if err := database.Close(db); err != nil {
+		log.Errorf("Error closing db: %v", err)
+		return
+	}
+	log.Info("Db closed")
+}
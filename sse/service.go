@@ -0,0 +1,185 @@
+// Package sse implements server-sent event streams as a broadcast.Broadcaster,
+// an alternative to websockets for read only clients behind proxies that break websocket upgrades.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Service is a type that represents a server-sent event stream.
+// It implements the broadcast.Broadcaster interface, topics are chosen when the stream is opened.
+type Service struct {
+	Id           string        // Client identifier
+	Username     string        // Authenticated user bound to the stream
+	w            *bufio.Writer // Response body of the stream
+	connected    bool          // Connection state
+	lastActivity time.Time     // Time of last activity
+	topics       []string      // Topics the client is subscribed to
+	done         chan struct{} // Closed when the stream ends
+	mu           sync.Mutex    // Mutex for thread safety
+}
+
+// NewService creates a new Service with the given client ID writing its events to w.
+// The service is initialized as connected with the current time as last activity.
+func NewService(id string, w *bufio.Writer) *Service {
+	return &Service{
+		Id:           id,
+		w:            w,
+		connected:    true,
+		lastActivity: time.Now(),
+		topics:       []string{},
+		done:         make(chan struct{}),
+	}
+}
+
+// Connect sets the connected state to true and updates the last activity time.
+// A stream can't be reopened once ended, it returns an error in that case.
+func (s *Service) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isDone() {
+		return errors.New("stream ended")
+	}
+
+	s.connected = true
+	s.lastActivity = time.Now()
+	return nil
+}
+
+// Disconnect ends the stream, the response is completed once the handler waiting on Done returns.
+func (s *Service) Disconnect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.end()
+	return nil
+}
+
+// EndStream ends the stream like Disconnect, it marks the Service as a broadcast.Streamer.
+func (s *Service) EndStream() {
+	_ = s.Disconnect()
+}
+
+// Done returns a channel closed when the stream ends, either disconnected or after a failed write.
+func (s *Service) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes a message as a server-sent event and flushes it to the client.
+// It updates the last activity time if the message is sent successfully.
+// A failed write means the client went away, the stream is ended and the error returned.
+func (s *Service) Send(message []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return errors.New("not connected")
+	}
+
+	if err := writeEvent(s.w, message); err != nil {
+		s.end()
+		return err
+	}
+
+	s.lastActivity = time.Now()
+	return nil
+}
+
+// IsConnected returns whether the stream is currently open.
+func (s *Service) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// LastActivity returns the time of the last activity for this service.
+func (s *Service) LastActivity() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastActivity
+}
+
+// Subscribe adds a topic to the list of topics the client is subscribed to.
+// It returns nil if the topic is successfully added or if the client is already subscribed to the topic.
+func (s *Service) Subscribe(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.topics {
+		if t == topic {
+			return nil // Already subscribed
+		}
+	}
+
+	s.topics = append(s.topics, topic)
+	return nil
+}
+
+// Unsubscribe removes a topic from the list of topics the client is subscribed to.
+// It returns nil if the topic is successfully removed or if the client is not subscribed to the topic.
+func (s *Service) Unsubscribe(topic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.topics {
+		if t == topic {
+			s.topics = append(s.topics[:i], s.topics[i+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// GetTopics returns a copy of the list of topics the client is subscribed to.
+func (s *Service) GetTopics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	topics := make([]string, len(s.topics))
+	copy(topics, s.topics)
+	return topics
+}
+
+// end marks the stream as disconnected and closes done, the caller must hold the lock
+func (s *Service) end() {
+	s.connected = false
+	if !s.isDone() {
+		close(s.done)
+	}
+}
+
+// isDone reports whether done is closed, the caller must hold the lock
+func (s *Service) isDone() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeEvent writes a message as a single server-sent event, one data field per line, and flushes it
+func writeEvent(w *bufio.Writer, message []byte) error {
+	for _, line := range bytes.Split(message, []byte("\n")) {
+		if _, err := w.WriteString("data: "); err != nil {
+			return err
+		}
+		if _, err := w.Write(bytes.TrimSuffix(line, []byte("\r"))); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
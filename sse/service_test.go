@@ -0,0 +1,112 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// failingWriter fails every write, like the connection of a client gone away
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestService_Send(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{
+			name:     "Single line message",
+			message:  `{"type":"heartbeat"}`,
+			expected: "data: {\"type\":\"heartbeat\"}\n\n",
+		},
+		{
+			name:     "Multi line message",
+			message:  "{\n  \"type\": \"heartbeat\"\r\n}",
+			expected: "data: {\ndata:   \"type\": \"heartbeat\"\ndata: }\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			s := NewService("test-client", bufio.NewWriter(&body))
+
+			if err := s.Send([]byte(tt.message)); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			// The event must be flushed right away
+			if body.String() != tt.expected {
+				t.Errorf("Send() wrote %q, want %q", body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestService_Send_FailedWrite(t *testing.T) {
+	s := NewService("test-client", bufio.NewWriter(failingWriter{}))
+
+	if err := s.Send([]byte(`{"type":"heartbeat"}`)); err == nil {
+		t.Fatalf("Send() error = nil, want the write error")
+	}
+
+	if s.IsConnected() {
+		t.Errorf("IsConnected() = true after a failed write")
+	}
+	select {
+	case <-s.Done():
+	default:
+		t.Errorf("Done() not closed after a failed write")
+	}
+
+	if err := s.Send([]byte(`{"type":"heartbeat"}`)); err == nil {
+		t.Errorf("Send() on an ended stream error = nil, want not connected")
+	}
+}
+
+func TestService_Disconnect(t *testing.T) {
+	var body bytes.Buffer
+	s := NewService("test-client", bufio.NewWriter(&body))
+
+	if err := s.Disconnect(); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	// Ending an ended stream must not panic
+	s.EndStream()
+
+	select {
+	case <-s.Done():
+	default:
+		t.Errorf("Done() not closed after Disconnect()")
+	}
+	if s.IsConnected() {
+		t.Errorf("IsConnected() = true after Disconnect()")
+	}
+	if err := s.Connect(); err == nil {
+		t.Errorf("Connect() on an ended stream error = nil, want an error")
+	}
+}
+
+func TestService_Topics(t *testing.T) {
+	s := NewService("test-client", bufio.NewWriter(&bytes.Buffer{}))
+
+	_ = s.Subscribe("event_updates")
+	_ = s.Subscribe("central_SRA")
+	_ = s.Subscribe("event_updates")
+	if want := []string{"event_updates", "central_SRA"}; !reflect.DeepEqual(s.GetTopics(), want) {
+		t.Errorf("GetTopics() = %v, want %v", s.GetTopics(), want)
+	}
+
+	_ = s.Unsubscribe("event_updates")
+	_ = s.Unsubscribe("missing")
+	if want := []string{"central_SRA"}; !reflect.DeepEqual(s.GetTopics(), want) {
+		t.Errorf("GetTopics() = %v, want %v", s.GetTopics(), want)
+	}
+}
@@ -12,6 +12,7 @@ import (
 type Service struct {
 	Conn         *websocket.Conn // WebSocket connection
 	Id           string          // Client identifier
+	Username     string          // Authenticated user bound to the connection
 	connected    bool            // Connection state
 	lastActivity time.Time       // Time of last activity
 	topics       []string        // Topics the client is subscribed to